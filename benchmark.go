@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// benchmarkReport is the JSON line --benchmark-mode prints to stderr when
+// the scan finishes.
+type benchmarkReport struct {
+	FilesChecked int64   `json:"files_checked"`
+	Matches      int64   `json:"matches"`
+	DurationMS   int64   `json:"duration_ms"`
+	FilesPerSec  float64 `json:"files_per_sec"`
+}
+
+func newBenchmarkReport(filesChecked, matches int64, elapsed time.Duration) benchmarkReport {
+	r := benchmarkReport{FilesChecked: filesChecked, Matches: matches, DurationMS: elapsed.Milliseconds()}
+	if secs := elapsed.Seconds(); secs > 0 {
+		r.FilesPerSec = float64(filesChecked) / secs
+	}
+	return r
+}
+
+func (r benchmarkReport) write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestBenchmarkReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newBenchmarkReport(5000, 12, 2500*time.Millisecond).write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got map[string]float64
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	want := map[string]float64{"files_checked": 5000, "matches": 12, "duration_ms": 2500, "files_per_sec": 2000}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("expected %s=%v, got %v in %s", k, v, got[k], buf.String())
+		}
+	}
+}
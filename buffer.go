@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// errBufferFull is wrapped by the error a scan stops with when --max-buffer
+// is exceeded.
+var errBufferFull = errors.New("--max-buffer exceeded")
+
+// bufferLimit implements --max-buffer. Features that remember something
+// for every match, content hash or directory seen take a slot before
+// storing a new entry; once max slots are held in total the scan is
+// cancelled with an error naming the feature, rather than letting memory
+// grow with the size of the tree. A nil *bufferLimit imposes no limit. It
+// is safe for concurrent use by workers.
+type bufferLimit struct {
+	max int
+
+	mu   sync.Mutex
+	used int
+	err  error
+	// cancel stops the scan; it is set once the scan's context exists.
+	cancel context.CancelCauseFunc
+}
+
+func newBufferLimit(max int) *bufferLimit {
+	return &bufferLimit{max: max}
+}
+
+// take claims a slot for one more entry held by feature, reporting false
+// once the limit has been reached, in which case the entry must not be
+// stored.
+func (l *bufferLimit) take(feature string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false
+	}
+	if l.used >= l.max {
+		l.err = fmt.Errorf("%w: %s needs to hold more than %d entries; raise --max-buffer or narrow the scan", errBufferFull, feature, l.max)
+		if l.cancel != nil {
+			l.cancel(l.err)
+		}
+		return false
+	}
+	l.used++
+	return true
+}
+
+// Err returns the error the limit stopped the scan with, or nil.
+func (l *bufferLimit) Err() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
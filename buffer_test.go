@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPickByContentMaxBuffer(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	limit := newBufferLimit(2)
+	limit.cancel = cancel
+
+	in := make(chan matchResult, 10)
+	for i := 0; i < 10; i++ {
+		in <- matchResult{Path: fmt.Sprintf("/data/%d.db", i), SHA256: fmt.Sprintf("%064d", i)}
+	}
+	close(in)
+	var got []string
+	for m := range pickByContent(in, "newest", limit) {
+		got = append(got, m.Path)
+	}
+
+	// Only the hashes that fitted were kept, and the scan was told to stop
+	// with an error naming the feature.
+	if len(got) != 2 {
+		t.Fatalf("expected 2 buffered matches, got %v", got)
+	}
+	err := limit.Err()
+	if !errors.Is(err, errBufferFull) || !strings.Contains(err.Error(), "--keep newest") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ctx.Err() == nil || context.Cause(ctx) != err {
+		t.Fatalf("expected the scan context to be cancelled with %v, got %v", err, context.Cause(ctx))
+	}
+}
+
+func TestScanPathsMaxBufferStopsDedup(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 20; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%02d.db", i)), sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	limit := newBufferLimit(5)
+	limit.cancel = cancel
+
+	matches := make(chan matchResult, 32)
+	errs := make(chan error, 32)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Filters: []matchFilter{dedupPathFilter("path", limit)}}
+	err := scanPaths(ctx, []string{root}, opts, matches, errs)
+	n := 0
+	for range matches {
+		n++
+	}
+	if n > 5 {
+		t.Fatalf("expected at most 5 matches within the limit, got %d", n)
+	}
+	if !errors.Is(limit.Err(), errBufferFull) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the scan to stop on the limit, got scan error %v, limit error %v", err, limit.Err())
+	}
+
+	// Without --max-buffer the limit is nil and never refuses.
+	var none *bufferLimit
+	if !none.take("x") || none.Err() != nil {
+		t.Fatalf("a nil limit must not restrict")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// scanCacheVersion is bumped whenever the cache format or the meaning of a
+// cached entry changes; caches with another version are ignored.
+const scanCacheVersion = 7
+
+// scanCache implements --cache-file. A directory's mtime only changes when
+// entries are added, removed or renamed inside it, so an unchanged directory
+// can replay its cached matches and subdirectory list without being read.
+// Subdirectories are still visited because their own changes do not bump
+// the parent's mtime. Files rewritten in place are not detected, and a
+// directory is only saved once every file in it was checked without error.
+type scanCache struct {
+	key  string
+	prev map[string]*dirCacheEntry
+
+	mu   sync.Mutex
+	next map[string]*dirCacheEntry
+}
+
+type dirCacheEntry struct {
+	ModTime time.Time     `json:"mtime"`
+	Subdirs []string      `json:"subdirs,omitempty"`
+	Matches []matchResult `json:"matches,omitempty"`
+	// incomplete is set when a file in the directory errored or was
+	// skipped, so the entry is not saved and the next run reads the
+	// directory again.
+	incomplete bool
+}
+
+type scanCacheFile struct {
+	Version int                       `json:"version"`
+	Key     string                    `json:"key"`
+	Dirs    map[string]*dirCacheEntry `json:"dirs"`
+}
+
+// loadScanCache reads path, returning an empty cache if it does not exist,
+// was written by an incompatible version, or was recorded with check
+// options (key) that produce different match details.
+func loadScanCache(path string, key string) (*scanCache, error) {
+	c := &scanCache{
+		key:  key,
+		prev: map[string]*dirCacheEntry{},
+		next: map[string]*dirCacheEntry{},
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f scanCacheFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version == scanCacheVersion && f.Key == key && f.Dirs != nil {
+		c.prev = f.Dirs
+	}
+	return c, nil
+}
+
+// save writes the directories seen during this scan; directories that were
+// not visited or are incomplete are dropped.
+func (c *scanCache) save(path string) error {
+	c.mu.Lock()
+	dirs := make(map[string]*dirCacheEntry, len(c.next))
+	for dir, e := range c.next {
+		if !e.incomplete {
+			dirs[dir] = e
+		}
+	}
+	b, err := json.Marshal(scanCacheFile{Version: scanCacheVersion, Key: c.key, Dirs: dirs})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// recordMatch remembers a freshly checked match under its directory.
+func (c *scanCache) recordMatch(m matchResult) {
+	dir, err := filepath.Abs(filepath.Dir(m.Path))
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.next[dir]; e != nil {
+		m.Path = filepath.Join(dir, filepath.Base(m.Path))
+		e.Matches = append(e.Matches, m)
+	}
+}
+
+// markIncomplete records that path was not checked successfully, so its
+// directory is not cached and the file is looked at again next time.
+func (c *scanCache) markIncomplete(path string) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e := c.next[dir]; e != nil {
+		e.incomplete = true
+	}
+}
+
+// walkHooks carries the callbacks scanPaths uses to drive a cached walk.
+type walkHooks struct {
+	logger *slog.Logger
+	denied *deniedPaths
+	// enterDir, when set, reports whether a directory below the root
+	// should be descended into.
+	enterDir func(path string, info fs.FileInfo) bool
+	offer    func(path string, d fs.DirEntry) error
+	emit     func(m matchResult) error
+	// newestFirst offers each directory's files most recently modified
+	// first, before descending into its subdirectories.
+	newestFirst bool
+}
+
+// walk visits root like filepath.WalkDir, handing regular files in changed
+// directories to offer and replaying cached matches of unchanged ones
+// through emit.
+func (c *scanCache) walk(ctx context.Context, root string, h walkHooks) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	return c.visit(ctx, abs, true, h)
+}
+
+func (c *scanCache) visit(ctx context.Context, dir string, isRoot bool, h walkHooks) error {
+	logger := h.logger
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	if !isRoot && h.enterDir != nil && !h.enterDir(dir, info) {
+		return nil
+	}
+
+	if prev := c.prev[dir]; prev != nil && prev.ModTime.Equal(info.ModTime()) {
+		logger.Debug("reusing cached directory", "path", dir)
+		c.mu.Lock()
+		c.next[dir] = &dirCacheEntry{
+			ModTime: prev.ModTime,
+			Subdirs: prev.Subdirs,
+			Matches: append([]matchResult(nil), prev.Matches...),
+		}
+		c.mu.Unlock()
+		for _, m := range prev.Matches {
+			if err := h.emit(m); err != nil {
+				return err
+			}
+		}
+		for _, sub := range prev.Subdirs {
+			if err := c.visit(ctx, filepath.Join(dir, sub), false, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	logger.Debug("entering directory", "path", dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			logger.Debug("skipping unreadable path", "path", dir)
+			h.denied.add(dir)
+			return nil
+		}
+		return err
+	}
+	entry := &dirCacheEntry{ModTime: info.ModTime()}
+	for _, e := range entries {
+		if e.IsDir() {
+			entry.Subdirs = append(entry.Subdirs, e.Name())
+		}
+	}
+	c.mu.Lock()
+	c.next[dir] = entry
+	c.mu.Unlock()
+
+	if h.newestFirst {
+		for _, e := range newestFiles(entries) {
+			if err := h.offer(filepath.Join(dir, e.Name()), e); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch {
+		case e.IsDir():
+			if err := c.visit(ctx, path, false, h); err != nil {
+				return err
+			}
+		case e.Type().IsRegular() && !h.newestFirst:
+			if err := h.offer(path, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
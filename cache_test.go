@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+)
+
+func TestScanCacheSkipsUnchangedTree(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "nested")
+	if err := os.MkdirAll(sub, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	content := append(append([]byte{}, sqliteMagic...), []byte("payload")...)
+	for _, p := range []string{filepath.Join(root, "a.db"), filepath.Join(sub, "b.db")} {
+		if err := os.WriteFile(p, content, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(sub, "notes.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+
+	run := func() ([]string, int64) {
+		cache, err := loadScanCache(cachePath, checkOptions{}.cacheKey())
+		if err != nil {
+			t.Fatalf("loadScanCache: %v", err)
+		}
+		stats := &scanStats{}
+		opts := scanOptions{
+			Workers: 2,
+			Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+			Check:   checkOptions{Stats: stats},
+			Cache:   cache,
+		}
+		matches := make(chan matchResult, 8)
+		errs := make(chan error, 8)
+		if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+		var got []string
+		for m := range matches {
+			got = append(got, filepath.Base(m.Path))
+		}
+		sort.Strings(got)
+		if err := cache.save(cachePath); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		return got, stats.BytesRead.Load()
+	}
+
+	first, read := run()
+	if len(first) != 2 || read == 0 {
+		t.Fatalf("expected first run to read files and find 2 dbs, got %v (%d bytes)", first, read)
+	}
+	second, read := run()
+	if read != 0 {
+		t.Fatalf("expected second run to read no bytes, read %d", read)
+	}
+	if len(second) != 2 || second[0] != "a.db" || second[1] != "b.db" {
+		t.Fatalf("expected cached matches a.db and b.db, got %v", second)
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, "c.db"), content, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	third, read := run()
+	if read == 0 || len(third) != 3 {
+		t.Fatalf("expected changed directory to be re-read, got %v (%d bytes)", third, read)
+	}
+}
+
+func TestScanCacheRechecksFailedFiles(t *testing.T) {
+	root := t.TempDir()
+	good := filepath.Join(root, "good.db")
+	flaky := filepath.Join(root, "flaky.db")
+	for _, p := range []string{good, flaky} {
+		if err := os.WriteFile(p, sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+
+	orig := openFile
+	t.Cleanup(func() { openFile = orig })
+	failing := true
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		if name == flaky && failing {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EIO}
+		}
+		return orig(name, flag, perm)
+	}
+
+	run := func() ([]string, int) {
+		cache, err := loadScanCache(cachePath, checkOptions{}.cacheKey())
+		if err != nil {
+			t.Fatalf("loadScanCache: %v", err)
+		}
+		opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Cache: cache}
+		matches := make(chan matchResult, 8)
+		errs := make(chan error, 8)
+		if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+		var got []string
+		for m := range matches {
+			got = append(got, filepath.Base(m.Path))
+		}
+		sort.Strings(got)
+		warnings := 0
+		for range errs {
+			warnings++
+		}
+		if err := cache.save(cachePath); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		return got, warnings
+	}
+
+	first, warnings := run()
+	if len(first) != 1 || first[0] != "good.db" || warnings != 1 {
+		t.Fatalf("expected only good.db and one warning, got %v and %d warnings", first, warnings)
+	}
+	failing = false
+	second, _ := run()
+	if len(second) != 2 || second[0] != "flaky.db" || second[1] != "good.db" {
+		t.Fatalf("expected the directory to be read again and flaky.db found, got %v", second)
+	}
+}
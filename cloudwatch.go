@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// defaultCloudWatchNamespace is used when --cloud-watch-namespace is not
+// set.
+const defaultCloudWatchNamespace = "SQLiteScanner"
+
+// maxDimensionValue is CloudWatch's limit on a dimension value.
+const maxDimensionValue = 1024
+
+// cloudWatchAPI is the part of *cloudwatch.Client --cloud-watch uses.
+type cloudWatchAPI interface {
+	PutMetricData(ctx context.Context, in *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+func newCloudWatchClient(ctx context.Context) (*cloudwatch.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatch.NewFromConfig(cfg), nil
+}
+
+// scanMetrics are the totals --cloud-watch reports once the scan ends.
+type scanMetrics struct {
+	FilesChecked int64
+	Matches      int64
+	Errors       int64
+	Duration     time.Duration
+}
+
+// putScanMetrics sends m to namespace in a single PutMetricData call, so
+// a scan costs one API request however many files it checks. Every metric
+// carries ScanRoot (the roots, comma-separated) and Hostname dimensions.
+func putScanMetrics(ctx context.Context, client cloudWatchAPI, namespace string, roots []string, m scanMetrics, now time.Time) error {
+	host, _ := os.Hostname()
+	root := strings.Join(roots, ",")
+	if len(root) > maxDimensionValue {
+		root = root[:maxDimensionValue]
+	}
+	dims := []types.Dimension{
+		{Name: aws.String("ScanRoot"), Value: aws.String(root)},
+		{Name: aws.String("Hostname"), Value: aws.String(host)},
+	}
+	datum := func(name string, v float64, unit types.StandardUnit) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String(name),
+			Value:      aws.Float64(v),
+			Unit:       unit,
+			Dimensions: dims,
+			Timestamp:  aws.Time(now),
+		}
+	}
+	_, err := client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(namespace),
+		MetricData: []types.MetricDatum{
+			datum("FilesChecked", float64(m.FilesChecked), types.StandardUnitCount),
+			datum("MatchesFound", float64(m.Matches), types.StandardUnitCount),
+			datum("ErrorsEncountered", float64(m.Errors), types.StandardUnitCount),
+			datum("ScanDurationMs", float64(m.Duration.Milliseconds()), types.StandardUnitMilliseconds),
+		},
+	})
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// recordingCloudWatch collects PutMetricData requests.
+type recordingCloudWatch struct {
+	calls []*cloudwatch.PutMetricDataInput
+}
+
+func (r *recordingCloudWatch) PutMetricData(_ context.Context, in *cloudwatch.PutMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	r.calls = append(r.calls, in)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestPutScanMetrics(t *testing.T) {
+	rec := &recordingCloudWatch{}
+	m := scanMetrics{FilesChecked: 120, Matches: 3, Errors: 1, Duration: 1500 * time.Millisecond}
+	if err := putScanMetrics(context.Background(), rec, "MyApp/SQLiteScanner", []string{"/data", "/srv"}, m, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.calls) != 1 {
+		t.Fatalf("expected a single PutMetricData call, got %d", len(rec.calls))
+	}
+	in := rec.calls[0]
+	if aws.ToString(in.Namespace) != "MyApp/SQLiteScanner" {
+		t.Fatalf("unexpected namespace %q", aws.ToString(in.Namespace))
+	}
+	want := map[string]float64{"FilesChecked": 120, "MatchesFound": 3, "ErrorsEncountered": 1, "ScanDurationMs": 1500}
+	if len(in.MetricData) != len(want) {
+		t.Fatalf("expected %d metrics, got %d", len(want), len(in.MetricData))
+	}
+	for _, d := range in.MetricData {
+		name := aws.ToString(d.MetricName)
+		if v, ok := want[name]; !ok || aws.ToFloat64(d.Value) != v {
+			t.Fatalf("unexpected %s = %v", name, aws.ToFloat64(d.Value))
+		}
+		dims := map[string]string{}
+		for _, dim := range d.Dimensions {
+			dims[aws.ToString(dim.Name)] = aws.ToString(dim.Value)
+		}
+		if dims["ScanRoot"] != "/data,/srv" || len(dims) != 2 {
+			t.Fatalf("unexpected dimensions %v", dims)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// manifestEntry is the part of a saved --json or --jsonl entry that
+// --compare reads: the path and, when they were recorded, the size and
+// hashes.
+type manifestEntry struct {
+	Path   string `json:"path"`
+	Size   *int64 `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Blake3 string `json:"blake3,omitempty"`
+}
+
+// manifestChange is a database present in both scans whose size or hash
+// differs.
+type manifestChange struct {
+	Path   string        `json:"path"`
+	Before manifestEntry `json:"before"`
+	After  manifestEntry `json:"after"`
+}
+
+// manifestDiff is what --compare prints, each list sorted by path.
+type manifestDiff struct {
+	Added   []manifestEntry  `json:"added"`
+	Removed []manifestEntry  `json:"removed"`
+	Changed []manifestChange `json:"changed"`
+}
+
+// loadManifest reads a previous scan saved with --json (under any
+// --json-key) or --jsonl, keyed by path.
+func loadManifest(path string) (map[string]manifestEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var raw json.RawMessage
+	var first map[string]json.RawMessage
+	if dec.Decode(&raw) != nil || json.Unmarshal(raw, &first) != nil || first == nil {
+		return nil, fmt.Errorf("%s: not a --json or --jsonl scan", path)
+	}
+	entries := map[string]manifestEntry{}
+	add := func(raw json.RawMessage) error {
+		var e manifestEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		if e.Path == "" {
+			return errors.New("entry without a path")
+		}
+		entries[e.Path] = e
+		return nil
+	}
+	if _, ok := first["path"]; !ok {
+		// A --json document: the entries are its only array.
+		for _, v := range first {
+			var list []json.RawMessage
+			if json.Unmarshal(v, &list) != nil {
+				continue
+			}
+			for _, raw := range list {
+				if err := add(raw); err != nil {
+					return nil, fmt.Errorf("%s: %w", path, err)
+				}
+			}
+			return entries, nil
+		}
+		return nil, fmt.Errorf("%s: no entries array found", path)
+	}
+	// --jsonl: one entry per line. --inotify-watch events carry an
+	// "event" field and are skipped.
+	for {
+		var ev struct {
+			Event string `json:"event"`
+		}
+		json.Unmarshal(raw, &ev)
+		if ev.Event == "" {
+			if err := add(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		raw = nil
+		if err := dec.Decode(&raw); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
+
+// manifestEntryOf records m the way it would be printed with opts, so the
+// paths line up with a manifest saved with the same --trim-prefix.
+func manifestEntryOf(m matchResult, opts outputOptions) manifestEntry {
+	e := manifestEntry{Path: opts.displayPath(m.Path), SHA256: m.SHA256, Blake3: m.Blake3}
+	if m.Size >= 0 {
+		e.Size = &m.Size
+	}
+	return e
+}
+
+// entryChanged reports whether a database differs between the scans, by
+// size or by a hash both of them recorded.
+func entryChanged(before, after manifestEntry) bool {
+	return (before.Size != nil && after.Size != nil && *before.Size != *after.Size) ||
+		(before.SHA256 != "" && after.SHA256 != "" && before.SHA256 != after.SHA256) ||
+		(before.Blake3 != "" && after.Blake3 != "" && before.Blake3 != after.Blake3)
+}
+
+// diffManifest compares the current scan with the baseline manifest.
+func diffManifest(baseline map[string]manifestEntry, current []manifestEntry) manifestDiff {
+	d := manifestDiff{Added: []manifestEntry{}, Removed: []manifestEntry{}, Changed: []manifestChange{}}
+	seen := make(map[string]bool, len(current))
+	for _, e := range current {
+		seen[e.Path] = true
+		before, ok := baseline[e.Path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e)
+		case entryChanged(before, e):
+			d.Changed = append(d.Changed, manifestChange{Path: e.Path, Before: before, After: e})
+		}
+	}
+	for p, e := range baseline {
+		if !seen[p] {
+			d.Removed = append(d.Removed, e)
+		}
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Path < d.Added[j].Path })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Path < d.Removed[j].Path })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Path < d.Changed[j].Path })
+	return d
+}
+
+// writeComparison implements --compare: it drains matches and prints how
+// they differ from baseline, as one JSON object with --json or --jsonl,
+// or otherwise one line per database marked + (added), - (removed) or
+// ~ (changed).
+func writeComparison(w io.Writer, matches <-chan matchResult, baseline map[string]manifestEntry, opts outputOptions) error {
+	var current []manifestEntry
+	for m := range matches {
+		if m.Event == "" {
+			current = append(current, manifestEntryOf(m, opts))
+		}
+	}
+	d := diffManifest(baseline, current)
+	if opts.JSON || opts.JSONL {
+		indent := opts.jsonIndent()
+		if opts.JSONL {
+			indent = ""
+		}
+		_, err := fmt.Fprintln(w, marshalJSON(d, "", indent))
+		return err
+	}
+	for _, e := range d.Added {
+		if _, err := fmt.Fprintf(w, "+ %s\n", e.Path); err != nil {
+			return err
+		}
+	}
+	for _, e := range d.Removed {
+		if _, err := fmt.Fprintf(w, "- %s\n", e.Path); err != nil {
+			return err
+		}
+	}
+	for _, c := range d.Changed {
+		if _, err := fmt.Fprintf(w, "~ %s (%s)\n", c.Path, describeChange(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeChange lists what differs, e.g. "size 4096 -> 8192".
+func describeChange(c manifestChange) string {
+	var parts []string
+	b, a := c.Before, c.After
+	if b.Size != nil && a.Size != nil && *b.Size != *a.Size {
+		parts = append(parts, fmt.Sprintf("size %d -> %d", *b.Size, *a.Size))
+	}
+	if b.SHA256 != "" && a.SHA256 != "" && b.SHA256 != a.SHA256 {
+		parts = append(parts, "sha256 "+b.SHA256+" -> "+a.SHA256)
+	}
+	if b.Blake3 != "" && a.Blake3 != "" && b.Blake3 != a.Blake3 {
+		parts = append(parts, "blake3 "+b.Blake3+" -> "+a.Blake3)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) string {
+		path := filepath.Join(dir, name)
+		content := append(append([]byte{}, sqliteMagic...), make([]byte, size)...)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	kept := write("kept.db", 100)
+	grown := write("grown.db", 100)
+	removed := write("removed.db", 100)
+
+	// Save the baseline the way --json would.
+	opts := outputOptions{JSON: true, ShowSize: true}
+	before, err := collectSQLiteFiles(context.Background(), []string{dir}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved bytes.Buffer
+	matches := make(chan matchResult, len(before))
+	for _, m := range before {
+		matches <- m
+	}
+	close(matches)
+	streamMatches(context.Background(), &saved, matches, opts)
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(manifest, saved.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(removed)
+	write("grown.db", 200)
+	added := write("added.db", 100)
+
+	baseline, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	if len(baseline) != 3 {
+		t.Fatalf("expected 3 baseline entries, got %v", baseline)
+	}
+	after, err := collectSQLiteFiles(context.Background(), []string{dir}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches = make(chan matchResult, len(after))
+	for _, m := range after {
+		matches <- m
+	}
+	close(matches)
+	var out bytes.Buffer
+	if err := writeComparison(&out, matches, baseline, opts); err != nil {
+		t.Fatal(err)
+	}
+	var d manifestDiff
+	if err := json.Unmarshal(out.Bytes(), &d); err != nil {
+		t.Fatalf("invalid JSON diff: %v\n%s", err, out.String())
+	}
+	if len(d.Added) != 1 || d.Added[0].Path != added {
+		t.Fatalf("expected %s added, got %+v", added, d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Path != removed {
+		t.Fatalf("expected %s removed, got %+v", removed, d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Path != grown || *d.Changed[0].Before.Size != 116 || *d.Changed[0].After.Size != 216 {
+		t.Fatalf("expected %s changed, got %+v", grown, d.Changed)
+	}
+	if strings.Contains(out.String(), kept) {
+		t.Fatalf("unchanged %s should not be listed:\n%s", kept, out.String())
+	}
+}
+
+func TestWriteComparisonPlain(t *testing.T) {
+	size := func(n int64) *int64 { return &n }
+	baseline := map[string]manifestEntry{
+		"/data/gone.db": {Path: "/data/gone.db"},
+		"/data/app.db":  {Path: "/data/app.db", Size: size(4096), SHA256: "aa"},
+	}
+	matches := make(chan matchResult, 2)
+	matches <- matchResult{Path: "/data/app.db", Size: 8192, SHA256: "bb"}
+	matches <- matchResult{Path: "/data/new.db", Size: 4096}
+	close(matches)
+	var out bytes.Buffer
+	if err := writeComparison(&out, matches, baseline, outputOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "+ /data/new.db\n- /data/gone.db\n~ /data/app.db (size 4096 -> 8192, sha256 aa -> bb)\n"
+	if out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestLoadManifestJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.jsonl")
+	lines := `{"path":"/data/a.db","size":4096}
+{"event":"removed","path":"/data/b.db"}
+{"path":"/data/c.db"}
+`
+	if err := os.WriteFile(path, []byte(lines), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	if len(m) != 2 || *m["/data/a.db"].Size != 4096 || m["/data/c.db"].Size != nil {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressSuffixes maps each --compress-output algorithm to the file name
+// suffix its output gets.
+var compressSuffixes = map[string]string{
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// compressedName returns path with the suffix for algo appended, unless it
+// already ends with it.
+func compressedName(path, algo string) string {
+	suffix := compressSuffixes[algo]
+	if strings.HasSuffix(path, suffix) {
+		return path
+	}
+	return path + suffix
+}
+
+// newCompressor wraps w in the named compressor. Closing it flushes the
+// compressed stream but leaves w open.
+func newCompressor(w io.Writer, algo string) (io.WriteCloser, error) {
+	switch algo {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unknown compression %q: must be gzip or zstd", algo)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressedName(t *testing.T) {
+	for _, tc := range []struct{ path, algo, want string }{
+		{"scan.jsonl", "gzip", "scan.jsonl.gz"},
+		{"scan.jsonl.gz", "gzip", "scan.jsonl.gz"},
+		{"scan.jsonl", "zstd", "scan.jsonl.zst"},
+		{"scan.jsonl.gz", "zstd", "scan.jsonl.gz.zst"},
+	} {
+		if got := compressedName(tc.path, tc.algo); got != tc.want {
+			t.Fatalf("compressedName(%q, %q) = %q, want %q", tc.path, tc.algo, got, tc.want)
+		}
+	}
+}
+
+func TestCompressorRoundTrip(t *testing.T) {
+	lines := formatJSONLine(matchResult{Path: "/data/a.db"}, outputOptions{}) + "\n" +
+		formatJSONLine(matchResult{Path: "/data/b.db"}, outputOptions{}) + "\n"
+	for algo, decompress := range map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	} {
+		path := filepath.Join(t.TempDir(), compressedName("scan.jsonl", algo))
+		sink, err := createAtomic(path)
+		if err != nil {
+			t.Fatalf("createAtomic: %v", err)
+		}
+		c, err := newCompressor(sink, algo)
+		if err != nil {
+			t.Fatalf("%s: %v", algo, err)
+		}
+		io.WriteString(c, lines)
+		if err := c.Close(); err != nil {
+			t.Fatalf("%s close: %v", algo, err)
+		}
+		if err := sink.Commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		r, err := decompress(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%s reader: %v", algo, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != lines {
+			t.Fatalf("%s: expected %q, got %q (err %v)", algo, lines, got, err)
+		}
+	}
+}
+
+func TestGzipOutputStreamsJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.jsonl.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	gz, _ := newCompressor(f, "gzip")
+	matches := make(chan matchResult, 2)
+	matches <- matchResult{Path: "/data/a.db", Size: 4096}
+	matches <- matchResult{Path: "/data/b.db", Size: 8192}
+	close(matches)
+	streamMatches(t.Context(), gz, matches, outputOptions{JSONL: true, ShowSize: true})
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	f.Close()
+
+	raw, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer raw.Close()
+	r, err := gzip.NewReader(raw)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	want := "{\"path\":\"/data/a.db\",\"size\":4096}\n{\"path\":\"/data/b.db\",\"size\":8192}\n"
+	if err != nil || string(got) != want {
+		t.Fatalf("expected %q, got %q (err %v)", want, got, err)
+	}
+}
+
+func TestOutputChainInterruptedGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.json.gz")
+	chain, err := newOutputChain(path, nil, "gzip")
+	if err != nil {
+		t.Fatalf("newOutputChain: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	matches := make(chan matchResult)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		streamMatches(ctx, chain.W, matches, outputOptions{JSON: true})
+	}()
+	// Cancel mid-scan: the walk stops and closes matches, as on Ctrl-C.
+	matches <- matchResult{Path: "/data/a.db"}
+	cancel()
+	close(matches)
+	<-done
+	if err := chain.close(true); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := chain.close(true); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+
+	raw, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer raw.Close()
+	r, err := gzip.NewReader(raw)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	var doc struct {
+		Entries   []map[string]any `json:"entries"`
+		Truncated bool             `json:"truncated"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		t.Fatalf("interrupted output is not valid gzipped JSON: %v", err)
+	}
+	if len(doc.Entries) != 1 || !doc.Truncated {
+		t.Fatalf("expected one entry and truncated, got %+v", doc)
+	}
+}
+
+func TestOutputChainAbortRemovesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.jsonl.gz")
+	chain, err := newOutputChain(path, nil, "gzip")
+	if err != nil {
+		t.Fatalf("newOutputChain: %v", err)
+	}
+	io.WriteString(chain.W, "partial\n")
+	if err := chain.close(false); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 0 {
+		t.Fatalf("expected no files after abort, got %v", entries)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+)
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/sqlite-scanner/config.toml,
+// falling back to ~/.config when XDG_CONFIG_HOME is unset.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "sqlite-scanner", "config.toml")
+}
+
+// applyConfigFile sets flags from a TOML file whose keys are flag names,
+// for example:
+//
+//	workers = 4
+//	size = true
+//	log-level = "info"
+//
+// Flags already given on the command line win. A missing file is only an
+// error when mustExist is set (an explicit --config).
+func applyConfigFile(flags *pflag.FlagSet, path string, mustExist bool) error {
+	var values map[string]any
+	if _, err := toml.DecodeFile(path, &values); err != nil {
+		if !mustExist && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for key, v := range values {
+		f := flags.Lookup(key)
+		if f == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if f.Changed {
+			continue
+		}
+		items, isList := v.([]any)
+		if !isList {
+			items = []any{v}
+		}
+		for _, item := range items {
+			if err := flags.Set(key, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
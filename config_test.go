@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := `workers = 4
+size = true
+log-level = "info"
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	workers := flags.Int("workers", 1, "")
+	size := flags.Bool("size", false, "")
+	logLevel := flags.String("log-level", "warn", "")
+	if err := flags.Parse([]string{"--log-level", "debug"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if err := applyConfigFile(flags, path, true); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	if *workers != 4 || !*size {
+		t.Fatalf("expected config values workers=4 size=true, got %d %v", *workers, *size)
+	}
+	if *logLevel != "debug" {
+		t.Fatalf("expected command-line --log-level to win, got %q", *logLevel)
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("workers", 1, "")
+
+	missing := filepath.Join(t.TempDir(), "missing.toml")
+	if err := applyConfigFile(flags, missing, false); err != nil {
+		t.Fatalf("expected missing default config to be ignored, got %v", err)
+	}
+	if err := applyConfigFile(flags, missing, true); err == nil {
+		t.Fatalf("expected missing explicit config to fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("wrokers = 2\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := applyConfigFile(flags, path, true); err == nil || !strings.Contains(err.Error(), "wrokers") {
+		t.Fatalf("expected unknown-key error, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	got, err := parseCPUList("0-3,8-11")
+	if err != nil {
+		t.Fatalf("parseCPUList: %v", err)
+	}
+	want := []int{0, 1, 2, 3, 8, 9, 10, 11}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if got, err := parseCPUList("5"); err != nil || !reflect.DeepEqual(got, []int{5}) {
+		t.Fatalf("expected [5], got %v (err %v)", got, err)
+	}
+
+	for _, bad := range []string{"", "a", "3-1", "-2", "1,,x"} {
+		if _, err := parseCPUList(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
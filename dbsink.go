@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// dbSinkBatchSize is how many rows are inserted per transaction before it
+// is committed, so an interrupted scan still leaves most rows on disk.
+const dbSinkBatchSize = 500
+
+// dbSink writes matches into a SQLite database for --db-output.
+type dbSink struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+}
+
+func openDBSink(path string) (*dbSink, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS matches (
+		path TEXT NOT NULL,
+		size INTEGER,
+		mtime TEXT
+	)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &dbSink{db: db}
+	if err := s.begin(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *dbSink) begin() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO matches (path, size, mtime) VALUES (?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	s.tx, s.stmt, s.pending = tx, stmt, 0
+	return nil
+}
+
+func (s *dbSink) commit() error {
+	s.stmt.Close()
+	return s.tx.Commit()
+}
+
+func (s *dbSink) Add(m matchResult) error {
+	var mtime any
+	if !m.ModTime.IsZero() {
+		mtime = m.ModTime.UTC().Format(time.RFC3339)
+	}
+	if _, err := s.stmt.Exec(formatPath(m.Path), m.Size, mtime); err != nil {
+		return err
+	}
+	s.pending++
+	if s.pending < dbSinkBatchSize {
+		return nil
+	}
+	if err := s.commit(); err != nil {
+		return err
+	}
+	return s.begin()
+}
+
+func (s *dbSink) Close() error {
+	err := s.commit()
+	if cerr := s.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
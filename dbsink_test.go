@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDBSinkRecordsScan(t *testing.T) {
+	root := t.TempDir()
+	content := append(append([]byte{}, sqliteMagic...), []byte("payload")...)
+	for _, name := range []string{"a.db", "b.sqlite"} {
+		if err := os.WriteFile(filepath.Join(root, name), content, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+
+	results, err := collectSQLiteFiles(context.Background(), []string{root}, runtime.NumCPU())
+	if err != nil {
+		t.Fatalf("findSQLiteFiles: %v", err)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "scan.db")
+	sink, err := openDBSink(outPath)
+	if err != nil {
+		t.Fatalf("openDBSink: %v", err)
+	}
+	for _, m := range results {
+		if err := sink.Add(m); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", outPath)
+	if err != nil {
+		t.Fatalf("open result db: %v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT path, size, mtime FROM matches ORDER BY path")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var path, mtime string
+		var size int64
+		if err := rows.Scan(&path, &size, &mtime); err != nil {
+			t.Fatalf("scan row: %v", err)
+		}
+		if size != int64(len(content)) {
+			t.Fatalf("expected size %d for %s, got %d", len(content), path, size)
+		}
+		if mtime == "" {
+			t.Fatalf("expected mtime for %s", path)
+		}
+		got = append(got, filepath.Base(path))
+	}
+	if len(got) != 2 || got[0] != "a.db" || got[1] != "b.sqlite" {
+		t.Fatalf("expected rows for a.db and b.sqlite, got %v", got)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// dedupModes are the values of --dedup-by.
+var dedupModes = []string{"path", "path-ci"}
+
+// dedupPathFilter drops matches whose absolute path was already reported,
+// which happens when roots overlap. With mode "path-ci" paths are compared
+// in lower case, for case-insensitive filesystems (the macOS default) where
+// Foo.DB and foo.db are the same file reached two ways. It is safe for
+// concurrent use by workers. Each path seen takes a slot from limit.
+func dedupPathFilter(mode string, limit *bufferLimit) matchFilter {
+	var mu sync.Mutex
+	seen := map[string]struct{}{}
+	return func(m matchResult) bool {
+		key := formatPath(m.Path)
+		if mode == "path-ci" {
+			key = strings.ToLower(key)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := seen[key]; ok {
+			return false
+		}
+		if !limit.take("--dedup-by") {
+			return false
+		}
+		seen[key] = struct{}{}
+		return true
+	}
+}
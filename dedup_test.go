@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDedupPathFilter(t *testing.T) {
+	paths := []string{
+		filepath.FromSlash("/Users/alice/Foo.DB"),
+		filepath.FromSlash("/users/alice/foo.db"),
+		filepath.FromSlash("/Users/alice/Foo.DB"),
+	}
+	for mode, want := range map[string]int{"path": 2, "path-ci": 1} {
+		filter := dedupPathFilter(mode, nil)
+		kept := 0
+		for _, p := range paths {
+			if keepMatch(matchResult{Path: p}, []matchFilter{filter}) {
+				kept++
+			}
+		}
+		if kept != want {
+			t.Fatalf("--dedup-by=%s kept %d matches, want %d", mode, kept, want)
+		}
+	}
+}
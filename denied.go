@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// deniedPaths collects paths skipped because of permission errors, for
+// --report-permission-denied.
+type deniedPaths struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (d *deniedPaths) add(path string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.paths = append(d.paths, path)
+	d.mu.Unlock()
+}
+
+// report writes the sorted list under a header, or nothing if no path was
+// denied.
+func (d *deniedPaths) report(w io.Writer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.paths) == 0 {
+		return
+	}
+	sort.Strings(d.paths)
+	fmt.Fprintln(w, "permission denied:")
+	for _, p := range d.paths {
+		fmt.Fprintf(w, "  %s\n", formatPath(p))
+	}
+}
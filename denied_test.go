@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportPermissionDenied(t *testing.T) {
+	root := t.TempDir()
+	secret := filepath.Join(root, "secret.db")
+	for _, p := range []string{secret, filepath.Join(root, "open.db")} {
+		if err := os.WriteFile(p, sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+
+	orig := openFile
+	t.Cleanup(func() { openFile = orig })
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		if name == secret {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+		}
+		return orig(name, flag, perm)
+	}
+
+	denied := &deniedPaths{}
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Denied: denied}
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	for range matches {
+	}
+	for err := range errs {
+		t.Fatalf("permission errors should not be reported as warnings, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	denied.report(&buf)
+	out := buf.String()
+	if !strings.HasPrefix(out, "permission denied:\n") {
+		t.Fatalf("expected header, got:\n%s", out)
+	}
+	if !strings.Contains(out, "  "+secret+"\n") || strings.Contains(out, "open.db") {
+		t.Fatalf("expected only %s to be listed, got:\n%s", secret, out)
+	}
+}
+
+func TestScanObjectsReportsPermissionDenied(t *testing.T) {
+	objects := []remoteObject{
+		{URI: "sftp://host/data/secret.db", Size: 100},
+		{URI: "sftp://host/data/open.db", Size: 100},
+	}
+	list := func(ctx context.Context, yield func(remoteObject) error) error {
+		for _, obj := range objects {
+			if err := yield(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	readHead := func(ctx context.Context, obj remoteObject, n int64) ([]byte, error) {
+		if strings.Contains(obj.URI, "secret") {
+			return nil, &fs.PathError{Op: "open", Path: obj.URI, Err: fs.ErrPermission}
+		}
+		return sqliteMagic, nil
+	}
+	denied := &deniedPaths{}
+	opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Denied: denied}
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	if err := scanObjects(context.Background(), list, readHead, opts, matches, errs); err != nil {
+		t.Fatalf("scanObjects: %v", err)
+	}
+	for range matches {
+	}
+	for err := range errs {
+		t.Fatalf("permission errors should not be reported as warnings, got %v", err)
+	}
+	var buf bytes.Buffer
+	denied.report(&buf)
+	if out := buf.String(); !strings.Contains(out, "  sftp://host/data/secret.db\n") || strings.Contains(out, "open.db") {
+		t.Fatalf("expected only the unreadable object to be listed, got:\n%s", out)
+	}
+}
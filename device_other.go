@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// deviceID is unavailable here, so --one-file-system has no effect.
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
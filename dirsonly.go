@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// dirAtDepth returns the ancestor of path that lies depth levels below the
+// root it was found under, or path's own directory when that is shallower.
+// Depth 0 is the root itself.
+func dirAtDepth(roots []string, path string, depth int) string {
+	dir := filepath.Dir(path)
+	root := ""
+	for _, r := range roots {
+		if (dir == r || strings.HasPrefix(dir, strings.TrimSuffix(r, string(filepath.Separator))+string(filepath.Separator))) && len(r) > len(root) {
+			root = r
+		}
+	}
+	if root == "" {
+		return dir
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return root
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return filepath.Join(append([]string{root}, parts...)...)
+}
+
+// reportDirsOnly implements --report-dirs-only: it replaces the matches
+// from in with the directories containing them, cut to depth levels below
+// their root, and forwards each directory once, as soon as its first
+// database is found. Each directory reported takes a slot from limit.
+func reportDirsOnly(in <-chan matchResult, roots []string, depth int, limit *bufferLimit) <-chan matchResult {
+	out := make(chan matchResult, cap(in))
+	go func() {
+		defer close(out)
+		seen := make(map[string]struct{})
+		for m := range in {
+			dir := dirAtDepth(roots, m.Path, depth)
+			if _, ok := seen[dir]; ok {
+				continue
+			}
+			if !limit.take("--report-dirs-only") {
+				continue
+			}
+			seen[dir] = struct{}{}
+			out <- matchResult{Path: dir, Label: m.Label}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDirAtDepth(t *testing.T) {
+	roots := []string{"/home", "/home/alice/special", "/srv/"}
+	for _, tc := range []struct {
+		path  string
+		depth int
+		want  string
+	}{
+		{"/home/alice/projects/myapp/db.sqlite", 2, "/home/alice/projects"},
+		{"/home/alice/projects/myapp/db.sqlite", 1, "/home/alice"},
+		{"/home/alice/projects/myapp/db.sqlite", 0, "/home"},
+		{"/home/alice/projects/myapp/db.sqlite", 9, "/home/alice/projects/myapp"},
+		{"/home/top.db", 2, "/home"},
+		{"/home/alice/special/a/b/c.db", 1, "/home/alice/special/a"},
+		{"/homework/x/y.db", 1, "/homework/x"},
+		{"/srv/app/data/z.db", 1, "/srv/app"},
+	} {
+		got := dirAtDepth(roots, filepath.FromSlash(tc.path), tc.depth)
+		if got != filepath.FromSlash(tc.want) {
+			t.Fatalf("dirAtDepth(%q, %d) = %q, want %q", tc.path, tc.depth, got, tc.want)
+		}
+	}
+}
+
+func TestReportDirsOnlyDeduplicates(t *testing.T) {
+	in := make(chan matchResult, 4)
+	for _, p := range []string{"/r/a/one.db", "/r/a/sub/two.db", "/r/b/three.db", "/r/four.db"} {
+		in <- matchResult{Path: p, Size: 123}
+	}
+	close(in)
+
+	var got []string
+	for m := range reportDirsOnly(in, []string{"/r"}, 1, nil) {
+		got = append(got, m.Path)
+	}
+	want := []string{"/r/a", "/r/b", "/r"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// scanConfig describes how a scan was run for --echo-config: the version,
+// the resolved roots, the number of header bytes probed per file and the
+// effective value of every flag, whether it came from the command line, a
+// config file, the environment or its default. Remote scans pass no roots;
+// their target is in the flags.
+func scanConfig(flags *pflag.FlagSet, roots []string) map[string]any {
+	values := map[string]string{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		switch f.Name {
+		case "help", "echo-config":
+			return
+		}
+		values[f.Name] = f.Value.String()
+	})
+	config := map[string]any{
+		"version":      version,
+		"header_bytes": sqliteHeaderSize,
+		"flags":        values,
+	}
+	if len(roots) > 0 {
+		config["roots"] = roots
+	}
+	return config
+}
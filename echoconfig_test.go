@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestScanConfigInJSONOutput(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("workers", 8, "")
+	flags.Bool("hash", false, "")
+	flags.Bool("echo-config", false, "")
+	if err := flags.Parse([]string{"--workers", "3", "--hash", "--echo-config"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts := outputOptions{JSON: true, Config: scanConfig(flags, []string{"/srv/data"})}
+	matches := make(chan matchResult, 1)
+	matches <- matchResult{Path: "/srv/data/app.db"}
+	close(matches)
+	var buf strings.Builder
+	streamMatches(context.Background(), &buf, matches, opts)
+
+	var doc struct {
+		Config struct {
+			Roots       []string          `json:"roots"`
+			HeaderBytes int               `json:"header_bytes"`
+			Flags       map[string]string `json:"flags"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	c := doc.Config
+	if len(c.Roots) != 1 || c.Roots[0] != "/srv/data" || c.HeaderBytes != sqliteHeaderSize {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	if c.Flags["workers"] != "3" || c.Flags["hash"] != "true" {
+		t.Fatalf("expected set flags in config, got %v", c.Flags)
+	}
+	if _, ok := c.Flags["echo-config"]; ok {
+		t.Fatalf("--echo-config should not record itself: %v", c.Flags)
+	}
+}
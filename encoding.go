@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// outputEncodings maps --encoding names to their encoders. A nil entry
+// means output is written unchanged (UTF-8).
+var outputEncodings = map[string]encoding.Encoding{
+	"utf8":        nil,
+	"latin1":      charmap.ISO8859_1,
+	"windows1252": charmap.Windows1252,
+	"utf16le":     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+}
+
+// encodeWriter wraps w so text written to it is converted to the named
+// encoding. Characters the target cannot represent are replaced with the
+// encoding's substitute character instead of failing. The returned closer
+// flushes the converter and must be called before w is flushed.
+func encodeWriter(w io.Writer, name string) (io.Writer, func() error, error) {
+	enc, ok := outputEncodings[strings.ToLower(strings.ReplaceAll(name, "-", ""))]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown encoding %q: must be utf8, latin1, windows1252, or utf16le", name)
+	}
+	if enc == nil {
+		return w, func() error { return nil }, nil
+	}
+	tw := transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder()))
+	return tw, tw.Close, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeWriterLatin1(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "données-é")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	dbPath := filepath.Join(dir, "café.db")
+
+	var buf bytes.Buffer
+	w, closeEnc, err := encodeWriter(&buf, "latin1")
+	if err != nil {
+		t.Fatalf("encodeWriter: %v", err)
+	}
+	matches := make(chan matchResult, 1)
+	matches <- matchResult{Path: dbPath}
+	close(matches)
+	streamMatches(context.Background(), w, matches, outputOptions{})
+	if err := closeEnc(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	out := buf.Bytes()
+	if bytes.Contains(out, []byte("é")) {
+		t.Fatalf("expected no UTF-8 sequences in latin1 output, got %q", out)
+	}
+	if !bytes.Contains(out, []byte("donn\xe9es-\xe9")) || !bytes.Contains(out, []byte("caf\xe9.db\n")) {
+		t.Fatalf("expected latin1-encoded é (0xE9) in output, got %q", out)
+	}
+}
+
+func TestEncodeWriterUTF16LE(t *testing.T) {
+	var buf bytes.Buffer
+	w, closeEnc, err := encodeWriter(&buf, "utf16le")
+	if err != nil {
+		t.Fatalf("encodeWriter: %v", err)
+	}
+	w.Write([]byte("é\n"))
+	closeEnc()
+	if want := []byte{0xe9, 0x00, '\n', 0x00}; !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("expected %x, got %x", want, buf.Bytes())
+	}
+}
+
+func TestEncodeWriterReplacesUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	w, closeEnc, err := encodeWriter(&buf, "latin1")
+	if err != nil {
+		t.Fatalf("encodeWriter: %v", err)
+	}
+	if _, err := w.Write([]byte("日本.db")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	closeEnc()
+	if got := buf.String(); got != "\x1a\x1a.db" {
+		t.Fatalf("expected substitute characters, got %q", got)
+	}
+
+	if _, _, err := encodeWriter(&buf, "ebcdic"); err == nil {
+		t.Fatalf("expected error for unknown encoding")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encrypted output (--encrypt-output) is a random 12-byte nonce followed
+// by the plaintext cut into encryptChunkSize pieces, each sealed with
+// AES-256-GCM. A chunk's nonce is the base nonce with its last four bytes
+// XORed with the chunk number, and the last chunk is sealed with the
+// additional data "final", so reordered, dropped or truncated chunks fail
+// to decrypt. Every stream ends with a final chunk, which may be empty.
+const encryptChunkSize = 64 << 10
+
+var finalChunkAD = []byte("final")
+
+// readKeyFile reads an AES-256 key from path: either 32 raw bytes or 64
+// hex digits, as produced by "openssl rand -hex 32".
+func readKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 32 {
+		return b, nil
+	}
+	if key, err := hex.DecodeString(string(bytes.TrimSpace(b))); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: key must be 32 raw bytes or 64 hex digits", path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce for chunk n of a stream.
+func chunkNonce(base []byte, n uint32) []byte {
+	nonce := bytes.Clone(base)
+	binary.BigEndian.PutUint32(nonce[8:], binary.BigEndian.Uint32(nonce[8:])^n)
+	return nonce
+}
+
+// encryptWriter encrypts everything written to it onto w. Close seals the
+// final chunk but leaves w open.
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	nonce []byte
+	n     uint32
+	buf   []byte
+}
+
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(nonce); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, nonce: nonce}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	// Hold back at least one byte so the last chunk is only sealed, as
+	// final, by Close.
+	for len(e.buf) > encryptChunkSize {
+		if err := e.seal(e.buf[:encryptChunkSize], nil); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[encryptChunkSize:]
+	}
+	return len(p), nil
+}
+
+func (e *encryptWriter) seal(chunk, ad []byte) error {
+	if e.n == ^uint32(0) {
+		return errors.New("encrypted output too large")
+	}
+	_, err := e.w.Write(e.aead.Seal(nil, chunkNonce(e.nonce, e.n), chunk, ad))
+	e.n++
+	return err
+}
+
+func (e *encryptWriter) Close() error {
+	err := e.seal(e.buf, finalChunkAD)
+	e.buf = nil
+	return err
+}
+
+// decryptStream reverses encryptWriter, writing the plaintext of r to w.
+// Nothing from a chunk is written until it has been authenticated.
+func decryptStream(w io.Writer, r io.Reader, key []byte) error {
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return errors.New("input too short to be encrypted output")
+	}
+	br := bufio.NewReader(r)
+	chunk := make([]byte, encryptChunkSize+aead.Overhead())
+	for n := uint32(0); ; n++ {
+		got, err := io.ReadFull(br, chunk)
+		final := errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+		if err != nil && !final {
+			return err
+		}
+		if !final {
+			if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+				final = true
+			}
+		}
+		var ad []byte
+		if final {
+			ad = finalChunkAD
+		}
+		plain, err := aead.Open(nil, chunkNonce(nonce, n), chunk[:got], ad)
+		if err != nil {
+			return errors.New("decryption failed: wrong key, or the output was modified or truncated")
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// runDecrypt implements "sqlite-scanner decrypt KEYFILE [INPUT]", writing
+// the plaintext of INPUT, or stdin, to stdout. It returns the exit code.
+func runDecrypt(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: sqlite-scanner decrypt KEYFILE [INPUT]")
+		return 2
+	}
+	key, err := readKeyFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
+		return 2
+	}
+	var in io.Reader = os.Stdin
+	if len(args) == 2 {
+		f, err := os.Open(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	out := bufio.NewWriter(os.Stdout)
+	err = decryptStream(out, in, key)
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
+		return 1
+	}
+	return 0
+}
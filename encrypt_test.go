@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptOutputRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	for _, size := range []int{0, 10, encryptChunkSize, 3*encryptChunkSize + 123} {
+		plain := bytes.Repeat([]byte("/data/app.db\n"), size/13+1)[:size]
+		var enc bytes.Buffer
+		w, err := newEncryptWriter(&enc, key)
+		if err != nil {
+			t.Fatalf("newEncryptWriter: %v", err)
+		}
+		// Write in odd-sized pieces to cross chunk boundaries.
+		for rest := plain; len(rest) > 0; {
+			n := min(len(rest), 1000)
+			w.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if bytes.Contains(enc.Bytes(), []byte("app.db")) {
+			t.Fatalf("%d bytes: plaintext visible in encrypted output", size)
+		}
+
+		var dec bytes.Buffer
+		if err := decryptStream(&dec, bytes.NewReader(enc.Bytes()), key); err != nil {
+			t.Fatalf("%d bytes: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(dec.Bytes(), plain) {
+			t.Fatalf("%d bytes: round trip mismatch", size)
+		}
+
+		// Dropping the final chunk must not pass for a shorter file.
+		if size > encryptChunkSize {
+			cut := enc.Len() - (size%encryptChunkSize + 16)
+			if err := decryptStream(&bytes.Buffer{}, bytes.NewReader(enc.Bytes()[:cut]), key); err == nil {
+				t.Fatalf("%d bytes: truncated output decrypted without error", size)
+			}
+		}
+		wrong := bytes.Repeat([]byte{8}, 32)
+		if err := decryptStream(&bytes.Buffer{}, bytes.NewReader(enc.Bytes()), wrong); err == nil {
+			t.Fatalf("%d bytes: decrypted with the wrong key", size)
+		}
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	raw := filepath.Join(dir, "raw.key")
+	os.WriteFile(raw, bytes.Repeat([]byte{1}, 32), 0o600)
+	hexKey := filepath.Join(dir, "hex.key")
+	os.WriteFile(hexKey, []byte(strings.Repeat("01", 32)+"\n"), 0o600)
+	short := filepath.Join(dir, "short.key")
+	os.WriteFile(short, []byte("too short"), 0o600)
+
+	for _, p := range []string{raw, hexKey} {
+		key, err := readKeyFile(p)
+		if err != nil || !bytes.Equal(key, bytes.Repeat([]byte{1}, 32)) {
+			t.Fatalf("%s: got %x, %v", p, key, err)
+		}
+	}
+	if _, err := readKeyFile(short); err == nil {
+		t.Fatalf("expected a short key to be rejected")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// envVarName maps a flag name to its environment variable, e.g. with prefix
+// SQLITE_SCANNER the flag --log-level becomes SQLITE_SCANNER_LOG_LEVEL.
+func envVarName(prefix, flag string) string {
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnv sets every flag not given on the command line from its
+// prefixed environment variable, if present.
+func applyEnv(flags *pflag.FlagSet, prefix string) error {
+	var firstErr error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || firstErr != nil {
+			return
+		}
+		name := envVarName(prefix, f.Name)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := flags.Set(f.Name, v); err != nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	})
+	return firstErr
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("SQLITE_SCANNER_WORKERS", "7")
+	t.Setenv("SQLITE_SCANNER_JSON", "true")
+	t.Setenv("SQLITE_SCANNER_LOG_LEVEL", "error")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	workers := flags.Int("workers", 1, "")
+	jsonOut := flags.Bool("json", false, "")
+	logLevel := flags.String("log-level", "warn", "")
+	path := flags.String("path", ".", "")
+	if err := flags.Parse([]string{"--log-level", "debug"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if err := applyEnv(flags, "SQLITE_SCANNER"); err != nil {
+		t.Fatalf("applyEnv: %v", err)
+	}
+	if *workers != 7 || !*jsonOut {
+		t.Fatalf("expected env values workers=7 json=true, got %d %v", *workers, *jsonOut)
+	}
+	if *logLevel != "debug" {
+		t.Fatalf("expected command-line flag to beat env, got %q", *logLevel)
+	}
+	if *path != "." {
+		t.Fatalf("expected unset env var to leave default, got %q", *path)
+	}
+}
+
+func TestApplyEnvInvalidValue(t *testing.T) {
+	t.Setenv("APP_WORKERS", "lots")
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("workers", 1, "")
+	if err := applyEnv(flags, "APP"); err == nil {
+		t.Fatalf("expected error for invalid env value")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// etcdBatchSize is how many puts go in one transaction; etcd rejects
+// transactions of more than 128 operations by default.
+const etcdBatchSize = 100
+
+// etcdTimeout bounds connecting to the --etcd-endpoints and each request.
+const etcdTimeout = 5 * time.Second
+
+// etcdPut is one key written by the etcd sink.
+type etcdPut struct {
+	Key, Value string
+}
+
+// etcdKV is the part of etcd the sink uses: a lease for --etcd-ttl and
+// transactions of puts attached to it (lease 0 means none).
+type etcdKV interface {
+	grant(ctx context.Context, ttl time.Duration) (int64, error)
+	commit(ctx context.Context, puts []etcdPut, lease int64) error
+	Close() error
+}
+
+// etcdMatch is the value written under PREFIX/matches/PATH.
+type etcdMatch struct {
+	Size    int64  `json:"size"`
+	FoundAt string `json:"found_at"`
+}
+
+// etcdSink is a matchSink writing every match to etcd (--etcd-prefix) as
+// PREFIX/matches/PATH = {"size":N,"found_at":"RFC3339"}, in transactions
+// of etcdBatchSize puts. Close commits the rest and PREFIX/_summary =
+// {"total":N}; keeping matches under their own subprefix means no path can
+// collide with the summary. With --etcd-ttl every key is attached to one
+// lease that expires after the TTL.
+type etcdSink struct {
+	ctx    context.Context
+	kv     etcdKV
+	prefix string
+	ttl    time.Duration
+	now    func() time.Time
+	lease  int64
+	queued []etcdPut
+	total  int64
+	// dropped counts the puts in transactions that failed.
+	dropped int
+}
+
+// newEtcdSink connects to endpoints, over TLS when any of cert, key or ca
+// is set.
+func newEtcdSink(ctx context.Context, prefix string, endpoints []string, ttl time.Duration, cert, key, ca string) (*etcdSink, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("--etcd-ttl cannot be negative")
+	}
+	if ttl > 0 && ttl < time.Second {
+		return nil, fmt.Errorf("--etcd-ttl must be at least a second")
+	}
+	if (cert == "") != (key == "") {
+		return nil, fmt.Errorf("--etcd-cert and --etcd-key must be used together")
+	}
+	var tlsConfig *tls.Config
+	if cert != "" || ca != "" {
+		info := transport.TLSInfo{CertFile: cert, KeyFile: key, TrustedCAFile: ca}
+		c, err := info.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("--etcd-cert/--etcd-key/--etcd-ca: %w", err)
+		}
+		tlsConfig = c
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdTimeout,
+		TLS:         tlsConfig,
+		Context:     context.WithoutCancel(ctx),
+		// Failures are reported by the sink's errors instead.
+		Logger: zap.NewNop(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("--etcd-endpoints: %w", err)
+	}
+	return &etcdSink{
+		ctx:    ctx,
+		kv:     etcdClient{client},
+		prefix: prefix,
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
+}
+
+// key joins the prefix and name with a single slash.
+func (s *etcdSink) key(name string) string {
+	return strings.TrimSuffix(s.prefix, "/") + "/" + strings.TrimPrefix(name, "/")
+}
+
+// matchKey is where a match for path is written: PREFIX/matches/PATH.
+func (s *etcdSink) matchKey(path string) string {
+	return s.key("matches/" + strings.TrimPrefix(path, "/"))
+}
+
+func (s *etcdSink) Add(m matchResult) error {
+	body, err := json.Marshal(etcdMatch{Size: m.Size, FoundAt: s.now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	s.total++
+	s.queued = append(s.queued, etcdPut{Key: s.matchKey(formatPath(m.Path)), Value: string(body)})
+	if len(s.queued) < etcdBatchSize {
+		return nil
+	}
+	return s.flush(s.ctx)
+}
+
+// flush commits the queued puts in one transaction, granting the
+// --etcd-ttl lease first if that has not happened yet. A failed batch is
+// dropped and counted, like --redis does.
+func (s *etcdSink) flush(ctx context.Context) error {
+	if len(s.queued) == 0 {
+		return nil
+	}
+	puts := s.queued
+	s.queued = nil
+	if s.ttl > 0 && s.lease == 0 {
+		lease, err := s.kv.grant(ctx, s.ttl)
+		if err != nil {
+			s.dropped += len(puts)
+			return fmt.Errorf("--etcd-ttl: %w", err)
+		}
+		s.lease = lease
+	}
+	if err := s.kv.commit(ctx, puts, s.lease); err != nil {
+		s.dropped += len(puts)
+		return fmt.Errorf("--etcd-prefix: writing %d keys: %w", len(puts), err)
+	}
+	return nil
+}
+
+// Close commits what is still queued together with the summary key, even
+// when the scan was interrupted, so it does not use the scan's context. It
+// fails if any batch was dropped along the way.
+func (s *etcdSink) Close() error {
+	ctx := context.WithoutCancel(s.ctx)
+	summary, _ := json.Marshal(struct {
+		Total int64 `json:"total"`
+	}{s.total})
+	s.queued = append(s.queued, etcdPut{Key: s.key("_summary"), Value: string(summary)})
+	err := s.flush(ctx)
+	if cerr := s.kv.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && s.dropped > 0 {
+		err = fmt.Errorf("--etcd-prefix: %d keys could not be written", s.dropped)
+	}
+	return err
+}
+
+// etcdClient implements etcdKV with one clientv3 client, whose gRPC
+// connection is shared by every request.
+type etcdClient struct {
+	client *clientv3.Client
+}
+
+func (c etcdClient) grant(ctx context.Context, ttl time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+	resp, err := c.client.Grant(ctx, int64(ttl/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return int64(resp.ID), nil
+}
+
+func (c etcdClient) commit(ctx context.Context, puts []etcdPut, lease int64) error {
+	ops := make([]clientv3.Op, len(puts))
+	for i, p := range puts {
+		var opts []clientv3.OpOption
+		if lease != 0 {
+			opts = append(opts, clientv3.WithLease(clientv3.LeaseID(lease)))
+		}
+		ops[i] = clientv3.OpPut(p.Key, p.Value, opts...)
+	}
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+	_, err := c.client.Txn(ctx).Then(ops...).Commit()
+	return err
+}
+
+func (c etcdClient) Close() error { return c.client.Close() }
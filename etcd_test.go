@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingEtcd collects the transactions an etcdSink commits.
+type recordingEtcd struct {
+	txns   [][]etcdPut
+	leases []int64
+	grants []time.Duration
+	closed bool
+	// err, when set, fails every commit.
+	err error
+}
+
+func (r *recordingEtcd) grant(_ context.Context, ttl time.Duration) (int64, error) {
+	r.grants = append(r.grants, ttl)
+	return 42, nil
+}
+
+func (r *recordingEtcd) commit(_ context.Context, puts []etcdPut, lease int64) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.txns = append(r.txns, puts)
+	r.leases = append(r.leases, lease)
+	return nil
+}
+
+func (r *recordingEtcd) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestEtcdSinkBatches(t *testing.T) {
+	fixed := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+	rec := &recordingEtcd{}
+	sink := &etcdSink{ctx: context.Background(), kv: rec, prefix: "/sqlite-scanner/scans/2024-01-01", ttl: time.Hour, now: func() time.Time { return fixed }}
+	for i := 0; i < etcdBatchSize+3; i++ {
+		if err := sink.Add(matchResult{Path: "/data/app.db", Size: 4096}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rec.txns) != 1 || len(rec.txns[0]) != etcdBatchSize {
+		t.Fatalf("expected one full transaction before Close, got %d", len(rec.txns))
+	}
+	if err := sink.Close(); err != nil || !rec.closed {
+		t.Fatalf("expected the client to be closed, got %v", err)
+	}
+	if len(rec.txns) != 2 || len(rec.txns[1]) != 4 {
+		t.Fatalf("expected Close to commit the last 3 matches and the summary, got %v", rec.txns)
+	}
+	if len(rec.grants) != 1 || rec.grants[0] != time.Hour || rec.leases[0] != 42 || rec.leases[1] != 42 {
+		t.Fatalf("expected every put on one one-hour lease, got grants %v leases %v", rec.grants, rec.leases)
+	}
+
+	first := rec.txns[0][0]
+	if first.Key != "/sqlite-scanner/scans/2024-01-01/matches/data/app.db" {
+		t.Fatalf("unexpected key %q", first.Key)
+	}
+	var m etcdMatch
+	if err := json.Unmarshal([]byte(first.Value), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Size != 4096 || m.FoundAt != "2024-01-01T09:30:00Z" {
+		t.Fatalf("unexpected value %+v", m)
+	}
+	summary := rec.txns[1][3]
+	if summary.Key != "/sqlite-scanner/scans/2024-01-01/_summary" || summary.Value != `{"total":103}` {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
+
+func TestEtcdSinkWithoutTTL(t *testing.T) {
+	rec := &recordingEtcd{}
+	sink := &etcdSink{ctx: context.Background(), kv: rec, prefix: "scans/", now: time.Now}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.grants) != 0 || rec.leases[0] != 0 {
+		t.Fatalf("expected no lease without --etcd-ttl, got %v", rec.grants)
+	}
+	if len(rec.txns) != 1 || rec.txns[0][0].Key != "scans/_summary" || rec.txns[0][0].Value != `{"total":0}` {
+		t.Fatalf("expected only the summary, got %v", rec.txns)
+	}
+}
+
+func TestEtcdSinkMatchCannotOverwriteSummary(t *testing.T) {
+	rec := &recordingEtcd{}
+	sink := &etcdSink{ctx: context.Background(), kv: rec, prefix: "scans", now: time.Now}
+	if err := sink.Add(matchResult{Path: "/_summary", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.txns) != 1 || rec.txns[0][0].Key != "scans/matches/_summary" || rec.txns[0][1].Key != "scans/_summary" {
+		t.Fatalf("expected the match under matches/ and the summary apart, got %v", rec.txns)
+	}
+}
+
+func TestEtcdSinkFailsCloseAfterDroppedBatch(t *testing.T) {
+	rec := &recordingEtcd{err: errors.New("no leader")}
+	sink := &etcdSink{ctx: context.Background(), kv: rec, prefix: "scans", now: time.Now}
+	var addErr error
+	for i := 0; i < etcdBatchSize; i++ {
+		addErr = sink.Add(matchResult{Path: "/data/a.db"})
+	}
+	if addErr == nil {
+		t.Fatal("expected the failed transaction to be reported")
+	}
+	rec.err = nil
+	err := sink.Close()
+	if err == nil || !strings.Contains(err.Error(), "100 keys could not be written") {
+		t.Fatalf("expected Close to report the dropped batch, got %v", err)
+	}
+	if len(rec.txns) != 1 || rec.txns[0][0].Key != "scans/_summary" {
+		t.Fatalf("expected the summary to still be written, got %v", rec.txns)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// execSink runs a command for every match (--exec), like find -exec. At most
+// jobs commands run at once; failures are logged and never stop the scan.
+type execSink struct {
+	ctx    context.Context
+	argv   []string
+	logger *slog.Logger
+	sem    chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newExecSink returns a sink running argv per match. The --exec template is
+// split on whitespace by the caller; there is no shell quoting, so wrap the
+// command in "sh -c" when it needs pipes or quoted arguments.
+func newExecSink(ctx context.Context, argv []string, jobs int, logger *slog.Logger) *execSink {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &execSink{
+		ctx:    ctx,
+		argv:   argv,
+		logger: logger,
+		sem:    make(chan struct{}, jobs),
+	}
+}
+
+// execArgs substitutes path for every {} in argv, or appends it when the
+// template has no placeholder.
+func execArgs(argv []string, path string) []string {
+	args := make([]string, 0, len(argv)+1)
+	replaced := false
+	for _, a := range argv {
+		if strings.Contains(a, "{}") {
+			a = strings.ReplaceAll(a, "{}", path)
+			replaced = true
+		}
+		args = append(args, a)
+	}
+	if !replaced {
+		args = append(args, path)
+	}
+	return args
+}
+
+func (s *execSink) Add(m matchResult) error {
+	args := execArgs(s.argv, formatPath(m.Path))
+	s.sem <- struct{}{}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.sem }()
+		cmd := exec.CommandContext(s.ctx, args[0], args[1:]...)
+		// The results own stdout, which may be JSON, gzip or encrypted,
+		// so the command's output goes to stderr alongside its errors.
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			s.logger.Warn("--exec command failed", "path", m.Path, "error", err)
+		}
+	}()
+	return nil
+}
+
+// Close waits for running commands to finish.
+func (s *execSink) Close() error {
+	s.wg.Wait()
+	return nil
+}
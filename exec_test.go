@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExecArgs(t *testing.T) {
+	got := execArgs([]string{"cp", "{}", "/backup/"}, "/a b.db")
+	if want := []string{"cp", "/a b.db", "/backup/"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	got = execArgs([]string{"ls", "-l"}, "/x.db")
+	if want := []string{"ls", "-l", "/x.db"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected path appended, got %v", got)
+	}
+}
+
+func TestExecSinkRunsOncePerMatch(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "ran.txt")
+	argv := []string{"sh", "-c", `echo "$1" >> "$2"`, "_", "{}", logPath}
+	sink := newExecSink(context.Background(), argv, 2, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	paths := []string{filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db"), filepath.Join(dir, "c.db")}
+	for _, p := range paths {
+		if err := sink.Add(matchResult{Path: p}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	got := strings.Fields(string(b))
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, paths) {
+		t.Fatalf("expected one run per match %v, got %v", paths, got)
+	}
+}
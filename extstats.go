@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// noExtension is the --ext-stats key for files without an extension.
+const noExtension = "(none)"
+
+// extStats is a matchSink counting matches per file extension for
+// --ext-stats, or with newFileExtStats every file the walk finds for
+// --extensions-report. It is only read once the scan has finished.
+type extStats struct {
+	heading string
+	mu      sync.Mutex
+	counts  map[string]int
+}
+
+func newExtStats() *extStats {
+	return &extStats{heading: "matches by extension:", counts: map[string]int{}}
+}
+
+func newFileExtStats() *extStats {
+	return &extStats{heading: "files by extension:", counts: map[string]int{}}
+}
+
+// extensionOf returns the lower-cased extension of path, treating dot
+// files such as ".bashrc" as having none.
+func extensionOf(path string) string {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	if ext == "" || ext == base {
+		return noExtension
+	}
+	return strings.ToLower(ext)
+}
+
+// addPath counts path. It is safe for concurrent use by the walkers.
+func (s *extStats) addPath(path string) {
+	s.mu.Lock()
+	s.counts[extensionOf(path)]++
+	s.mu.Unlock()
+}
+
+func (s *extStats) Add(m matchResult) error {
+	s.addPath(m.Path)
+	return nil
+}
+
+func (s *extStats) Close() error { return nil }
+
+// sorted returns the extensions, most common first.
+func (s *extStats) sorted() []string {
+	exts := make([]string, 0, len(s.counts))
+	for ext := range s.counts {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if s.counts[exts[i]] != s.counts[exts[j]] {
+			return s.counts[exts[i]] > s.counts[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+	return exts
+}
+
+// report prints the counts to w, most common extension first.
+func (s *extStats) report(w io.Writer) {
+	fmt.Fprintln(w, s.heading)
+	for _, ext := range s.sorted() {
+		fmt.Fprintf(w, "  %-10s %d\n", ext, s.counts[ext])
+	}
+}
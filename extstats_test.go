@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtStats(t *testing.T) {
+	s := newExtStats()
+	for _, p := range []string{"/a/x.db", "/a/y.DB", "/a/z.sqlite", "/a/noext", "/a/.hidden", "/a/w.sqlite3", "/b.d/x.db"} {
+		s.Add(matchResult{Path: p})
+	}
+	want := map[string]int{".db": 3, ".sqlite": 1, ".sqlite3": 1, noExtension: 2}
+	if len(s.counts) != len(want) {
+		t.Fatalf("expected %v, got %v", want, s.counts)
+	}
+	for ext, n := range want {
+		if s.counts[ext] != n {
+			t.Fatalf("expected %v, got %v", want, s.counts)
+		}
+	}
+
+	var buf bytes.Buffer
+	s.report(&buf)
+	wantReport := "matches by extension:\n  .db        3\n  (none)     2\n  .sqlite    1\n  .sqlite3   1\n"
+	if buf.String() != wantReport {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestStreamMatchesJSONExtStats(t *testing.T) {
+	s := newExtStats()
+	matches := make(chan matchResult, 2)
+	for _, p := range []string{"/data/a.db", "/data/b.sqlite"} {
+		m := matchResult{Path: p}
+		s.Add(m)
+		matches <- m
+	}
+	close(matches)
+
+	out := captureStdout(t, func() {
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, ExtStats: s})
+	})
+	var doc struct {
+		Entries  []map[string]any `json:"entries"`
+		ExtStats map[string]int   `json:"ext_stats"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON document: %v\n%s", err, out)
+	}
+	if len(doc.Entries) != 2 || doc.ExtStats[".db"] != 1 || doc.ExtStats[".sqlite"] != 1 {
+		t.Fatalf("unexpected document:\n%s", out)
+	}
+}
+
+func TestExtensionsReportCountsEveryFile(t *testing.T) {
+	root := t.TempDir()
+	files := map[string][]byte{
+		"app.db":          sqliteMagic,
+		"notes.txt":       []byte("hello"),
+		"readme.TXT":      []byte("hello"),
+		"sub/photo.jpg":   []byte("\xff\xd8\xff"),
+		"sub/Makefile":    []byte("all:"),
+		"sub/cache.db":    []byte("not really a database"),
+		"sub/deep/x.json": []byte("{}"),
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, content, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	exts := newFileExtStats()
+	matches := make(chan matchResult, 8)
+	errs := make(chan error, 8)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Extensions: exts}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	for range matches {
+	}
+
+	var buf bytes.Buffer
+	exts.report(&buf)
+	want := "files by extension:\n  .db        2\n  .txt       2\n  (none)     1\n  .jpg       1\n  .json      1\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
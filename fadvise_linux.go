@@ -0,0 +1,25 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const fadviseSupported = true
+
+// adviseSequential tells the kernel f will be read from the start, so it
+// can read ahead more aggressively. The advice is only a hint; errors are
+// ignored.
+func adviseSequential(f *os.File) {
+	unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+// adviseDontNeed drops f's pages from the page cache once it has been
+// checked, so a scan of millions of files does not evict the working set
+// of everything else on the machine.
+func adviseDontNeed(f *os.File) {
+	unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSQLiteMagicFadvise(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.db")
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 8192)...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{Fadvise: true, Hash: true})
+	if err != nil || !ok || res.Size != int64(len(content)) || res.SHA256 == "" {
+		t.Fatalf("expected a hashed match with fadvise, got %+v ok=%v err=%v", res, ok, err)
+	}
+}
+
+// BenchmarkScanFadvise scans a tree of small files with and without
+// --sparse. The difference it is meant to show, less page cache left
+// behind, is best measured on a cold filesystem with many more files
+// (e.g. 100k) while watching the Cached line of /proc/meminfo; in a warm
+// benchmark loop it mostly shows the cost of the extra system calls.
+func BenchmarkScanFadvise(b *testing.B) {
+	root := b.TempDir()
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 4096)...)
+	for i := 0; i < 2000; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%02d", i%20))
+		os.MkdirAll(dir, 0o755)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d.db", i)), content, 0o600); err != nil {
+			b.Fatalf("write: %v", err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, fadvise := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fadvise=%t", fadvise), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matches := make(chan matchResult, 64)
+				errs := make(chan error, 64)
+				opts := scanOptions{Workers: 4, Logger: logger, Check: checkOptions{Fadvise: fadvise}}
+				go func() {
+					for range matches {
+					}
+				}()
+				if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// fadviseSupported is false where posix_fadvise is unavailable to Go,
+// making --sparse an error.
+const fadviseSupported = false
+
+func adviseSequential(*os.File) {}
+
+func adviseDontNeed(*os.File) {}
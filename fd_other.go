@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+const fdFromSupported = false
+
+func openDirFD(fd int) (*os.Root, string, func(), error) {
+	return nil, "", nil, errors.New("--fd-from is only supported on Unix")
+}
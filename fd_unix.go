@@ -0,0 +1,44 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const fdFromSupported = true
+
+// openDirFD turns the inherited directory descriptor fd into an os.Root
+// and a path to report matches under: the directory's path when the
+// platform exposes it through /proc, /dev/fd/N otherwise. The returned
+// function closes both the root and fd.
+func openDirFD(fd int) (*os.Root, string, func(), error) {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	if f == nil {
+		return nil, "", nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if !info.IsDir() {
+		return nil, "", nil, errors.New("file descriptor is not a directory")
+	}
+	// Reopening through /dev/fd yields the same directory the
+	// descriptor refers to, whatever its path now points at.
+	devPath := fmt.Sprintf("/dev/fd/%d", fd)
+	root, err := os.OpenRoot(devPath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	display := devPath
+	if p, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd)); err == nil {
+		display = p
+	}
+	return root, display, func() {
+		root.Close()
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,104 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanRootFromFD(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "app.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		t.Fatalf("open dir: %v", err)
+	}
+	defer d.Close()
+
+	root, display, closeRoot, err := openDirFD(int(d.Fd()))
+	if err != nil {
+		t.Fatalf("openDirFD: %v", err)
+	}
+	defer closeRoot()
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if err := scanRoot(context.Background(), root, display, opts, matches, errs); err != nil {
+		t.Fatalf("scanRoot: %v", err)
+	}
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []matchResult
+	for m := range matches {
+		got = append(got, m)
+	}
+	if len(got) != 1 || filepath.Base(got[0].Path) != "app.db" || got[0].Size != int64(len(sqliteMagic)) {
+		t.Fatalf("expected sub/app.db, got %+v", got)
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil && display != fmt.Sprintf("/dev/fd/%d", d.Fd()) && got[0].Path != filepath.Join(real, "sub", "app.db") {
+		t.Fatalf("expected the match under %s, got %s", real, got[0].Path)
+	}
+}
+
+func TestScanRootFromFDReportsPermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read every directory")
+	}
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "locked")
+	if err := os.Mkdir(locked, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(locked, "app.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0o755) })
+	d, err := os.Open(dir)
+	if err != nil {
+		t.Fatalf("open dir: %v", err)
+	}
+	defer d.Close()
+
+	root, display, closeRoot, err := openDirFD(int(d.Fd()))
+	if err != nil {
+		t.Fatalf("openDirFD: %v", err)
+	}
+	defer closeRoot()
+	denied := &deniedPaths{}
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Denied: denied}
+	if err := scanRoot(context.Background(), root, display, opts, matches, errs); err != nil {
+		t.Fatalf("scanRoot: %v", err)
+	}
+	for range matches {
+	}
+	for err := range errs {
+		t.Fatalf("permission errors should not be reported as warnings, got %v", err)
+	}
+	var buf bytes.Buffer
+	denied.report(&buf)
+	if !strings.Contains(buf.String(), "locked") {
+		t.Fatalf("expected the locked directory to be listed, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// scanRoot implements --fd-from: it walks the directory tree of root
+// through its fs.FS, so every open is resolved relative to the directory
+// that was handed over and cannot be redirected by a path swapped in
+// after the caller opened it. Matches are reported under display. Like
+// the remote scanners it reuses scanObjects, so it closes matches and
+// errs when done.
+func scanRoot(ctx context.Context, root *os.Root, display string, opts scanOptions, matches chan<- matchResult, errs chan<- error) error {
+	fsys := root.FS()
+	list := func(ctx context.Context, yield func(remoteObject) error) error {
+		return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					opts.Logger.Debug("skipping unreadable path", "path", path.Join(display, name))
+					opts.Denied.add(path.Join(display, name))
+					return nil
+				}
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				errs <- fmt.Errorf("%s: %w", path.Join(display, name), err)
+				return nil
+			}
+			return yield(remoteObject{URI: path.Join(display, name), Key: name, Size: info.Size(), ModTime: info.ModTime()})
+		})
+	}
+	readHead := func(ctx context.Context, obj remoteObject, n int64) ([]byte, error) {
+		f, err := fsys.Open(obj.Key)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		buf := make([]byte, n)
+		read, err := io.ReadFull(f, buf)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		}
+		return buf[:read], err
+	}
+	return scanObjects(ctx, list, readHead, opts, matches, errs)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// gcsAPI is the part of Cloud Storage used by scanGCSPrefix, so tests can
+// substitute a stub.
+type gcsAPI interface {
+	// list calls yield for every object in bucket whose name starts with
+	// prefix.
+	list(ctx context.Context, bucket, prefix string, yield func(remoteObject) error) error
+	// readHead returns the first n bytes of an object.
+	readHead(ctx context.Context, bucket, name string, n int64) ([]byte, error)
+}
+
+// gcsClient implements gcsAPI with the Cloud Storage client library.
+type gcsClient struct {
+	c *storage.Client
+}
+
+// newGCSClient authenticates with Application Default Credentials.
+func newGCSClient(ctx context.Context) (*gcsClient, error) {
+	c, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsClient{c: c}, nil
+}
+
+func (g *gcsClient) list(ctx context.Context, bucket, prefix string, yield func(remoteObject) error) error {
+	q := &storage.Query{Prefix: prefix}
+	if err := q.SetAttrSelection([]string{"Name", "Size", "Updated"}); err != nil {
+		return err
+	}
+	it := g.c.Bucket(bucket).Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := yield(remoteObject{Key: attrs.Name, Size: attrs.Size, ModTime: attrs.Updated}); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *gcsClient) readHead(ctx context.Context, bucket, name string, n int64) ([]byte, error) {
+	r, err := g.c.Bucket(bucket).Object(name).NewRangeReader(ctx, 0, n)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, n))
+}
+
+func (g *gcsClient) Close() error { return g.c.Close() }
+
+// scanGCSPrefix lists every object under a gs://bucket/prefix URI and
+// reports those starting with the SQLite magic, reading only their first
+// bytes with a range read. Sizes and mtimes come from the listing.
+func scanGCSPrefix(ctx context.Context, client gcsAPI, uri string, opts scanOptions, matches chan<- matchResult, errs chan<- error) error {
+	bucket, prefix, err := parseBucketURI(uri, "gs")
+	if err != nil {
+		close(matches)
+		close(errs)
+		return err
+	}
+	list := func(ctx context.Context, yield func(remoteObject) error) error {
+		return client.list(ctx, bucket, prefix, func(obj remoteObject) error {
+			obj.URI = "gs://" + bucket + "/" + obj.Key
+			return yield(obj)
+		})
+	}
+	readHead := func(ctx context.Context, obj remoteObject, n int64) ([]byte, error) {
+		return client.readHead(ctx, bucket, obj.Key, n)
+	}
+	return scanObjects(ctx, list, readHead, opts, matches, errs)
+}
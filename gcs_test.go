@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubGCS is an in-memory gcsAPI.
+type stubGCS struct {
+	bucket  string
+	objects map[string][]byte
+}
+
+func (s *stubGCS) list(ctx context.Context, bucket, prefix string, yield func(remoteObject) error) error {
+	if bucket != s.bucket {
+		return errors.New("storage: bucket doesn't exist")
+	}
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		obj := remoteObject{Key: name, Size: int64(len(s.objects[name])), ModTime: time.Unix(1700000000, 0)}
+		if err := yield(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *stubGCS) readHead(ctx context.Context, bucket, name string, n int64) ([]byte, error) {
+	data := s.objects[name]
+	return data[:min(n, int64(len(data)))], nil
+}
+
+func TestScanGCSPrefix(t *testing.T) {
+	client := &stubGCS{bucket: "backups", objects: map[string][]byte{
+		"nightly/app.db":   append(append([]byte{}, sqliteMagic...), make([]byte, 200)...),
+		"nightly/readme":   []byte("plain text that is long enough"),
+		"weekly/other.db":  sqliteMagic,
+		"nightly/sub/x.db": sqliteMagic,
+	}}
+
+	matches := make(chan matchResult, 10)
+	errs := make(chan error, 10)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if err := scanGCSPrefix(context.Background(), client, "gs://backups/nightly/", opts, matches, errs); err != nil {
+		t.Fatalf("scanGCSPrefix: %v", err)
+	}
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var lines []string
+	for m := range matches {
+		lines = append(lines, formatJSONLine(m, outputOptions{JSONL: true, ShowSize: true}))
+	}
+	sort.Strings(lines)
+	want := []string{
+		`{"path":"gs://backups/nightly/app.db","size":216}`,
+		`{"path":"gs://backups/nightly/sub/x.db","size":16}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(lines, "\n"))
+	}
+	for _, l := range lines {
+		if !json.Valid([]byte(l)) {
+			t.Fatalf("invalid JSON line %s", l)
+		}
+	}
+}
+
+func TestScanGCSPrefixListError(t *testing.T) {
+	matches := make(chan matchResult, 1)
+	errs := make(chan error, 1)
+	opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	err := scanGCSPrefix(context.Background(), &stubGCS{bucket: "other"}, "gs://missing/x", opts, matches, errs)
+	if err == nil {
+		t.Fatalf("expected listing error")
+	}
+	if _, ok := <-matches; ok {
+		t.Fatalf("expected matches to be closed")
+	}
+}
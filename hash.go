@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/zeebo/blake3"
+)
+
+// newHash returns the content hash selected by o: BLAKE3 with --blake3,
+// SHA-256 otherwise.
+func (o checkOptions) newHash() hash.Hash {
+	if o.Blake3 {
+		return blake3.New()
+	}
+	return sha256.New()
+}
+
+// setHash stores sum, computed by o.newHash, in the field for its
+// algorithm.
+func (m *matchResult) setHash(sum string, o checkOptions) {
+	if o.Blake3 {
+		m.Blake3 = sum
+	} else {
+		m.SHA256 = sum
+	}
+}
+
+// contentHash returns the digest identifying m's content, prefixed by its
+// algorithm for BLAKE3 so the two kinds never compare equal, or "" when
+// m was not hashed.
+func (m matchResult) contentHash() string {
+	if m.Blake3 != "" {
+		return "blake3:" + m.Blake3
+	}
+	return m.SHA256
+}
+
+// hashFile feeds h a file whose first len(head) bytes have already been
+// read into head, continuing from r's current offset. When max is positive
+// no more than max bytes in total are hashed. It returns the hex digest and
+// the number of extra bytes read.
+func hashFile(h hash.Hash, head []byte, r io.Reader, max int64) (string, int64, error) {
+	if max > 0 {
+		if int64(len(head)) >= max {
+			head, r = head[:max], eofReader{}
+		} else {
+			r = io.LimitReader(r, max-int64(len(head)))
+		}
+	}
+	h.Write(head)
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// eofReader is an empty reader.
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// uniqueContentFilter keeps the first match seen for each content hash and
+// drops later ones. Matches without a hash, or with a partial hash that
+// only covers a prefix shared by different files, are always kept. It is
+// safe for concurrent use by workers, so "first" means first to finish
+// checking. Each hash seen takes a slot from limit.
+func uniqueContentFilter(limit *bufferLimit) matchFilter {
+	var mu sync.Mutex
+	seen := map[string]struct{}{}
+	return func(m matchResult) bool {
+		sum := m.contentHash()
+		if sum == "" || m.HashPartial {
+			return true
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := seen[sum]; ok {
+			return false
+		}
+		if !limit.take("--unique-content") {
+			return false
+		}
+		seen[sum] = struct{}{}
+		return true
+	}
+}
+
+// keepStrategies are the values of --keep, choosing which path
+// --unique-content reports for each distinct hash.
+var keepStrategies = []string{"first", "shortest-path", "newest", "oldest"}
+
+// better reports whether a should be kept over b under strategy. Ties keep
+// the match seen first.
+func better(strategy string, a, b matchResult) bool {
+	switch strategy {
+	case "shortest-path":
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Path < b.Path
+	case "newest":
+		return a.ModTime.After(b.ModTime)
+	case "oldest":
+		return a.ModTime.Before(b.ModTime)
+	}
+	return false
+}
+
+// pickByContent implements --keep strategies other than "first": it
+// buffers every match, keeps the best one per hash and sends the winners
+// once in is closed, in the order their hash was first seen. Matches
+// without a full hash are passed through at once, as uniqueContentFilter
+// does. Each hash buffered takes a slot from limit; matches that do not get
+// one are dropped, as the scan is then being stopped.
+func pickByContent(in <-chan matchResult, strategy string, limit *bufferLimit) <-chan matchResult {
+	out := make(chan matchResult, cap(in))
+	go func() {
+		defer close(out)
+		var order []string
+		best := make(map[string]matchResult)
+		for m := range in {
+			sum := m.contentHash()
+			if sum == "" || m.HashPartial {
+				out <- m
+				continue
+			}
+			cur, ok := best[sum]
+			if !ok {
+				if !limit.take("--keep " + strategy) {
+					continue
+				}
+				order = append(order, sum)
+			}
+			if !ok || better(strategy, m, cur) {
+				best[sum] = m
+			}
+		}
+		for _, h := range order {
+			out <- best[h]
+		}
+	}()
+	return out
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/blake3"
+)
+
+func TestCheckSQLiteMagicHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.db")
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 5000)...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{Hash: true})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	sum := sha256.Sum256(content)
+	if want := hex.EncodeToString(sum[:]); res.SHA256 != want {
+		t.Fatalf("expected sha256 %s, got %s", want, res.SHA256)
+	}
+}
+
+func TestCheckSQLiteMagicBlake3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.db")
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 5000)...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{Hash: true, Blake3: true})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	sum := blake3.Sum256(content)
+	if want := hex.EncodeToString(sum[:]); res.Blake3 != want || res.SHA256 != "" {
+		t.Fatalf("expected only blake3 %s, got %+v", want, res)
+	}
+	opts := outputOptions{ShowBlake3: true}
+	if got := formatPlainMatch(res, opts); got != path+" blake3:"+res.Blake3 {
+		t.Fatalf("unexpected plain output %q", got)
+	}
+	if got := formatJSONLine(res, opts); !strings.Contains(got, `"blake3":"`+res.Blake3+`"`) || strings.Contains(got, "sha256") {
+		t.Fatalf("unexpected JSONL %s", got)
+	}
+	if (matchResult{SHA256: res.Blake3}).contentHash() == res.contentHash() {
+		t.Fatalf("a BLAKE3 digest must never equal a SHA-256 one")
+	}
+}
+
+// BenchmarkHashFile compares the throughput of the two --hash algorithms.
+// Run with -benchtime=1x and a larger size to reproduce multi-gigabyte
+// numbers; 64 MiB keeps the default run short.
+func BenchmarkHashFile(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "big.db")
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 64<<20)...)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		b.Fatalf("write db: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		opts checkOptions
+	}{
+		{"sha256", checkOptions{Hash: true, SkipStat: true}},
+		{"blake3", checkOptions{Hash: true, Blake3: true, SkipStat: true}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, _, err := checkSQLiteMagic(path, tc.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestScanPathsUniqueContent(t *testing.T) {
+	root := t.TempDir()
+	same := append(append([]byte{}, sqliteMagic...), []byte("identical")...)
+	other := append(append([]byte{}, sqliteMagic...), []byte("different")...)
+	for name, content := range map[string][]byte{"a.db": same, "copy-of-a.db": same, "b.db": other} {
+		if err := os.WriteFile(filepath.Join(root, name), content, 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	opts := scanOptions{
+		Workers: 2,
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Check:   checkOptions{Hash: true},
+		Filters: []matchFilter{uniqueContentFilter(nil)},
+	}
+	matches := make(chan matchResult, 8)
+	errs := make(chan error, 8)
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	seen := map[string]int{}
+	for m := range matches {
+		seen[m.SHA256]++
+	}
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 distinct hashes, got %v", seen)
+	}
+	for sum, n := range seen {
+		if n != 1 {
+			t.Fatalf("expected one path for hash %s, got %d", sum, n)
+		}
+	}
+}
+
+func TestCheckSQLiteMagicHashMaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.db")
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 5000)...)
+	for i := range content[len(sqliteMagic):] {
+		content[len(sqliteMagic)+i] = byte(i)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	for _, limit := range []int64{50, 1000} {
+		stats := &scanStats{}
+		res, ok, err := checkSQLiteMagic(path, checkOptions{Hash: true, HashMaxBytes: limit, Stats: stats})
+		if err != nil || !ok {
+			t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+		}
+		sum := sha256.Sum256(content[:limit])
+		if want := hex.EncodeToString(sum[:]); res.SHA256 != want {
+			t.Fatalf("limit %d: expected hash of prefix %s, got %s", limit, want, res.SHA256)
+		}
+		if !res.HashPartial || res.HashedBytes != limit {
+			t.Fatalf("limit %d: expected partial hash of %d bytes, got partial=%v hashed=%d", limit, limit, res.HashPartial, res.HashedBytes)
+		}
+		// The header is always read in full.
+		if want := max(limit, sqliteHeaderSize); stats.BytesRead.Load() != want {
+			t.Fatalf("limit %d: expected %d bytes read, got %d", limit, want, stats.BytesRead.Load())
+		}
+	}
+
+	res, _, err := checkSQLiteMagic(path, checkOptions{Hash: true, HashMaxBytes: int64(len(content))})
+	if err != nil || res.HashPartial {
+		t.Fatalf("expected a complete hash when the limit covers the file, got partial=%v err=%v", res.HashPartial, err)
+	}
+}
+
+func TestPickByContentStrategies(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dups := []matchResult{
+		{Path: "/data/archive/old/copy.db", SHA256: "aaa", ModTime: base},
+		{Path: "/data/a.db", SHA256: "aaa", ModTime: base.Add(2 * time.Hour)},
+		{Path: "/data/backup/a.db", SHA256: "aaa", ModTime: base.Add(time.Hour)},
+		{Path: "/data/solo.db", SHA256: "bbb", ModTime: base},
+		{Path: "/data/partial.db", SHA256: "aaa", HashPartial: true},
+	}
+	for strategy, want := range map[string][]string{
+		"shortest-path": {"/data/partial.db", "/data/a.db", "/data/solo.db"},
+		"newest":        {"/data/partial.db", "/data/a.db", "/data/solo.db"},
+		"oldest":        {"/data/partial.db", "/data/archive/old/copy.db", "/data/solo.db"},
+	} {
+		in := make(chan matchResult, len(dups))
+		for _, m := range dups {
+			in <- m
+		}
+		close(in)
+		var got []string
+		for m := range pickByContent(in, strategy, nil) {
+			got = append(got, m.Path)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("%s: expected %v, got %v", strategy, want, got)
+		}
+	}
+
+	// "first" is the streaming uniqueContentFilter.
+	keep := uniqueContentFilter(nil)
+	var got []string
+	for _, m := range dups {
+		if keep(m) {
+			got = append(got, m.Path)
+		}
+	}
+	if want := "/data/archive/old/copy.db,/data/solo.db,/data/partial.db"; strings.Join(got, ",") != want {
+		t.Fatalf("first: expected %s, got %v", want, got)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// sqliteHeaderSize is the length of the database header at the start of
+// every SQLite file. See https://www.sqlite.org/fileformat.html#the_database_header
+const sqliteHeaderSize = 100
+
+// Values of matchResult.Kind.
+const (
+	kindDatabase = "database"
+	kindWAL      = "wal"
+	// kindPlaceholder is an empty .db or .sqlite file reported by
+	// --include-zero-size. It has no header, so no header fields.
+	kindPlaceholder = "placeholder"
+)
+
+// Values of matchResult.JournalMode.
+const (
+	journalWAL      = "wal"
+	journalRollback = "rollback"
+	journalUnknown  = "unknown"
+)
+
+// journalModes are the values of --journal-mode.
+var journalModes = []string{journalWAL, journalRollback}
+
+// journalMode derives the journaling mode from the file format write and
+// read version bytes at offsets 18 and 19 of a complete header: both are 1
+// for a rollback journal and 2 for WAL. Any other combination, such as a
+// database caught mid-conversion, is unknown.
+func journalMode(hdr []byte) string {
+	switch {
+	case hdr[18] == 1 && hdr[19] == 1:
+		return journalRollback
+	case hdr[18] == 2 && hdr[19] == 2:
+		return journalWAL
+	}
+	return journalUnknown
+}
+
+// isWALHeader reports whether head starts with the magic number of a
+// write-ahead log, 0x377f0682 or 0x377f0683 (the low bit records the
+// checksum byte order). See https://www.sqlite.org/fileformat.html#the_write_ahead_log
+func isWALHeader(head []byte) bool {
+	if len(head) < 4 {
+		return false
+	}
+	return binary.BigEndian.Uint32(head)&^1 == 0x377f0682
+}
+
+// matchHeader reports whether head, the first bytes of a file (up to
+// sqliteHeaderSize), belongs to a SQLite database or, with walFiles, a
+// write-ahead log, and returns a match with the header fields filled in.
+func matchHeader(head []byte, walFiles bool) (matchResult, bool) {
+	res := matchResult{Kind: kindDatabase, UID: -1, GID: -1}
+	switch {
+	case bytes.HasPrefix(head, sqliteMagic):
+		// A file holding the magic but less than a full header (down
+		// to the bare 16 bytes) is still reported as a degenerate
+		// match; header fields are only decoded from a complete header
+		// and stay zero here.
+		if len(head) == sqliteHeaderSize {
+			applyHeader(&res, head)
+		} else {
+			res.JournalMode = journalUnknown
+		}
+	case walFiles && isWALHeader(head):
+		res.Kind = kindWAL
+	default:
+		return matchResult{}, false
+	}
+	return res, true
+}
+
+// applyHeader copies the header fields we report from a complete 100-byte
+// header into m.
+func applyHeader(m *matchResult, hdr []byte) {
+	// Offset 44 is a 4-byte big-endian integer; only values 1-4 are valid,
+	// so anything out of range is reported as 0 (unknown).
+	if v := binary.BigEndian.Uint32(hdr[44:48]); v >= 1 && v <= 4 {
+		m.SchemaFormat = uint8(v)
+	}
+	m.FreelistPages = int(binary.BigEndian.Uint32(hdr[36:40]))
+	m.PageSize = headerPageSize(hdr)
+	// The in-header database size (offset 28) is only trusted when it is
+	// nonzero and the change counter (offset 24) matches the
+	// version-valid-for number (offset 92); older versions of SQLite
+	// left it stale. Otherwise it stays 0 for estimatePageCount. The
+	// same mismatch means the version at offset 96 is stale too.
+	m.VersionStale = !bytes.Equal(hdr[24:28], hdr[92:96])
+	if !m.VersionStale {
+		m.PageCount = int(binary.BigEndian.Uint32(hdr[28:32]))
+	}
+	m.JournalMode = journalMode(hdr)
+	// Offset 96 holds SQLITE_VERSION_NUMBER of the library that last
+	// wrote the file; user_version (60) and application_id (68) are
+	// set by the application.
+	m.SQLiteVersion = formatSQLiteVersion(binary.BigEndian.Uint32(hdr[96:100]))
+	m.UserVersion = int32(binary.BigEndian.Uint32(hdr[60:64]))
+	m.ApplicationID = binary.BigEndian.Uint32(hdr[68:72])
+	// The largest root b-tree page (offset 52) is only maintained, and so
+	// nonzero, when auto_vacuum is on; the incremental-vacuum flag at
+	// offset 64 then tells incremental from full mode.
+	m.LargestRootPage = binary.BigEndian.Uint32(hdr[52:56])
+	m.AutoVacuum = m.LargestRootPage != 0
+	m.IncrementalVacuum = m.AutoVacuum && binary.BigEndian.Uint32(hdr[64:68]) != 0
+}
+
+// formatSQLiteVersion renders a SQLITE_VERSION_NUMBER such as 3045001 as
+// "3.45.1", or "" for 0 (unknown).
+func formatSQLiteVersion(v uint32) string {
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", v/1000000, v/1000%1000, v%1000)
+}
+
+// headerPageSize decodes the page size at offset 16 of a complete header,
+// where 1 stands for 65536. It returns 0 for a value that is not a power
+// of two between 512 and 65536.
+func headerPageSize(hdr []byte) int {
+	pageSize := int(binary.BigEndian.Uint16(hdr[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return 0
+	}
+	return pageSize
+}
+
+// estimatePageCount fills in PageCount from the file size when the header
+// did not hold a usable count, as SQLite itself does. It needs both the
+// page size and the size, so matches made with --no-stat, and files with
+// a truncated header, keep 0.
+func (m *matchResult) estimatePageCount() {
+	if m.PageCount == 0 && m.PageSize > 0 && m.Size > 0 {
+		m.PageCount = int(m.Size / int64(m.PageSize))
+	}
+}
+
+// schemaFormatFilter keeps databases whose schema format number lies within
+// [min, max]; a zero bound is open-ended.
+func schemaFormatFilter(min, max int) matchFilter {
+	return func(m matchResult) bool {
+		v := int(m.SchemaFormat)
+		if min > 0 && v < min {
+			return false
+		}
+		if max > 0 && v > max {
+			return false
+		}
+		return true
+	}
+}
+
+// journalModeFilter keeps databases using the given journaling mode.
+// Write-ahead log files matched by --wal-files have no mode and are kept.
+func journalModeFilter(mode string) matchFilter {
+	return func(m matchResult) bool {
+		return m.Kind == kindWAL || m.JournalMode == mode
+	}
+}
+
+// minFreelistFilter keeps databases with at least min freelist pages.
+func minFreelistFilter(min int) matchFilter {
+	return func(m matchResult) bool {
+		return m.FreelistPages >= min
+	}
+}
+
+// pageCountFilter keeps databases whose page count lies within [min, max];
+// a zero bound is open-ended. Databases whose count is unknown even from
+// the file size (an invalid page size) are dropped rather than treated as
+// empty; write-ahead log files have no page count and are kept.
+func pageCountFilter(min, max int) matchFilter {
+	return func(m matchResult) bool {
+		if m.Kind == kindWAL {
+			return true
+		}
+		if m.PageCount == 0 && m.Kind == kindDatabase {
+			return false
+		}
+		if min > 0 && m.PageCount < min {
+			return false
+		}
+		if max > 0 && m.PageCount > max {
+			return false
+		}
+		return true
+	}
+}
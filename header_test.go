@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHeader writes a synthetic SQLite file consisting of a 100-byte header
+// (magic plus whatever edit makes) followed by padding.
+func writeHeader(t *testing.T, dir, name string, edit func(hdr []byte)) string {
+	t.Helper()
+	hdr := make([]byte, sqliteHeaderSize+28)
+	copy(hdr, sqliteMagic)
+	if edit != nil {
+		edit(hdr)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, hdr, 0o600); err != nil {
+		t.Fatalf("write header: %v", err)
+	}
+	return path
+}
+
+func TestCheckSQLiteMagicSchemaFormat(t *testing.T) {
+	dir := t.TempDir()
+	for format := uint32(1); format <= 4; format++ {
+		path := writeHeader(t, dir, "db", func(hdr []byte) {
+			binary.BigEndian.PutUint32(hdr[44:], format)
+		})
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("format %d: expected match, got ok=%v err=%v", format, ok, err)
+		}
+		if uint32(res.SchemaFormat) != format {
+			t.Fatalf("expected schema format %d, got %d", format, res.SchemaFormat)
+		}
+
+		for _, tc := range []struct {
+			min, max int
+			keep     bool
+		}{
+			{0, 0, true},
+			{int(format), 0, true},
+			{int(format) + 1, 0, false},
+			{0, int(format), true},
+			{0, int(format) - 1, format == 1},
+		} {
+			if got := schemaFormatFilter(tc.min, tc.max)(res); got != tc.keep {
+				t.Fatalf("format %d with min=%d max=%d: expected keep=%v", format, tc.min, tc.max, tc.keep)
+			}
+		}
+	}
+}
+
+func TestCheckSQLiteMagicSchemaFormatOutOfRange(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []uint32{5, 200, 0x01000001} {
+		path := writeHeader(t, dir, "db", func(hdr []byte) {
+			binary.BigEndian.PutUint32(hdr[44:], v)
+		})
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("value %d: expected match, got ok=%v err=%v", v, ok, err)
+		}
+		if res.SchemaFormat != 0 {
+			t.Fatalf("value %d: expected schema format 0 (unknown), got %d", v, res.SchemaFormat)
+		}
+	}
+}
+
+func TestCheckSQLiteMagicShortHeaderHasNoSchemaFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short.db")
+	if err := os.WriteFile(path, append(append([]byte{}, sqliteMagic...), 4, 4, 4), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.SchemaFormat != 0 {
+		t.Fatalf("expected schema format 0 for short header, got %d", res.SchemaFormat)
+	}
+}
+
+func TestCheckSQLiteMagicMagicOnlyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiny.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected 16-byte magic-only file to match, got ok=%v err=%v", ok, err)
+	}
+	if res.Size != int64(len(sqliteMagic)) {
+		t.Fatalf("expected size %d, got %d", len(sqliteMagic), res.Size)
+	}
+	if res.SchemaFormat != 0 {
+		t.Fatalf("expected zeroed header fields, got schema format %d", res.SchemaFormat)
+	}
+
+	short := filepath.Join(t.TempDir(), "short.db")
+	if err := os.WriteFile(short, sqliteMagic[:15], 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, ok, err := checkSQLiteMagic(short, checkOptions{}); err != nil || ok {
+		t.Fatalf("expected 15-byte prefix not to match, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestCheckSQLiteMagicFreelistPages(t *testing.T) {
+	path := writeHeader(t, t.TempDir(), "bloated.db", func(hdr []byte) {
+		binary.BigEndian.PutUint32(hdr[36:], 1234)
+	})
+	res, ok, err := checkSQLiteMagic(path, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.FreelistPages != 1234 {
+		t.Fatalf("expected 1234 freelist pages, got %d", res.FreelistPages)
+	}
+	if !minFreelistFilter(1234)(res) || minFreelistFilter(1235)(res) {
+		t.Fatalf("minFreelistFilter did not honour the threshold")
+	}
+	opts := outputOptions{ShowFreelist: true}
+	if got := formatJSONLine(res, opts); !strings.Contains(got, `"freelist_pages":1234`) {
+		t.Fatalf("expected freelist_pages in JSON, got %s", got)
+	}
+}
+
+func TestCheckSQLiteMagicPageCount(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeHeader(t, dir, "valid.db", func(hdr []byte) {
+		binary.BigEndian.PutUint16(hdr[16:], 4096)
+		binary.BigEndian.PutUint32(hdr[24:], 7)
+		binary.BigEndian.PutUint32(hdr[28:], 120)
+		binary.BigEndian.PutUint32(hdr[92:], 7)
+	})
+	res, ok, err := checkSQLiteMagic(valid, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.PageCount != 120 {
+		t.Fatalf("expected 120 pages from the header, got %d", res.PageCount)
+	}
+	if !pageCountFilter(100, 0)(res) || pageCountFilter(121, 0)(res) || pageCountFilter(0, 1)(res) {
+		t.Fatalf("pageCountFilter did not honour the bounds")
+	}
+	opts := outputOptions{ShowPageCount: true}
+	if got := formatJSONLine(res, opts); !strings.Contains(got, `"page_count":120`) {
+		t.Fatalf("expected page_count in JSON, got %s", got)
+	}
+
+	// A stale count, left by a change counter that no longer matches
+	// version-valid-for, falls back to size / page size.
+	stale := writeHeader(t, dir, "stale.db", func(hdr []byte) {
+		binary.BigEndian.PutUint16(hdr[16:], 512)
+		binary.BigEndian.PutUint32(hdr[24:], 8)
+		binary.BigEndian.PutUint32(hdr[28:], 120)
+		binary.BigEndian.PutUint32(hdr[92:], 7)
+	})
+	if err := os.Truncate(stale, 3*512); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	res, ok, err = checkSQLiteMagic(stale, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.PageCount != 3 {
+		t.Fatalf("expected 3 pages from the file size, got %d", res.PageCount)
+	}
+}
+
+func TestPageCountFilterUnknownCount(t *testing.T) {
+	dir := t.TempDir()
+	// A legacy header with no in-header size falls back to the file
+	// size, so the limit still applies.
+	legacy := writeHeader(t, dir, "legacy.db", func(hdr []byte) {
+		binary.BigEndian.PutUint16(hdr[16:], 512)
+	})
+	if err := os.Truncate(legacy, 4*512); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(legacy, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.PageCount != 4 || pageCountFilter(0, 1)(res) || !pageCountFilter(0, 4)(res) {
+		t.Fatalf("expected 4 pages from the file size to be filtered, got %d", res.PageCount)
+	}
+
+	// With an invalid page size the count is unknown and no limit
+	// passes it.
+	invalid := writeHeader(t, dir, "invalid.db", func(hdr []byte) {
+		binary.BigEndian.PutUint16(hdr[16:], 1000)
+	})
+	res, ok, err = checkSQLiteMagic(invalid, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.PageCount != 0 || pageCountFilter(0, 1)(res) || pageCountFilter(0, 1<<30)(res) || pageCountFilter(1, 0)(res) {
+		t.Fatalf("expected an unknown page count to be filtered out, got %d", res.PageCount)
+	}
+
+	if !pageCountFilter(0, 1)(matchResult{Kind: kindWAL}) {
+		t.Fatal("expected write-ahead log files to be kept")
+	}
+}
+
+func TestCheckSQLiteMagicSQLiteVersion(t *testing.T) {
+	path := writeHeader(t, t.TempDir(), "app.db", func(hdr []byte) {
+		binary.BigEndian.PutUint32(hdr[60:], 7)
+		binary.BigEndian.PutUint32(hdr[68:], 0x0f055112)
+		binary.BigEndian.PutUint32(hdr[96:], 3045001)
+	})
+	res, ok, err := checkSQLiteMagic(path, checkOptions{})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.SQLiteVersion != "3.45.1" || res.UserVersion != 7 || res.ApplicationID != 0x0f055112 {
+		t.Fatalf("expected 3.45.1, user_version 7 and application_id 0x0f055112, got %q, %d, %#x", res.SQLiteVersion, res.UserVersion, res.ApplicationID)
+	}
+	opts := outputOptions{ShowVersion: true}
+	got := formatJSONLine(res, opts)
+	for _, want := range []string{`"sqlite_version":"3.45.1"`, `"user_version":7`, `"application_id":252006674`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in JSON, got %s", want, got)
+		}
+	}
+	if got := formatPlainMatch(res, opts); !strings.Contains(got, "[sqlite 3.45.1]") {
+		t.Fatalf("expected [sqlite 3.45.1] in plain output, got %s", got)
+	}
+}
+
+func TestCheckSQLiteMagicWALFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, magic := range []uint32{0x377f0682, 0x377f0683} {
+		hdr := make([]byte, 32)
+		binary.BigEndian.PutUint32(hdr, magic)
+		path := filepath.Join(dir, "orphan.db-wal")
+		if err := os.WriteFile(path, hdr, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		if _, ok, err := checkSQLiteMagic(path, checkOptions{}); err != nil || ok {
+			t.Fatalf("%#x: expected no match without --wal-files, got ok=%v err=%v", magic, ok, err)
+		}
+		res, ok, err := checkSQLiteMagic(path, checkOptions{WALFiles: true})
+		if err != nil || !ok || res.Kind != kindWAL {
+			t.Fatalf("%#x: expected WAL match, got %+v ok=%v err=%v", magic, res, ok, err)
+		}
+		if got := formatJSONLine(res, outputOptions{ShowKind: true}); !strings.Contains(got, `"kind":"wal"`) {
+			t.Fatalf("expected kind in JSON, got %s", got)
+		}
+	}
+
+	db := writeHeader(t, dir, "main.db", nil)
+	res, ok, err := checkSQLiteMagic(db, checkOptions{WALFiles: true})
+	if err != nil || !ok || res.Kind != kindDatabase {
+		t.Fatalf("expected database kind, got %+v ok=%v err=%v", res, ok, err)
+	}
+}
+
+func TestCheckSQLiteMagicJournalMode(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name        string
+		write, read byte
+		want        string
+	}{
+		{"wal.db", 2, 2, journalWAL},
+		{"rollback.db", 1, 1, journalRollback},
+		{"mixed.db", 2, 1, journalUnknown},
+	} {
+		path := writeHeader(t, dir, tc.name, func(hdr []byte) {
+			hdr[18], hdr[19] = tc.write, tc.read
+		})
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, got ok=%v err=%v", tc.name, ok, err)
+		}
+		if res.JournalMode != tc.want {
+			t.Fatalf("%s: expected journal mode %q, got %q", tc.name, tc.want, res.JournalMode)
+		}
+		if keep := journalModeFilter(journalWAL)(res); keep != (tc.want == journalWAL) {
+			t.Fatalf("%s: --journal-mode wal kept=%v", tc.name, keep)
+		}
+	}
+	res, _, _ := checkSQLiteMagic(filepath.Join(dir, "wal.db"), checkOptions{})
+	opts := outputOptions{ShowJournalMode: true}
+	if got := formatPlainMatch(res, opts); !strings.HasSuffix(got, " [journal wal]") {
+		t.Fatalf("unexpected plain output %q", got)
+	}
+	if got := formatJSONLine(res, opts); !strings.Contains(got, `"journal_mode":"wal"`) {
+		t.Fatalf("expected journal_mode in JSON, got %s", got)
+	}
+}
+
+func TestCheckSQLiteMagicAutoVacuum(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name              string
+		rootPage, incr    uint32
+		auto, incremental bool
+		plain             string
+	}{
+		{"normal.db", 0, 0, false, false, ""},
+		{"full.db", 7, 0, true, false, " [auto-vacuum full]"},
+		{"incremental.db", 3, 1, true, true, " [auto-vacuum incremental]"},
+	} {
+		path := writeHeader(t, dir, tc.name, func(hdr []byte) {
+			binary.BigEndian.PutUint32(hdr[52:56], tc.rootPage)
+			binary.BigEndian.PutUint32(hdr[64:68], tc.incr)
+		})
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, got ok=%v err=%v", tc.name, ok, err)
+		}
+		if res.AutoVacuum != tc.auto || res.IncrementalVacuum != tc.incremental || res.LargestRootPage != tc.rootPage {
+			t.Fatalf("%s: unexpected auto-vacuum fields %+v", tc.name, res)
+		}
+		opts := outputOptions{ShowAutoVacuum: true}
+		if got := formatPlainMatch(res, opts); got != path+tc.plain {
+			t.Fatalf("%s: unexpected plain output %q", tc.name, got)
+		}
+		want := fmt.Sprintf(`"autovacuum":%v,"incremental_vacuum":%v,"largest_root_page":%d`, tc.auto, tc.incremental, tc.rootPage)
+		if got := formatJSONLine(res, opts); !strings.Contains(got, want) {
+			t.Fatalf("%s: expected %s in JSON, got %s", tc.name, want, got)
+		}
+	}
+}
+
+func TestCheckSQLiteMagicVersionStale(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name     string
+		validFor uint32
+		stale    bool
+		plain    string
+	}{
+		{"current.db", 42, false, "[sqlite 3.45.1]"},
+		{"stale.db", 41, true, "[sqlite 3.45.1, stale]"},
+	} {
+		path := writeHeader(t, dir, tc.name, func(hdr []byte) {
+			binary.BigEndian.PutUint32(hdr[24:], 42)
+			binary.BigEndian.PutUint32(hdr[92:], tc.validFor)
+			binary.BigEndian.PutUint32(hdr[96:], 3045001)
+		})
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, got ok=%v err=%v", tc.name, ok, err)
+		}
+		if res.VersionStale != tc.stale {
+			t.Fatalf("%s: expected VersionStale %v, got %v", tc.name, tc.stale, res.VersionStale)
+		}
+		opts := outputOptions{ShowVersion: true}
+		if got := formatPlainMatch(res, opts); !strings.Contains(got, tc.plain) {
+			t.Fatalf("%s: expected %s in plain output, got %s", tc.name, tc.plain, got)
+		}
+		got := formatJSONLine(res, opts)
+		if tc.stale != strings.Contains(got, `"sqlite_version_stale":true`) {
+			t.Fatalf("%s: unexpected JSON %s", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// expandHome implements --expand-home: it replaces a leading "~" or
+// "~/..." with the current user's home directory and "~name/..." with
+// name's, as the shell would for unquoted arguments. Other paths are
+// returned unchanged.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	name, rest, _ := strings.Cut(path[1:], "/")
+	var u *user.User
+	var err error
+	if name == "" {
+		u, err = user.Current()
+	} else {
+		u, err = user.Lookup(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(u.HomeDir, rest), nil
+}
+
+// expandHomeAll applies expandHome to every path.
+func expandHomeAll(paths []string) ([]string, error) {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		expanded, err := expandHome(p)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = expanded
+	}
+	return out, nil
+}
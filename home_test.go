@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandHome(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	for _, tc := range []struct{ in, want string }{
+		{"~", u.HomeDir},
+		{"~/", u.HomeDir},
+		{"~/sub", filepath.Join(u.HomeDir, "sub")},
+		{"~" + u.Username + "/sub", filepath.Join(u.HomeDir, "sub")},
+		{"/data/~/sub", "/data/~/sub"},
+		{"sub/~", "sub/~"},
+	} {
+		got, err := expandHome(tc.in)
+		if err != nil || got != tc.want {
+			t.Fatalf("expandHome(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := expandHome("~no-such-user-sqlite-scanner/sub"); err == nil {
+		t.Fatal("expected an error for an unknown user")
+	}
+
+	roots, err := expandHomeAll([]string{"~/sub", "/data"})
+	if err != nil || roots[0] != filepath.Join(u.HomeDir, "sub") || roots[1] != "/data" {
+		t.Fatalf("unexpected roots %q, %v", roots, err)
+	}
+}
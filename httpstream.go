@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpStreamQueue is how many matches may wait for --stream-to before Add
+// blocks, so a slow endpoint only holds the scan back once it has fallen
+// this far behind.
+const httpStreamQueue = 256
+
+// httpStreamMaxFailures is how many requests in a row may fail before
+// --stream-to gives up on the endpoint and drops the matches still to come,
+// so a dead endpoint does not cost a timeout per match.
+const httpStreamMaxFailures = 10
+
+// httpStreamer is a matchSink that sends every match to a webhook or log
+// collector (--stream-to) as soon as it is found. Add queues the match and
+// a background goroutine sends the requests one at a time; a failed
+// request goes to errs and never stops the scan, but makes Close fail.
+type httpStreamer struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	method string
+	header http.Header
+	// secret, when set, signs every body (--webhook-secret).
+	secret []byte
+	now    func() time.Time
+	errs   chan<- error
+	queue  chan streamRequest
+	done   chan struct{}
+	// failed counts the matches that were not sent. It belongs to the
+	// sending goroutine until done is closed.
+	failed int
+}
+
+// streamRequest is a queued --stream-to body and the path it is for.
+type streamRequest struct {
+	path string
+	body []byte
+}
+
+// signatureHeader carries the body's HMAC-SHA256 in GitHub's webhook
+// format, "sha256=HEXSIG".
+const signatureHeader = "X-Signature-SHA256"
+
+// streamEvent is the JSON body sent for each match.
+type streamEvent struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Timestamp string `json:"timestamp"`
+}
+
+// newHTTPStreamer validates the --stream-to settings and starts sending.
+// headers are "Name: value" strings; a non-empty secret signs each
+// request. errs must stay open until Close returns.
+func newHTTPStreamer(ctx context.Context, rawURL, method string, headers []string, timeout time.Duration, secret string, errs chan<- error) (*httpStreamer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", rawURL)
+	}
+	method = strings.ToUpper(method)
+	if method != http.MethodPost && method != http.MethodPut {
+		return nil, fmt.Errorf("method must be POST or PUT, not %q", method)
+	}
+	h := http.Header{}
+	for _, kv := range headers {
+		name, value, ok := strings.Cut(kv, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("header %q is not in Name: value form", kv)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	s := &httpStreamer{
+		ctx:    ctx,
+		client: &http.Client{Timeout: timeout},
+		url:    rawURL,
+		method: method,
+		header: h,
+		now:    time.Now,
+		errs:   errs,
+		queue:  make(chan streamRequest, httpStreamQueue),
+		done:   make(chan struct{}),
+	}
+	if secret != "" {
+		s.secret = []byte(secret)
+	}
+	go s.run()
+	return s, nil
+}
+
+// Add queues m, stamped with the time it was found.
+func (s *httpStreamer) Add(m matchResult) error {
+	body, err := json.Marshal(streamEvent{
+		Path:      formatPath(m.Path),
+		Size:      m.Size,
+		Timestamp: s.now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	s.queue <- streamRequest{path: formatPath(m.Path), body: body}
+	return nil
+}
+
+// run sends the queued requests until Close closes the queue. After
+// httpStreamMaxFailures failures in a row the rest are only counted.
+func (s *httpStreamer) run() {
+	defer close(s.done)
+	streak := 0
+	for r := range s.queue {
+		if streak >= httpStreamMaxFailures {
+			s.failed++
+			continue
+		}
+		if err := s.send(r.body); err != nil {
+			s.failed++
+			streak++
+			s.errs <- fmt.Errorf("--stream-to: %s: %w", r.path, err)
+			continue
+		}
+		streak = 0
+	}
+}
+
+// send makes one request with body.
+func (s *httpStreamer) send(body []byte) error {
+	req, err := http.NewRequestWithContext(s.ctx, s.method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = s.header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != nil {
+		req.Header.Set(signatureHeader, signBody(s.secret, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s", s.method, s.url, resp.Status)
+	}
+	return nil
+}
+
+// Close waits for the queued matches to be sent.
+func (s *httpStreamer) Close() error {
+	close(s.queue)
+	<-s.done
+	if s.failed > 0 {
+		return fmt.Errorf("--stream-to: %d matches could not be sent", s.failed)
+	}
+	return nil
+}
+
+// signBody returns the signatureHeader value for body.
+func signBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPStreamer(t *testing.T) {
+	type request struct {
+		method string
+		auth   string
+		ctype  string
+		event  streamEvent
+	}
+	got := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev streamEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		got <- request{r.Method, r.Header.Get("Authorization"), r.Header.Get("Content-Type"), ev}
+	}))
+	defer srv.Close()
+
+	s, err := newHTTPStreamer(context.Background(), srv.URL, "put", []string{"Authorization: Bearer TOKEN"}, time.Second, "", make(chan error, 1))
+	if err != nil {
+		t.Fatalf("newHTTPStreamer: %v", err)
+	}
+	s.now = func() time.Time { return time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC) }
+	if err := s.Add(matchResult{Path: "/data/a.db", Size: 4096}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	r := <-got
+	want := request{"PUT", "Bearer TOKEN", "application/json", streamEvent{"/data/a.db", 4096, "2024-05-06T07:08:09Z"}}
+	if r != want {
+		t.Fatalf("expected %+v, got %+v", want, r)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestHTTPStreamerReportsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	errs := make(chan error, httpStreamMaxFailures)
+	s, err := newHTTPStreamer(context.Background(), srv.URL, "POST", nil, time.Second, "", errs)
+	if err != nil {
+		t.Fatalf("newHTTPStreamer: %v", err)
+	}
+	if err := s.Add(matchResult{Path: "/data/a.db"}); err != nil {
+		t.Fatalf("Add should only queue the match, got %v", err)
+	}
+	if err := s.Close(); err == nil || !strings.Contains(err.Error(), "1 matches could not be sent") {
+		t.Fatalf("expected Close to report the failed match, got %v", err)
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "/data/a.db") || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected the 503 for /data/a.db, got %v", err)
+	}
+}
+
+func TestHTTPStreamerGivesUpOnDeadEndpoint(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	errs := make(chan error, httpStreamMaxFailures)
+	s, err := newHTTPStreamer(context.Background(), srv.URL, "POST", nil, time.Second, "", errs)
+	if err != nil {
+		t.Fatalf("newHTTPStreamer: %v", err)
+	}
+	for i := 0; i < httpStreamMaxFailures+5; i++ {
+		s.Add(matchResult{Path: "/data/a.db"})
+	}
+	if err := s.Close(); err == nil || !strings.Contains(err.Error(), "15 matches could not be sent") {
+		t.Fatalf("expected Close to count every unsent match, got %v", err)
+	}
+	if n := requests.Load(); n != httpStreamMaxFailures {
+		t.Fatalf("expected %d requests before giving up, got %d", httpStreamMaxFailures, n)
+	}
+	if len(errs) != httpStreamMaxFailures {
+		t.Fatalf("expected one error per failed request, got %d", len(errs))
+	}
+}
+
+func TestNewHTTPStreamerValidates(t *testing.T) {
+	for _, tc := range []struct {
+		url, method string
+		headers     []string
+	}{
+		{"ftp://example.com/hook", "POST", nil},
+		{"not a url", "POST", nil},
+		{"https://example.com/hook", "GET", nil},
+		{"https://example.com/hook", "POST", []string{"no colon"}},
+	} {
+		if _, err := newHTTPStreamer(context.Background(), tc.url, tc.method, tc.headers, time.Second, "", make(chan error, 1)); err == nil {
+			t.Fatalf("expected %+v to be rejected", tc)
+		}
+	}
+}
+
+func TestHTTPStreamerSignsBody(t *testing.T) {
+	const secret = "It's a Secret to Everybody"
+	got := make(chan bool, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		got <- hmac.Equal([]byte(r.Header.Get("X-Signature-SHA256")), []byte(want))
+	}))
+	defer srv.Close()
+
+	s, err := newHTTPStreamer(context.Background(), srv.URL, "POST", nil, time.Second, secret, make(chan error, 1))
+	if err != nil {
+		t.Fatalf("newHTTPStreamer: %v", err)
+	}
+	for _, p := range []string{"/data/a.db", "/data/b.db"} {
+		if err := s.Add(matchResult{Path: p, Size: 4096}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if !<-got {
+			t.Fatalf("%s: signature did not verify", p)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// GitHub's documented example for this secret and body.
+	if sig := signBody([]byte(secret), []byte("Hello, World!")); sig != "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17" {
+		t.Fatalf("unexpected signature %s", sig)
+	}
+}
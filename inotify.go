@@ -0,0 +1,52 @@
+package main
+
+import "sync"
+
+// eventRemoved marks a matchResult that reports a previously matched file
+// disappearing under --inotify-watch, rather than a new match.
+const eventRemoved = "removed"
+
+// knownPaths records the paths reported as matches, so --inotify-watch
+// can tell which removals to report and does not report a file twice. It
+// is safe for concurrent use by workers. Each new path takes a slot from
+// limit.
+type knownPaths struct {
+	mu    sync.Mutex
+	paths map[string]bool
+	limit *bufferLimit
+}
+
+func newKnownPaths(limit *bufferLimit) *knownPaths {
+	return &knownPaths{paths: map[string]bool{}, limit: limit}
+}
+
+func (k *knownPaths) add(path string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.paths[path] && !k.limit.take("--inotify-watch") {
+		return
+	}
+	k.paths[path] = true
+}
+
+func (k *knownPaths) has(path string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.paths[path]
+}
+
+// remove forgets path, reporting whether it was known.
+func (k *knownPaths) remove(path string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ok := k.paths[path]
+	delete(k.paths, path)
+	return ok
+}
+
+// removedEvent is the JSON shape of a removal under --inotify-watch.
+type removedEvent struct {
+	Event string `json:"event"`
+	Host  string `json:"host,omitempty"`
+	Path  string `json:"path"`
+}
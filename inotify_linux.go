@@ -0,0 +1,131 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifySupported = true
+
+// inotifyDirMask selects the events watched on every directory. Files
+// are only checked on IN_CLOSE_WRITE and IN_MOVED_TO, once their content
+// is complete; IN_CREATE is used for new subdirectories only.
+const inotifyDirMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_ONLYDIR
+
+// inotifyWatchRoots implements --inotify-watch: like watchRoots, but on
+// the raw inotify API. It checks files as they are closed after writing
+// or moved in, calls removed for known matches that are deleted or moved
+// away, and adds watches for new subdirectories, until ctx is cancelled.
+func inotifyWatchRoots(ctx context.Context, roots []string, opts scanOptions, errs chan<- error, check func(path, label string) bool, known *knownPaths, removed func(path, label string)) error {
+	logger := opts.Logger
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	// A non-blocking descriptor is handled by the runtime poller, so
+	// closing the file unblocks the read below.
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	// dirs maps each watch descriptor to its directory and root label.
+	type watched struct{ dir, label string }
+	dirs := make(map[int]watched)
+
+	offer := func(path, label string) {
+		if known.has(path) {
+			return
+		}
+		check(path, label)
+	}
+
+	addTree := func(dir, label string, checkFiles bool) {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					opts.Denied.add(path)
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				if checkFiles && d.Type().IsRegular() {
+					offer(path, label)
+				}
+				return nil
+			}
+			wd, err := unix.InotifyAddWatch(fd, path, inotifyDirMask)
+			if err != nil {
+				errs <- fmt.Errorf("watch %s: %w", path, err)
+				return filepath.SkipDir
+			}
+			dirs[wd] = watched{path, label}
+			return nil
+		})
+	}
+
+	for _, r := range roots {
+		addTree(r, opts.Labels[r], false)
+	}
+	logger.Info("watching for new databases", "directories", len(dirs))
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("inotify: %w", err)
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+				errs <- errors.New("inotify: event queue overflowed; some files may have been missed")
+				continue
+			}
+			w, ok := dirs[int(ev.Wd)]
+			if !ok {
+				continue
+			}
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				delete(dirs, int(ev.Wd))
+				continue
+			}
+			path := filepath.Join(w.dir, string(bytes.TrimRight(nameBytes, "\x00")))
+			isDir := ev.Mask&unix.IN_ISDIR != 0
+			switch {
+			case ev.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+				if !isDir && known.remove(path) {
+					removed(path, w.label)
+				}
+			case isDir && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+				logger.Debug("watching new directory", "path", path)
+				addTree(path, w.label, true)
+			case !isDir && ev.Mask&(unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO) != 0:
+				if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+					offer(path, w.label)
+				}
+			}
+		}
+	}
+}
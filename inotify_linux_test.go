@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanPathsInotifyWatchReportsNewAndRemoved(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing.db")
+	if err := os.WriteFile(existing, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	matches := make(chan matchResult, 16)
+	errs := make(chan error, 16)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), InotifyWatch: true}
+	done := make(chan error, 1)
+	go func() {
+		done <- scanPaths(ctx, []string{root}, opts, matches, errs)
+	}()
+
+	select {
+	case m := <-matches:
+		if m.Path != existing || m.Event != "" {
+			t.Fatalf("expected the initial scan to report %s, got %+v", existing, m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("initial scan reported nothing")
+	}
+
+	// As with --watch, there is no signal that the watches are in place,
+	// so keep rewriting the new files until they are noticed.
+	sub := filepath.Join(root, "sub")
+	fresh := filepath.Join(root, "fresh.db")
+	nested := filepath.Join(sub, "nested.db")
+	got := map[string]bool{}
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for !got[fresh] || !got[nested] {
+		select {
+		case m := <-matches:
+			if m.Event != "" || got[m.Path] {
+				t.Fatalf("unexpected report %+v", m)
+			}
+			got[m.Path] = true
+		case <-tick.C:
+			os.WriteFile(filepath.Join(root, "notes.txt"), []byte("not a database"), 0o600)
+			if !got[fresh] {
+				os.WriteFile(fresh, sqliteMagic, 0o600)
+			}
+			if _, err := os.Stat(sub); err != nil {
+				os.Mkdir(sub, 0o755)
+				os.WriteFile(nested, sqliteMagic, 0o600)
+			}
+		case <-deadline:
+			t.Fatalf("watch reported %v, expected %s and %s", got, fresh, nested)
+		}
+	}
+
+	// Removing a match reports it; removing another file does not.
+	os.Remove(filepath.Join(root, "notes.txt"))
+	if err := os.Remove(existing); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	select {
+	case m := <-matches:
+		if m.Event != eventRemoved || m.Path != existing {
+			t.Fatalf("expected removal of %s, got %+v", existing, m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("removal not reported")
+	}
+	line := formatJSONLine(matchResult{Path: existing, Event: eventRemoved}, outputOptions{JSONL: true})
+	if want := `{"event":"removed","path":"` + existing + `"}`; line != want {
+		t.Fatalf("got %s, want %s", line, want)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watch did not stop after cancel")
+	}
+}
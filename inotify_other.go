@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+const inotifySupported = false
+
+func inotifyWatchRoots(ctx context.Context, roots []string, opts scanOptions, errs chan<- error, check func(path, label string) bool, known *knownPaths, removed func(path, label string)) error {
+	return errors.New("--inotify-watch is only supported on Linux")
+}
@@ -0,0 +1,34 @@
+// Package testutil holds helpers shared by the scanner's tests.
+package testutil
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// CreateSQLiteDB creates a real SQLite database named name in dir with one
+// single-column table for each of tables and returns its path. Unlike a
+// file holding only the magic bytes, the result opens cleanly with the
+// driver, for tests of checks that go beyond the header.
+func CreateSQLiteDB(t testing.TB, dir, name string, tables []string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer db.Close()
+	// Creating the file even without tables makes it a valid database.
+	if _, err := db.Exec("PRAGMA user_version = 1"); err != nil {
+		t.Fatalf("init %s: %v", path, err)
+	}
+	for _, table := range tables {
+		if _, err := db.Exec(`CREATE TABLE "` + table + `" (x)`); err != nil {
+			t.Fatalf("create table %s in %s: %v", table, path, err)
+		}
+	}
+	return path
+}
@@ -0,0 +1,22 @@
+package testutil
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCreateSQLiteDB(t *testing.T) {
+	path := CreateSQLiteDB(t, t.TempDir(), "app.db", []string{"users", "orders"})
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table'").Scan(&n); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 tables, got %d", n)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// journalFields are the structured fields --journald attaches to the entry
+// for a match. SQLITE_SCANNER_SIZE is left out when the size is unknown.
+func journalFields(m matchResult) map[string]string {
+	fields := map[string]string{"SQLITE_SCANNER_PATH": formatPath(m.Path)}
+	if m.Size >= 0 {
+		fields["SQLITE_SCANNER_SIZE"] = strconv.FormatInt(m.Size, 10)
+	}
+	return fields
+}
+
+// stderrJournal is the matchSink --journald falls back to where there is
+// no journal: the message it would have sent, one line per match.
+type stderrJournal struct {
+	w io.Writer
+}
+
+func (s stderrJournal) Add(m matchResult) error {
+	_, err := fmt.Fprintln(s.w, "sqlite-scanner: "+syslogMessage(m))
+	return err
+}
+
+func (s stderrJournal) Close() error { return nil }
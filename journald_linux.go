@@ -0,0 +1,23 @@
+package main
+
+import "github.com/coreos/go-systemd/v22/journal"
+
+const journaldSupported = true
+
+// journalSend is swapped out in tests.
+var journalSend = journal.Send
+
+// journaldSink is a matchSink sending every match to the systemd journal
+// as an info-level entry (PRIORITY=6) with journalFields (--journald).
+type journaldSink struct{}
+
+func (journaldSink) Add(m matchResult) error {
+	return journalSend(syslogMessage(m), journal.PriInfo, journalFields(m))
+}
+
+func (journaldSink) Close() error { return nil }
+
+// journaldAvailable reports whether the journal's socket exists.
+func journaldAvailable() bool {
+	return journal.Enabled()
+}
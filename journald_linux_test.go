@@ -0,0 +1,39 @@
+package main
+
+import (
+	"maps"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/go-systemd/v22/journal"
+)
+
+func TestJournaldSink(t *testing.T) {
+	type entry struct {
+		msg    string
+		pri    journal.Priority
+		fields map[string]string
+	}
+	var sent []entry
+	old := journalSend
+	journalSend = func(msg string, pri journal.Priority, fields map[string]string) error {
+		sent = append(sent, entry{msg, pri, fields})
+		return nil
+	}
+	defer func() { journalSend = old }()
+
+	path := filepath.Join(t.TempDir(), "app.db")
+	if err := (journaldSink{}).Add(matchResult{Path: path, Size: 4096}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected one entry, got %d", len(sent))
+	}
+	if sent[0].pri != 6 || sent[0].msg != "found "+path+" (4096 bytes)" {
+		t.Fatalf("unexpected entry %+v", sent[0])
+	}
+	want := map[string]string{"SQLITE_SCANNER_PATH": path, "SQLITE_SCANNER_SIZE": "4096"}
+	if !maps.Equal(sent[0].fields, want) {
+		t.Fatalf("expected fields %v, got %v", want, sent[0].fields)
+	}
+}
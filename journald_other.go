@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+const journaldSupported = false
+
+// journaldSink is never used here; --journald falls back to stderrJournal.
+type journaldSink struct{}
+
+func (journaldSink) Add(matchResult) error { return nil }
+
+func (journaldSink) Close() error { return nil }
+
+func journaldAvailable() bool { return false }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+var pathologicalPaths = []string{
+	`/data/quote"d.db`,
+	`/data/back\slash.db`,
+	"/data/tab\there.db",
+	"/data/new\nline.db",
+	"/data/nul\x00byte.db",
+	"/data/bell\x07.db",
+	"/data/line\u2028separator.db",
+	"/data/html<&>.db",
+	"/data/ünïcødé/日本語.db",
+	"/data/emoji-🗄️.db",
+}
+
+func TestJSONLinePathologicalPaths(t *testing.T) {
+	opts := outputOptions{JSONL: true, ShowSize: true, ShowSchemaFormat: true}
+	for _, p := range pathologicalPaths {
+		line := formatJSONLine(matchResult{Path: p, Size: 1 << 62, SchemaFormat: 4}, opts)
+		if strings.Contains(line, "\n") {
+			t.Fatalf("JSONL entry for %q spans lines: %q", p, line)
+		}
+		var got struct {
+			Path         string `json:"path"`
+			Size         int64  `json:"size"`
+			SchemaFormat uint8  `json:"schema_format"`
+		}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("invalid JSON for %q: %v\n%s", p, err, line)
+		}
+		if got.Path != p || got.Size != 1<<62 || got.SchemaFormat != 4 {
+			t.Fatalf("round trip mismatch for %q: %+v", p, got)
+		}
+	}
+}
+
+func TestJSONLineInvalidUTF8StillValid(t *testing.T) {
+	line := formatJSONLine(matchResult{Path: "/data/bad\xff\xfe.db"}, outputOptions{JSONL: true})
+	if !json.Valid([]byte(line)) {
+		t.Fatalf("expected valid JSON for invalid UTF-8 path, got %q", line)
+	}
+}
+
+func TestJSONDocumentPathologicalPaths(t *testing.T) {
+	matches := make(chan matchResult, len(pathologicalPaths))
+	for _, p := range pathologicalPaths {
+		matches <- matchResult{Path: p, Size: 42}
+	}
+	close(matches)
+
+	out := captureStdout(t, func() {
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, ShowSize: true})
+	})
+	var doc struct {
+		Entries []struct {
+			Path string `json:"path"`
+			Size int64  `json:"size"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON document: %v\n%s", err, out)
+	}
+	if len(doc.Entries) != len(pathologicalPaths) {
+		t.Fatalf("expected %d entries, got %d", len(pathologicalPaths), len(doc.Entries))
+	}
+	for i, e := range doc.Entries {
+		if e.Path != pathologicalPaths[i] || e.Size != 42 {
+			t.Fatalf("entry %d mismatch: %+v", i, e)
+		}
+	}
+	if !strings.Contains(out, "html<&>.db") {
+		t.Fatalf("expected HTML characters to be left unescaped, got:\n%s", out)
+	}
+}
+
+func TestTruncatePathBoundaries(t *testing.T) {
+	path := "/home/user/data/db.sqlite" // 25 characters
+	if got := truncatePath(path, 25); got != path {
+		t.Fatalf("expected exact-length path unchanged, got %q", got)
+	}
+	if got := truncatePath(path, 26); got != path {
+		t.Fatalf("expected shorter path unchanged, got %q", got)
+	}
+	got := truncatePath(path, 24)
+	if got != "...e/user/data/db.sqlite" || len([]rune(got)) != 24 {
+		t.Fatalf("expected 24-character truncation, got %q", got)
+	}
+	if got := truncatePath("/données/é.db", 8); got != ".../é.db" {
+		t.Fatalf("expected rune-aware truncation, got %q", got)
+	}
+}
+
+func TestTruncatePathOutput(t *testing.T) {
+	m := matchResult{Path: "/home/user/.local/share/app/state.db"}
+	opts := outputOptions{TruncatePath: 15}
+
+	if got := formatPlainMatch(m, opts); got != "...app/state.db" {
+		t.Fatalf("expected truncated plain path, got %q", got)
+	}
+	var obj map[string]string
+	if err := json.Unmarshal([]byte(formatJSONLine(m, opts)), &obj); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if obj["path"] != m.Path || obj["display_path"] != "...app/state.db" {
+		t.Fatalf("expected full path and display_path, got %v", obj)
+	}
+}
+
+func TestJSONKeyRenamesEntries(t *testing.T) {
+	matches := make(chan matchResult, 1)
+	matches <- matchResult{Path: "/data/a.db"}
+	close(matches)
+
+	out := captureStdout(t, func() {
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, JSONKey: "files"})
+	})
+	var doc map[string][]map[string]any
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON document: %v\n%s", err, out)
+	}
+	if len(doc["files"]) != 1 || doc["files"][0]["path"] != "/data/a.db" {
+		t.Fatalf(`expected entries under "files", got:\n%s`, out)
+	}
+	if _, ok := doc["entries"]; ok {
+		t.Fatalf(`expected no "entries" key, got:\n%s`, out)
+	}
+
+	for _, key := range []string{"files", "_x", "db-list2"} {
+		if err := validateJSONKey(key); err != nil {
+			t.Fatalf("expected %q to be accepted: %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "1st", "has space", "quo\"te", "truncated", strings.Repeat("k", 65)} {
+		if err := validateJSONKey(key); err == nil {
+			t.Fatalf("expected %q to be rejected", key)
+		}
+	}
+}
+
+func TestJSONIndentWidth(t *testing.T) {
+	stream := func(opts outputOptions) string {
+		matches := make(chan matchResult, 2)
+		matches <- matchResult{Path: "/data/a.db", Size: 1}
+		matches <- matchResult{Path: "/data/b.db", Size: 2}
+		close(matches)
+		opts.JSON, opts.ShowSize = true, true
+		out := captureStdout(t, func() {
+			streamMatches(context.Background(), os.Stdout, matches, opts)
+		})
+		if err := json.Unmarshal([]byte(out), new(map[string]any)); err != nil {
+			t.Fatalf("invalid JSON document: %v\n%s", err, out)
+		}
+		return out
+	}
+
+	want4 := "{\n" +
+		"    \"entries\": [\n" +
+		"        {\n" +
+		"            \"path\": \"/data/a.db\",\n" +
+		"            \"size\": 1\n" +
+		"        },\n" +
+		"        {\n" +
+		"            \"path\": \"/data/b.db\",\n" +
+		"            \"size\": 2\n" +
+		"        }\n" +
+		"    ]\n" +
+		"}\n"
+	if got := stream(outputOptions{JSONIndent: 4}); got != want4 {
+		t.Fatalf("unexpected --json-indent 4 output:\n%s", got)
+	}
+	if got := stream(outputOptions{}); !strings.Contains(got, "\n  \"entries\": [\n    {\n      \"path\"") {
+		t.Fatalf("expected two-space indentation by default, got:\n%s", got)
+	}
+	want0 := `{"entries":[{"path":"/data/a.db","size":1},{"path":"/data/b.db","size":2}]}` + "\n"
+	if got := stream(outputOptions{JSONCompact: true}); got != want0 {
+		t.Fatalf("unexpected compact output:\n%s", got)
+	}
+}
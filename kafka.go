@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaAttempts is how many times a batch is sent before its matches are
+// reported as failed.
+const kafkaAttempts = 3
+
+// kafkaWriter is the part of *kafka.Writer the sink uses.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSizeHeader is the message header carrying the size in bytes that
+// --kafka-partition-key size partitions on.
+const kafkaSizeHeader = "size"
+
+// kafkaSink is a matchSink producing every match to a Kafka topic
+// (--kafka-broker, --kafka-topic) as a streamEvent JSON message keyed by
+// path. Writes are batched in the background; Close flushes whatever is
+// still queued and fails if any match could not be delivered.
+type kafkaSink struct {
+	ctx context.Context
+	w   kafkaWriter
+	key string
+	now func() time.Time
+	// errs receives an error for every message whose batch still failed
+	// after kafkaAttempts tries. It must stay open until Close returns.
+	errs   chan<- error
+	failed atomic.Int64
+}
+
+// newKafkaSink validates the --kafka-* settings and starts a writer for
+// topic on brokers. Messages still failing after kafkaAttempts tries are
+// sent to errs without stopping the scan, and make Close fail.
+func newKafkaSink(ctx context.Context, brokers []string, topic, partitionKey string, errs chan<- error) (*kafkaSink, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("--kafka-topic requires --kafka-broker")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("--kafka-broker requires --kafka-topic")
+	}
+	balancer, err := kafkaBalancer(partitionKey)
+	if err != nil {
+		return nil, err
+	}
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     balancer,
+		MaxAttempts:  kafkaAttempts,
+		BatchTimeout: 100 * time.Millisecond,
+		Async:        true,
+	}
+	s := &kafkaSink{ctx: ctx, w: w, key: partitionKey, now: time.Now, errs: errs}
+	w.Completion = s.delivered
+	return s, nil
+}
+
+// delivered is the writer's Completion callback, called once for every
+// batch sent in the background.
+func (s *kafkaSink) delivered(msgs []kafka.Message, err error) {
+	if err == nil {
+		return
+	}
+	s.failed.Add(int64(len(msgs)))
+	for _, m := range msgs {
+		s.errs <- fmt.Errorf("--kafka-broker: %s: %w", m.Key, err)
+	}
+}
+
+// kafkaBalancer picks the partitioner for --kafka-partition-key: path
+// hashes the message key and size the kafkaSizeHeader, so equal paths or
+// sizes share a partition; random spreads matches evenly.
+func kafkaBalancer(partitionKey string) (kafka.Balancer, error) {
+	switch partitionKey {
+	case "path":
+		return &kafka.Hash{}, nil
+	case "size":
+		hash := &kafka.Hash{}
+		return kafka.BalancerFunc(func(msg kafka.Message, partitions ...int) int {
+			for _, h := range msg.Headers {
+				if h.Key == kafkaSizeHeader {
+					msg.Key = h.Value
+				}
+			}
+			return hash.Balance(msg, partitions...)
+		}), nil
+	case "random":
+		return kafka.BalancerFunc(func(_ kafka.Message, partitions ...int) int {
+			return partitions[rand.IntN(len(partitions))]
+		}), nil
+	}
+	return nil, fmt.Errorf("--kafka-partition-key must be path, size or random, not %q", partitionKey)
+}
+
+// message is the Kafka message for m, keyed by path. For
+// --kafka-partition-key size the size also travels in kafkaSizeHeader for
+// the balancer.
+func (s *kafkaSink) message(m matchResult, value []byte) kafka.Message {
+	msg := kafka.Message{Key: []byte(formatPath(m.Path)), Value: value}
+	if s.key == "size" {
+		msg.Headers = []kafka.Header{{Key: kafkaSizeHeader, Value: []byte(strconv.FormatInt(m.Size, 10))}}
+	}
+	return msg
+}
+
+func (s *kafkaSink) Add(m matchResult) error {
+	value, err := json.Marshal(streamEvent{
+		Path:      formatPath(m.Path),
+		Size:      m.Size,
+		Timestamp: s.now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.w.WriteMessages(s.ctx, s.message(m, value)); err != nil {
+		s.failed.Add(1)
+		return fmt.Errorf("--kafka-broker: %w", err)
+	}
+	return nil
+}
+
+// Close flushes queued messages and closes the connections. It is called
+// after the scan ends, including when SIGTERM cancelled it.
+func (s *kafkaSink) Close() error {
+	if err := s.w.Close(); err != nil {
+		return err
+	}
+	if n := s.failed.Load(); n > 0 {
+		return fmt.Errorf("--kafka-broker: %d matches could not be delivered", n)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// recordingKafka collects the messages a kafkaSink writes.
+type recordingKafka struct {
+	msgs   []kafka.Message
+	closed bool
+}
+
+func (r *recordingKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.msgs = append(r.msgs, msgs...)
+	return nil
+}
+
+func (r *recordingKafka) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	fixed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		partitionKey, wantSize string
+	}{
+		{"path", ""},
+		{"size", "8192"},
+		{"random", ""},
+	} {
+		rec := &recordingKafka{}
+		sink := &kafkaSink{ctx: context.Background(), w: rec, key: tc.partitionKey, now: func() time.Time { return fixed }}
+		if err := sink.Add(matchResult{Path: "/data/app.db", Size: 8192}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil || !rec.closed {
+			t.Fatalf("expected the writer to be closed, got %v", err)
+		}
+		if len(rec.msgs) != 1 {
+			t.Fatalf("expected one message, got %d", len(rec.msgs))
+		}
+		if got := string(rec.msgs[0].Key); got != "/data/app.db" {
+			t.Fatalf("%s: expected the path as key, got %q", tc.partitionKey, got)
+		}
+		var size string
+		for _, h := range rec.msgs[0].Headers {
+			if h.Key == kafkaSizeHeader {
+				size = string(h.Value)
+			}
+		}
+		if size != tc.wantSize {
+			t.Fatalf("%s: expected size header %q, got %q", tc.partitionKey, tc.wantSize, size)
+		}
+		var ev streamEvent
+		if err := json.Unmarshal(rec.msgs[0].Value, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Path != "/data/app.db" || ev.Size != 8192 || ev.Timestamp != "2024-05-01T12:00:00Z" {
+			t.Fatalf("unexpected message %+v", ev)
+		}
+	}
+}
+
+func TestKafkaBalancerPartitionsBySize(t *testing.T) {
+	balancer, err := kafkaBalancer("size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &kafkaSink{key: "size"}
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	sizes := map[int64]int{}
+	for i, p := range []string{"/a.db", "/b.db", "/c/d.db", "/e.db"} {
+		size := int64(4096 * (1 + i%2))
+		got := balancer.Balance(sink.message(matchResult{Path: p, Size: size}, nil), partitions...)
+		if want, ok := sizes[size]; ok && got != want {
+			t.Fatalf("%s: expected size %d on partition %d, got %d", p, size, want, got)
+		}
+		sizes[size] = got
+	}
+}
+
+func TestNewKafkaSinkValidates(t *testing.T) {
+	ctx := context.Background()
+	if _, err := newKafkaSink(ctx, nil, "matches", "path", nil); err == nil {
+		t.Fatal("expected an error without a broker")
+	}
+	if _, err := newKafkaSink(ctx, []string{"localhost:9092"}, "", "path", nil); err == nil {
+		t.Fatal("expected an error without a topic")
+	}
+	if _, err := newKafkaSink(ctx, []string{"localhost:9092"}, "matches", "mtime", nil); err == nil {
+		t.Fatal("expected an error for an unknown partition key")
+	}
+}
+
+// failingKafka is a broker that never accepts a batch: like the async
+// kafka.Writer, it reports the failure through the sink's Completion
+// callback when the queue is flushed.
+type failingKafka struct {
+	sink   *kafkaSink
+	queued []kafka.Message
+}
+
+func (f *failingKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.queued = append(f.queued, msgs...)
+	return nil
+}
+
+func (f *failingKafka) Close() error {
+	f.sink.delivered(f.queued, errors.New("leader not available"))
+	return nil
+}
+
+func TestKafkaSinkReportsFailedDeliveries(t *testing.T) {
+	errs := make(chan error, 4)
+	sink := &kafkaSink{ctx: context.Background(), key: "path", now: time.Now, errs: errs}
+	sink.w = &failingKafka{sink: sink}
+	for _, p := range []string{"/data/a.db", "/data/b.db"} {
+		if err := sink.Add(matchResult{Path: p}); err != nil {
+			t.Fatalf("Add should not fail before delivery, got %v", err)
+		}
+	}
+	err := sink.Close()
+	if err == nil || !strings.Contains(err.Error(), "2 matches could not be delivered") {
+		t.Fatalf("expected Close to report 2 failed deliveries, got %v", err)
+	}
+	for _, p := range []string{"/data/a.db", "/data/b.db"} {
+		err := <-errs
+		if !strings.Contains(err.Error(), p) || !strings.Contains(err.Error(), "leader not available") {
+			t.Fatalf("expected an error for %s, got %v", p, err)
+		}
+	}
+	if closeSinks([]matchSink{sink}, slog.New(slog.NewTextHandler(io.Discard, nil))) == nil {
+		t.Fatal("expected closeSinks to report the failure")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rootLabels resolves --label values into a label for every root, keyed by
+// the resolved root that scanPaths walks. A value of the form ROOT=LABEL
+// names one root; a bare LABEL applies to every root not named that way.
+// Roots with neither are labelled with the path as given on the command
+// line.
+func rootLabels(roots []string, values []string) (map[string]string, error) {
+	named := make(map[string]string)
+	fallback := ""
+	for _, v := range values {
+		root, label, ok := strings.Cut(v, "=")
+		if !ok {
+			if fallback != "" {
+				return nil, fmt.Errorf("only one --label without ROOT= may be given")
+			}
+			fallback = v
+			continue
+		}
+		if label == "" {
+			return nil, fmt.Errorf("empty label for %s", root)
+		}
+		named[resolveRoots([]string{root})[0]] = label
+	}
+
+	labels := make(map[string]string, len(roots))
+	for _, root := range roots {
+		key := resolveRoots([]string{root})[0]
+		if _, ok := labels[key]; ok {
+			continue
+		}
+		switch {
+		case named[key] != "":
+			labels[key] = named[key]
+			delete(named, key)
+		case fallback != "":
+			labels[key] = fallback
+		default:
+			labels[key] = root
+		}
+	}
+	for root := range named {
+		return nil, fmt.Errorf("%s is not one of the roots being scanned", root)
+	}
+	return labels, nil
+}
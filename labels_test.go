@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRootLabels(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+
+	labels, err := rootLabels([]string{a, b}, []string{a + "=prod"})
+	if err != nil {
+		t.Fatalf("rootLabels: %v", err)
+	}
+	if labels[a] != "prod" || labels[b] != b {
+		t.Fatalf("expected prod and the root path as default, got %v", labels)
+	}
+
+	labels, err = rootLabels([]string{a, b}, []string{"all", b + "=backup"})
+	if err != nil {
+		t.Fatalf("rootLabels: %v", err)
+	}
+	if labels[a] != "all" || labels[b] != "backup" {
+		t.Fatalf("expected bare label as fallback, got %v", labels)
+	}
+
+	if _, err := rootLabels([]string{a}, []string{"/not/a/root=x"}); err == nil {
+		t.Fatalf("expected error for label on unknown root")
+	}
+	if _, err := rootLabels([]string{a}, []string{"x", "y"}); err == nil {
+		t.Fatalf("expected error for two bare labels")
+	}
+}
+
+func TestScanPathsLabelsMatchesByRoot(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	for _, dir := range []string{a, b} {
+		if err := os.WriteFile(filepath.Join(dir, "x.db"), sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	labels, err := rootLabels([]string{a, b}, []string{a + "=first", b + "=second"})
+	if err != nil {
+		t.Fatalf("rootLabels: %v", err)
+	}
+
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Labels: labels}
+	if err := scanPaths(context.Background(), []string{a, b}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	var got []string
+	for m := range matches {
+		got = append(got, formatPlainMatch(m, outputOptions{ShowLabel: true}))
+	}
+	sort.Strings(got)
+	want := []string{
+		"[first] " + filepath.Join(a, "x.db"),
+		"[second] " + filepath.Join(b, "x.db"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	line := formatJSONLine(matchResult{Path: "/x.db", Label: "first"}, outputOptions{JSONL: true, ShowLabel: true})
+	if line != `{"path":"/x.db","label":"first"}` {
+		t.Fatalf("unexpected JSONL: %s", line)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// ranksBelow reports whether a sorts after b in --largest output: it is
+// smaller, or the same size with a path that sorts later. Breaking ties by
+// path keeps the output the same whichever order the workers found the
+// matches in.
+func ranksBelow(a, b matchResult) bool {
+	if a.Size != b.Size {
+		return a.Size < b.Size
+	}
+	return a.Path > b.Path
+}
+
+// sizeHeap is a min-heap of matches by rank, so the lowest ranked of the
+// current top N is the one to evict.
+type sizeHeap []matchResult
+
+func (h sizeHeap) Len() int           { return len(h) }
+func (h sizeHeap) Less(i, j int) bool { return ranksBelow(h[i], h[j]) }
+func (h sizeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *sizeHeap) Push(x any)        { *h = append(*h, x.(matchResult)) }
+func (h *sizeHeap) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
+
+// largestMatches implements --largest: it keeps the n biggest matches from
+// in, holding no more than n at a time, and forwards them largest first
+// once in is closed. Matches of equal size are ranked by path.
+func largestMatches(in <-chan matchResult, n int) <-chan matchResult {
+	out := make(chan matchResult, cap(in))
+	go func() {
+		defer close(out)
+		h := make(sizeHeap, 0, n)
+		for m := range in {
+			if h.Len() < n {
+				heap.Push(&h, m)
+			} else if ranksBelow(h[0], m) {
+				h[0] = m
+				heap.Fix(&h, 0)
+			}
+		}
+		sort.Slice(h, func(i, j int) bool { return ranksBelow(h[j], h[i]) })
+		for _, m := range h {
+			out <- m
+		}
+	}()
+	return out
+}
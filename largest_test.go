@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLargestMatches(t *testing.T) {
+	root := t.TempDir()
+	for name, size := range map[string]int{"small.db": 100, "huge.db": 5000, "medium.db": 800, "big.db": 3000} {
+		content := append(append([]byte{}, sqliteMagic...), make([]byte, size)...)
+		if err := os.WriteFile(filepath.Join(root, name), content, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	matches := make(chan matchResult, 8)
+	errs := make(chan error, 8)
+	opts := scanOptions{Workers: 3, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	var got []string
+	for m := range largestMatches(matches, 2) {
+		got = append(got, filepath.Base(m.Path))
+	}
+	if want := []string{"huge.db", "big.db"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLargestMatchesBreaksTiesByPath(t *testing.T) {
+	orders := [][]string{
+		{"/d/c.db", "/d/a.db", "/d/b.db", "/d/big.db"},
+		{"/d/big.db", "/d/b.db", "/d/c.db", "/d/a.db"},
+		{"/d/a.db", "/d/b.db", "/d/big.db", "/d/c.db"},
+	}
+	for _, order := range orders {
+		in := make(chan matchResult, len(order))
+		for _, p := range order {
+			size := int64(4096)
+			if p == "/d/big.db" {
+				size = 8192
+			}
+			in <- matchResult{Path: p, Size: size}
+		}
+		close(in)
+		var got []string
+		for m := range largestMatches(in, 3) {
+			got = append(got, m.Path)
+		}
+		if want := []string{"/d/big.db", "/d/a.db", "/d/b.db"}; !slices.Equal(got, want) {
+			t.Fatalf("found in order %v: expected %v, got %v", order, want, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const lockCheckSupported = true
+
+// fileLocked reports whether another process holds a flock on f by trying
+// to take an exclusive lock without blocking, releasing it at once when
+// that succeeds.
+func fileLocked(f *os.File) (bool, error) {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
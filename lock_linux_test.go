@@ -0,0 +1,77 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestHelperHoldLock is not a real test: TestLockCheck runs the test binary
+// again with this test selected to get a separate process holding a write
+// lock until its stdin is closed.
+func TestHelperHoldLock(t *testing.T) {
+	path := os.Getenv("SQLITE_SCANNER_HOLD_LOCK")
+	if path == "" {
+		t.Skip("helper process only")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	lk := unix.Flock_t{Type: unix.F_WRLCK}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	os.Stdout.WriteString("locked\n")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+func TestLockCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "busy.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	res, ok, err := checkSQLiteMagic(path, checkOptions{LockCheck: true})
+	if err != nil || !ok || res.Locked {
+		t.Fatalf("expected unlocked match, got %+v ok=%v err=%v", res, ok, err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperHoldLock$")
+	cmd.Env = append(os.Environ(), "SQLITE_SCANNER_HOLD_LOCK="+path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("stdin: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("stdout: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer func() {
+		stdin.Close()
+		cmd.Wait()
+	}()
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "locked\n" {
+		t.Fatalf("helper did not take the lock: %q %v", line, err)
+	}
+
+	res, ok, err = checkSQLiteMagic(path, checkOptions{LockCheck: true})
+	if err != nil || !ok || !res.Locked {
+		t.Fatalf("expected locked match, got %+v ok=%v err=%v", res, ok, err)
+	}
+	if got := formatPlainMatch(res, outputOptions{ShowLocked: true}); got != path+" [LOCKED]" {
+		t.Fatalf("unexpected plain output %q", got)
+	}
+	if got := formatJSONLine(res, outputOptions{JSONL: true, ShowLocked: true}); got != `{"path":`+marshalJSON(path, "", "")+`,"locked":true}` {
+		t.Fatalf("unexpected JSONL %s", got)
+	}
+}
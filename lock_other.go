@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+const lockCheckSupported = false
+
+func fileLocked(f *os.File) (bool, error) {
+	return false, errors.New("--lock-check is not supported on this platform")
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+const lockCheckSupported = true
+
+// fileLocked reports whether another process has locked part of f by
+// trying to take an exclusive lock on the whole file without waiting,
+// releasing it at once when that succeeds.
+func fileLocked(f *os.File) (bool, error) {
+	h := windows.Handle(f.Fd())
+	ol := new(windows.Overlapped)
+	err := windows.LockFileEx(h, windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, ^uint32(0), ^uint32(0), ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, windows.UnlockFileEx(h, 0, ^uint32(0), ^uint32(0), ol)
+}
@@ -2,34 +2,392 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"iter"
+	"log/slog"
+	"math/rand/v2"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/spf13/pflag"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var sqliteMagic = []byte("SQLite format 3\x00")
 var version = "dev"
 
 type matchResult struct {
-	Path string
-	Size int64
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// SchemaFormat is the header's schema format number (1-4), or 0 when
+	// the file is too short to contain a full header.
+	SchemaFormat uint8
+	// FreelistPages is the header's count of unused pages (offset 36),
+	// which VACUUM would reclaim.
+	FreelistPages int
+	// PageCount is the database size in pages: the header's count at
+	// offset 28 when it is valid, else the file size divided by PageSize
+	// (see estimatePageCount), or 0 when neither is known.
+	PageCount int
+	PageSize  int
+	// Tables is the number of user tables in sqlite_master with
+	// --min-tables, or -1 when the schema could not be parsed.
+	Tables int
+	// JournalMode is journalWAL or journalRollback as recorded in the
+	// header's version bytes, or journalUnknown.
+	JournalMode string
+	// AutoVacuum is set when the header's largest root b-tree page,
+	// LargestRootPage, is nonzero, meaning auto_vacuum is enabled;
+	// IncrementalVacuum when it is in incremental mode.
+	AutoVacuum        bool
+	IncrementalVacuum bool
+	LargestRootPage   uint32
+	// SQLiteVersion is the version of the SQLite library that last wrote
+	// the file, from header offset 96 (not the one that created it), or
+	// "" when unknown. UserVersion and ApplicationID are the header's
+	// user_version and application_id, which applications set to
+	// identify their files (--sqlite-version).
+	SQLiteVersion string
+	UserVersion   int32
+	ApplicationID uint32
+	// VersionStale is set when the version-valid-for number (offset 92)
+	// differs from the change counter (offset 24): a library older than
+	// 3.7.0 changed the file since SQLiteVersion wrote it.
+	VersionStale bool
+	// Openable reports whether --open-check could read the schema with
+	// the SQLite driver; OpenFailure is "locked" or "corrupt" when not.
+	Openable    bool
+	OpenFailure string
+	// Locked reports whether --lock-check found another process holding
+	// a lock that excludes readers.
+	Locked bool
+	// SharedLockCount is the number of read locks held on the file
+	// (--read-only-check, Linux only).
+	SharedLockCount int
+	// Mode holds the file's permission bits from the stat (--mode), or 0
+	// when unknown, as for remote objects.
+	Mode fs.FileMode
+	// UID and GID own the file, recorded with --owner or --group; -1
+	// when unknown, as for remote objects and archive members.
+	UID int
+	GID int
+	// Kind is kindDatabase, kindWAL for a write-ahead log matched by
+	// --wal-files, or kindPlaceholder for an empty file reported by
+	// --include-zero-size.
+	Kind string
+	// Label names the root the match was found under (--label).
+	Label string
+	// SHA256 is the hex digest of the whole file when --hash is set.
+	SHA256 string
+	// Blake3 is the hex BLAKE3 digest of the whole file, computed instead
+	// of SHA256 with --blake3. The two are never compared with each other.
+	Blake3 string
+	// HashPartial is set when --hash-max-bytes stopped hashing after
+	// HashedBytes bytes, so SHA256 only covers a prefix of the file.
+	HashPartial bool
+	HashedBytes int64
+	// Event is set, to eventRemoved, only on the reports --inotify-watch
+	// sends when a matched file disappears; it is empty for matches.
+	Event string
+}
+
+// String returns the match as a plain output line without any optional
+// fields: its absolute path, or the URI of a remote object.
+func (m matchResult) String() string {
+	return formatPlainMatch(m, outputOptions{})
+}
+
+// MarshalText returns String, for use as a text value such as a log
+// attribute or map key.
+func (m matchResult) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// MarshalJSON encodes every field, as --cache-file stores matches; without
+// it encoding/json would prefer MarshalText and write only the path.
+func (m matchResult) MarshalJSON() ([]byte, error) {
+	type plain matchResult
+	return json.Marshal(plain(m))
+}
+
+// matchFilter reports whether a match should be kept.
+type matchFilter func(matchResult) bool
+
+// checkOptions controls how checkSQLiteMagic inspects a single file.
+type checkOptions struct {
+	// SkipStat leaves Size at -1 instead of calling Stat on matches.
+	SkipStat bool
+	// NoATime opens files with O_NOATIME where the platform supports it.
+	NoATime bool
+	// Fadvise hints sequential access on open and drops each file from
+	// the page cache once checked (--sparse, Linux only).
+	Fadvise bool
+	// Mmap reads the header by mapping the start of each file instead of
+	// calling read (--mmap, Unix only).
+	Mmap bool
+	// Hash computes the SHA-256 of every matching file, or with Blake3
+	// its BLAKE3 digest.
+	Hash   bool
+	Blake3 bool
+	// HashMaxBytes, when > 0, stops hashing after this many bytes.
+	HashMaxBytes int64
+	// MaxWholeFileSize, when > 0, skips with errLargerThanRAM the matches
+	// larger than this that would be read whole (--skip-larger-than-ram).
+	MaxWholeFileSize int64
+	// RAMFraction is the --skip-larger-than-ram fraction MaxWholeFileSize
+	// was worked out from. The limit itself follows the memory free at
+	// startup, so only the fraction goes into cacheKey.
+	RAMFraction float64
+	// Owner records the uid and gid of every match (--owner, --group;
+	// Unix only). It needs the stat SkipStat would skip.
+	Owner bool
+	// ArchiveDepth is how many levels of tar archives nested inside each
+	// other --tar opens; 0 counts as 1 (--archive-depth).
+	ArchiveDepth int
+	// OpenCheck opens every match with the SQLite driver (--open-check).
+	OpenCheck bool
+	// CountTables parses sqlite_master on page 1 to count user tables.
+	CountTables bool
+	// WALFiles also matches standalone write-ahead log files.
+	WALFiles bool
+	// ZeroSize reports empty files with a database extension as
+	// placeholders (--include-zero-size).
+	ZeroSize bool
+	// LockCheck tests every match for a conflicting advisory lock.
+	LockCheck bool
+	// SharedLocks counts read locks on every match from /proc/locks.
+	SharedLocks bool
+	// Stats, when set, is updated with the work done for each file.
+	Stats *scanStats
+	// Retries is how many times opening and reading the header is
+	// retried after a transient error (EIO, ETIMEDOUT), waiting
+	// RetryDelay between attempts. OnRetry, when set, is told about each
+	// retry.
+	Retries    int
+	RetryDelay time.Duration
+	OnRetry    func(error)
+	// Trace, when set, carries the --otel-endpoint scan span; each
+	// check is recorded as a child span of it.
+	Trace context.Context
+}
+
+// cacheKey identifies the options that change what checkSQLiteMagic
+// records, so --cache-file entries made with different options are not
+// reused.
+func (o checkOptions) cacheKey() string {
+	return fmt.Sprintf("stat=%t hash=%t blake3=%t hashmax=%d open=%t tables=%t wal=%t owner=%t zero=%t ramfraction=%g", !o.SkipStat, o.Hash, o.Blake3, o.HashMaxBytes, o.OpenCheck, o.CountTables, o.WALFiles, o.Owner, o.ZeroSize, o.RAMFraction)
+}
+
+// scanStats holds counters shared by all workers of a scan.
+type scanStats struct {
+	// FilesExamined counts regular files reached by the walk, including
+	// those then skipped by --since.
+	FilesExamined atomic.Int64
+	FilesChecked  atomic.Int64
+	BytesRead     atomic.Int64
+}
+
+// scanOptions controls how scanPaths walks roots and which files it checks.
+type scanOptions struct {
+	Workers int
+	Logger  *slog.Logger
+	// Since, when non-zero, skips files whose mtime is not after it.
+	Since time.Time
+	Check checkOptions
+	// Cache, when set, skips unchanged directories (see --cache-file).
+	Cache *scanCache
+	// Filters drop matches that any filter rejects.
+	Filters []matchFilter
+	// Denied, when set, collects paths skipped for lack of permission.
+	Denied *deniedPaths
+	// Deterministic walks roots one after another and checks each file
+	// inline, so matches are produced in a stable, lexical order.
+	Deterministic bool
+	// NewestFirst offers the files of each directory to the workers most
+	// recently modified first (--scan-order=mtime).
+	NewestFirst bool
+	// Watch keeps reporting new databases under the roots after the
+	// initial scan, until ctx is cancelled.
+	Watch bool
+	// InotifyWatch is Watch on the raw Linux inotify API, also reporting
+	// matched files that are removed (--inotify-watch).
+	InotifyWatch bool
+	// Buffer bounds the paths remembered for InotifyWatch (--max-buffer).
+	Buffer *bufferLimit
+	// OneFileSystem stops the walk from descending into directories on a
+	// different device than the root they were reached from.
+	OneFileSystem bool
+	// Labels maps each root to the label attached to its matches.
+	Labels map[string]string
+	// Extensions, when set, counts the extension of every regular file
+	// the walk finds, database or not (--extensions-report).
+	Extensions *extStats
+	// UTF8Paths, when set, flags file paths that are not valid UTF-8:
+	// utf8PathsWarn logs them, utf8PathsSkip also leaves them unchecked.
+	UTF8Paths string
+	// Tar looks inside tar archives for SQLite members instead of
+	// checking the archive file itself (see scanTar).
+	Tar bool
+	// ConcurrentArchives, when > 0, lets this many members of
+	// uncompressed tar archives be checked at once, on top of Workers
+	// (--concurrent-archives).
+	ConcurrentArchives int
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "decrypt" {
+		// A directory named decrypt can still be scanned as ./decrypt.
+		os.Exit(runDecrypt(os.Args[2:]))
+	}
 	root := pflag.String("path", ".", "directory to scan")
+	expandHomeFlag := pflag.Bool("expand-home", false, "expand a leading ~ or ~user in roots and --label ROOT= paths, for paths the shell did not expand (from --path=~/dir, the environment or a config file)")
 	workers := pflag.Int("workers", runtime.NumCPU(), "number of parallel workers")
 	jsonOutput := pflag.Bool("json", false, "print matches as a JSON object with an entries array")
 	size := pflag.Bool("size", false, "include the file size (bytes) in the output")
 	jsonl := pflag.Bool("jsonl", false, "emit newline-delimited JSON objects")
+	nullSafeJSON := pflag.Bool("null-safe-json", false, "with --jsonl, print a single {\"_empty\": true} line when nothing matches instead of no output at all")
 	versionFlag := pflag.Bool("version", false, "print version and exit")
+	versionCheck := pflag.Bool("version-check", false, "ask GitHub whether a newer release is available, print the answer to stderr and exit")
+	jsonSchema := pflag.Bool("json-schema", false, "print the JSON Schema for the selected output format and exit")
+	logLevel := pflag.String("log-level", "warn", "stderr log level: debug, info, warn, or error")
+	output := pflag.String("output", "", "write results to FILE instead of stdout (replaced atomically when the scan finishes)")
+	parquetOutput := pflag.Bool("parquet", false, "write matches as a Parquet file to --output")
+	encryptOutput := pflag.String("encrypt-output", "", "encrypt the output with AES-256-GCM using the 32-byte key in KEYFILE (read it back with: sqlite-scanner decrypt KEYFILE FILE)")
+	gzipOutput := pflag.Bool("gzip-output", false, "gzip the output as it is written, to stdout or to --output (like --compress-output gzip)")
+	compressOutput := pflag.String("compress-output", "", "compress the --output file with gzip or zstd, adding .gz or .zst to its name")
+	outputParquet := pflag.String("output-parquet", "", "write matches as a Parquet file to FILE (short for --parquet --output FILE)")
+	dbOutput := pflag.String("db-output", "", "also insert matches into a SQLite database at FILE (table: matches)")
+	noStat := pflag.Bool("no-stat", false, "skip the per-match stat call when sizes are not needed (faster on high-latency filesystems)")
+	sparse := pflag.Bool("sparse", false, "use posix_fadvise to read files sequentially and drop them from the page cache once checked, easing cache pressure on huge scans (Linux only)")
+	mmapFlag := pflag.Bool("mmap", false, "read each header by memory-mapping the first page of the file instead of calling read (Unix only)")
+	touchATime := pflag.Bool("touch-access-time", true, "allow reads to update file access times; set to false to open with O_NOATIME (Linux)")
+	affinity := pflag.String("workers-affinity", "", "advanced: pin the process to CPUS, e.g. 0-3,8-11 (Linux only)")
+	cacheFile := pflag.String("cache-file", "", "remember per-directory results in FILE and skip directories whose mtime is unchanged")
+	schemaFormat := pflag.Bool("schema-format", false, "include the header's schema format number (1-4) in the output")
+	minSchema := pflag.Int("min-schema-version", 0, "only report databases whose schema format number is at least N")
+	maxSchema := pflag.Int("max-schema-version", 0, "only report databases whose schema format number is at most N")
+	readOnlyCheck := pflag.Bool("read-only-check", false, "count the shared (reader) locks other processes hold on each match, from /proc/locks (Linux only)")
+	jsonIndent := pflag.Int("json-indent", 2, "spaces per indentation level in --json output; 0 prints the document on one line")
+	jsonKey := pflag.String("json-key", defaultJSONKey, "name of the array of matches in --json output")
+	lockCheck := pflag.Bool("lock-check", false, "report whether another process holds a lock on each match (a writer); Linux, macOS and Windows")
+	countFirst := pflag.Bool("count-first", false, "count files with a quick walk first, then print progress and an ETA to stderr every second")
+	progressJSON := pflag.Bool("progress-json", false, "write {\"examined\": N, \"matched\": M, \"elapsed_ms\": T} progress lines to stderr every second, and a final one when the scan ends")
+	progressFD := pflag.Int("progress-fd", 2, "file descriptor --progress-json writes to instead of stderr")
+	openCheckFlag := pflag.Bool("open-check", false, "open each match with the SQLite driver and report whether it is usable (slower)")
+	trimPrefix := pflag.String("trim-prefix", "", "strip this directory from the start of every printed path, e.g. /data/exports turns /data/exports/a/app.db into a/app.db; other paths are printed unchanged")
+	cwdRelative := pflag.Bool("cwd-relative", false, "print paths relative to the current directory instead of absolute")
+	hostFlag := pflag.String("host", "", "add a host field with this name to every JSON entry, and prefix plain paths as HOST:path, for aggregating scans from several machines")
+	resolveHostname := pflag.Bool("resolve-hostname", false, "like --host, using this machine's hostname")
+	labelFlags := pflag.StringArray("label", nil, "add a label to each match: LABEL for every root or ROOT=LABEL for one (repeatable); unnamed roots are labelled with their path")
+	autovacuum := pflag.Bool("autovacuum", false, "report whether each database has auto_vacuum enabled (full or incremental), read from the header's largest root b-tree page")
+	sqliteVersion := pflag.Bool("sqlite-version", false, "report the SQLite version that last wrote each database, with its user_version and application_id, all read from the header")
+	journalModeFlag := pflag.String("journal-mode", "", "only report databases whose header records this journaling mode: wal or rollback; adds the mode to the output")
+	minTables := pflag.Int("min-tables", 0, "only report databases with at least N user tables, counted from sqlite_master without a driver; adds the count to the output")
+	minPageCount := pflag.Int("min-page-count", 0, "only report databases with at least N pages, from the header (or file size / page size when the header's count is stale); adds the count to the output")
+	maxPageCount := pflag.Int("max-page-count", 0, "only report databases with at most N pages, e.g. 1 for freshly initialised ones; adds the count to the output")
+	minFreePages := pflag.Int("min-free-pages", 0, "only report databases with at least N freelist pages (candidates for VACUUM); adds the count to the output")
+	modeFlag := pflag.Bool("mode", false, "include each file's permission bits in the output (octal)")
+	worldReadable := pflag.Bool("world-readable-only", false, "only report databases that any user can read (the other-read bit is set); adds the mode to the output")
+	ownerFlag := pflag.String("owner", "", "only report files owned by this user name or numeric uid (Unix only)")
+	groupFlag := pflag.String("group", "", "only report files owned by this group name or numeric gid (Unix only)")
+	execCmd := pflag.String("exec", "", "run a command for each match; {} is replaced by the path (appended if absent)")
+	execJobs := pflag.Int("exec-jobs", runtime.NumCPU(), "maximum number of --exec commands running at once")
+	benchmarkMode := pflag.Bool("benchmark-mode", false, "discard all output and print files_checked, matches, duration_ms and files_per_sec as JSON to stderr when the scan finishes")
+	noOutput := pflag.Bool("no-output", false, "do all the scanning work but discard matches instead of printing them, then print the number found to stderr (for timing the scan without output overhead)")
+	quiet := pflag.Bool("quiet", false, "do not print matches (useful with --exec or --db-output)")
+	hash := pflag.Bool("hash", false, "include the SHA-256 of each matching file (reads whole files)")
+	extStatsFlag := pflag.Bool("ext-stats", false, "count matches per file extension and print the totals to stderr (an \"ext_stats\" field with --json)")
+	sizeBucketsFlag := pflag.Bool("count-by-size-bucket", false, "tally matches into size buckets and print the histogram to stderr (a \"size_buckets\" field with --json)")
+	sizeBucketsSpec := pflag.String("size-buckets", defaultSizeBuckets, "comma-separated increasing size boundaries for --count-by-size-bucket, e.g. 64KB,10MB,1GB")
+	skipLargerThanRAM := pflag.Float64("skip-larger-than-ram", 0, "skip, with a warning, matches larger than this fraction of available RAM (e.g. 0.5) when --hash, --blake3 or --unique-content would read them whole, instead of truncating like --hash-max-bytes (Linux only; 0 = off)")
+	hashMaxBytes := pflag.Int64("hash-max-bytes", 0, "stop hashing each file after N bytes and mark the hash as partial (0 = whole file)")
+	keep := pflag.String("keep", "first", "which path --unique-content reports per hash: first, shortest-path, newest or oldest (all but first wait for the scan to finish)")
+	blake3Flag := pflag.Bool("blake3", false, "add the BLAKE3 hash of each matching file instead of SHA-256 (faster on large files; cannot be combined with --hash)")
+	uniqueContent := pflag.Bool("unique-content", false, "report only the first path for each distinct SHA-256 (implies --hash)")
+	reportDenied := pflag.Bool("report-permission-denied", false, "list paths skipped due to permission errors on stderr when the scan ends")
+	outEncoding := pflag.String("encoding", "utf8", "output text encoding: utf8, latin1, windows1252, or utf16le")
+	truncate := pflag.Int("truncate-path", 0, "shorten displayed paths longer than N characters by replacing the start with ...")
+	oneFS := pflag.Bool("one-file-system", false, "do not descend into directories on other filesystems than the root")
+	fromMounts := pflag.Bool("roots-from-mounts", false, "scan every local, non-virtual mount point (from /proc/mounts or getmntinfo)")
+	noSummary := pflag.Bool("no-summary", false, "don't print the \"found N databases\" line to stderr when stdout is redirected")
+	awsS3 := pflag.String("aws-s3", "", "scan the objects under an s3://bucket/prefix URI instead of local paths (AWS credentials from the standard chain)")
+	gcsURI := pflag.String("gcs", "", "scan the objects under a gs://bucket/prefix URI instead of local paths (Application Default Credentials)")
+	sftpFlag := pflag.String("sftp", "", "scan user@host[:port]:/path over SFTP instead of local paths (keys from ssh-agent)")
+	sftpKey := pflag.String("sftp-key", "", "private key file for --sftp instead of ssh-agent")
+	sftpTimeout := pflag.Duration("sftp-timeout", 10*time.Second, "connection timeout for --sftp")
+	fdFrom := pflag.Int("fd-from", -1, "scan the directory open as inherited file descriptor FD instead of a path, for privilege-separated callers (Unix only)")
+	walFiles := pflag.Bool("wal-files", false, "also report standalone write-ahead log (-wal) files, marked with kind \"wal\"")
+	includeZeroSize := pflag.Bool("include-zero-size", false, "also report empty (0-byte) .db and .sqlite files, marked with kind \"placeholder\"")
+	syslogFlag := pflag.Bool("syslog", false, "also log each match to the local syslog daemon (facility daemon, priority info) for audit trails (not on Windows)")
+	journaldFlag := pflag.Bool("journald", false, "also send each match to the systemd journal as a structured entry with SQLITE_SCANNER_PATH and SQLITE_SCANNER_SIZE fields (Linux; elsewhere written to stderr)")
+	streamTo := pflag.String("stream-to", "", "send each match as JSON to this http(s) URL as it is found")
+	streamMethod := pflag.String("stream-to-method", "POST", "HTTP method for --stream-to: POST or PUT")
+	streamHeaders := pflag.StringArray("stream-to-header", nil, "extra 'Name: value' header for --stream-to requests (repeatable)")
+	streamTimeout := pflag.Duration("stream-to-timeout", 5*time.Second, "timeout for each --stream-to request")
+	statsdAddr := pflag.String("statsd-addr", "", "send files_checked and matches_found counters and a scan_duration timer to the StatsD server at HOST:PORT over UDP")
+	statsdPrefix := pflag.String("statsd-prefix", "sqlite_scanner", "prefix for --statsd-addr metric names")
+	cloudWatchFlag := pflag.Bool("cloud-watch", false, "put FilesChecked, MatchesFound, ErrorsEncountered and ScanDurationMs metrics to AWS CloudWatch once the scan ends, using the standard AWS credential chain")
+	cloudWatchNamespace := pflag.String("cloud-watch-namespace", defaultCloudWatchNamespace, "CloudWatch namespace for --cloud-watch metrics")
+	otelEndpoint := pflag.String("otel-endpoint", "", "export OpenTelemetry traces of the scan over OTLP/gRPC to this URL, e.g. https://otel.example.com:4317 (http:// for no TLS)")
+	compare := pflag.String("compare", "", "instead of listing matches, print how they differ from a scan saved earlier with --json or --jsonl: added, removed and changed (by size or hash) databases")
+	webhookSecret := pflag.String("webhook-secret", "", "sign each --stream-to body with HMAC-SHA256 using this secret, sent as an X-Signature-SHA256: sha256=HEX header")
+	natsURL := pflag.String("nats-url", "", "also publish each match as JSON to the NATS server at this URL (requires --nats-subject)")
+	natsSubject := pflag.String("nats-subject", "", "NATS subject for --nats-url")
+	natsCreds := pflag.String("nats-credentials", "", "authenticate to --nats-url with this NATS credentials (.creds) file")
+	natsRetry := pflag.Int("nats-retry", 3, "retry connecting to --nats-url up to N times with exponential back-off")
+	redisURL := pflag.String("redis", "", "also RPUSH each match as JSON onto a Redis list at this redis:// URL (requires --redis-key)")
+	redisKey := pflag.String("redis-key", "", "Redis list key for --redis")
+	redisTTL := pflag.Duration("redis-ttl", 0, "set this expiry on the --redis-key list after the scan (0 = none)")
+	etcdPrefix := pflag.String("etcd-prefix", "", "also write each match to etcd as PREFIX/matches/PATH = {\"size\":N,\"found_at\":\"RFC3339\"}, and PREFIX/_summary = {\"total\":N} at the end")
+	etcdEndpoints := pflag.StringSlice("etcd-endpoints", []string{"localhost:2379"}, "comma-separated etcd endpoints for --etcd-prefix")
+	etcdTTL := pflag.Duration("etcd-ttl", 0, "expire the --etcd-prefix keys this long after they are first written (0 = never)")
+	etcdCert := pflag.String("etcd-cert", "", "TLS client certificate for --etcd-endpoints (requires --etcd-key)")
+	etcdKey := pflag.String("etcd-key", "", "TLS client key for --etcd-endpoints")
+	etcdCA := pflag.String("etcd-ca", "", "CA certificate to verify --etcd-endpoints with; setting it or --etcd-cert connects over TLS")
+	kafkaBrokers := pflag.StringArray("kafka-broker", nil, "also produce each match as JSON to a Kafka broker at HOST:PORT (repeatable; requires --kafka-topic)")
+	kafkaTopic := pflag.String("kafka-topic", "", "Kafka topic for --kafka-broker")
+	kafkaPartitionKey := pflag.String("kafka-partition-key", "path", "what Kafka partitions --kafka-broker messages on: path, size or random")
+	dirsOnly := pflag.Bool("report-dirs-only", false, "print each directory containing a database once instead of the files (see --depth)")
+	depth := pflag.Int("depth", 1, "with --report-dirs-only, report directories at most N levels below the root")
+	retries := pflag.Int("retry", 0, "retry opening and reading a file up to N times after a transient I/O error (EIO, ETIMEDOUT)")
+	retryDelay := pflag.Duration("retry-delay", 100*time.Millisecond, "wait this long between --retry attempts")
+	maxBuffer := pflag.Int("max-buffer", 0, "stop with an error once the features that remember every match, hash or directory (--keep, --unique-content, --dedup-by, --max-matches-per-dir, --report-dirs-only, --inotify-watch) hold N entries in total, instead of growing without bound (0 = no limit)")
+	maxPerDir := pflag.Int("max-matches-per-dir", 0, "report at most N databases from any one directory (0 = no limit)")
+	validateUTF8 := pflag.Bool("validate-utf8-paths", false, "warn on stderr about every file whose path is not valid UTF-8, which some JSON consumers choke on")
+	skipInvalidUTF8 := pflag.Bool("skip-invalid-utf8-paths", false, "like --validate-utf8-paths, but also skip those files")
+	dedupBy := pflag.String("dedup-by", "", "report each file once when roots overlap: path, or path-ci to compare paths case-insensitively (macOS)")
+	extReport := pflag.Bool("extensions-report", false, "count the extensions of every file walked, not just matches, and print the totals to stderr after the scan")
+	echoConfig := pflag.Bool("echo-config", false, "with --json, add a \"config\" object recording the roots and every flag's effective value")
+	tarFlag := pflag.Bool("tar", false, "look inside .tar, .tar.gz, .tgz and .tar.bz2 archives and report SQLite members as archive::member")
+	archiveDepth := pflag.Int("archive-depth", 1, "with --tar, open archives nested inside archives up to N levels deep (1 = only archives on disk)")
+	concurrentArchives := pflag.Int("concurrent-archives", 0, "with --tar, check up to N members of uncompressed .tar archives at once, in addition to --workers (helps on high-latency filesystems such as NFS)")
+	largest := pflag.Int("largest", 0, "report only the N largest databases, biggest first, when the scan finishes")
+	reorderWindow := pflag.Int("reorder-window", 0, "hold up to N matches and print the lexically smallest path whenever the window is full, for nearly sorted output with bounded memory (0 = print as found)")
+	sample := pflag.Int("sample", 0, "report a uniformly random N of the matches, printed when the scan finishes")
+	seed := pflag.Uint64("seed", 0, "random seed for --sample, for a reproducible sample (default: random)")
+	watch := pflag.Bool("watch", false, "after the initial scan, keep watching the roots and report new databases as they appear (stop with Ctrl-C)")
+	inotifyWatch := pflag.Bool("inotify-watch", false, "after the initial scan, use inotify to report new databases and removed ones as JSON lines (Linux only; stop with Ctrl-C)")
+	scanOrder := pflag.String("scan-order", "lexical", "order in which each directory's files are checked: lexical, or mtime for newest first")
+	deterministic := pflag.Bool("deterministic", false, "single-threaded scan in lexical order, for reproducible output and benchmarks (ignores --workers)")
+	configPath := pflag.String("config", "", "read default flag values from this TOML file instead of ~/.config/sqlite-scanner/config.toml")
+	noConfig := pflag.Bool("no-config", false, "do not read any config file")
+	envPrefix := pflag.String("env-prefix", "SQLITE_SCANNER", "read unset flags from PREFIX_FLAG_NAME environment variables (empty disables)")
+	since := pflag.String("since", "", "only report files modified after this reference file's mtime, then touch it on success")
 
 	pflag.Usage = func() {
 		out := os.Stdout
@@ -39,6 +397,7 @@ func main() {
 		fmt.Fprintln(out)
 		fmt.Fprintln(out, "Usage:")
 		fmt.Fprintln(out, "  sqlite-scanner [flags] [paths...] (flags accept --flag form anywhere)")
+		fmt.Fprintln(out, "  sqlite-scanner decrypt KEYFILE [FILE] (decrypt --encrypt-output results to stdout)")
 		fmt.Fprintln(out)
 		fmt.Fprintln(out, "Flags (use --flag form):")
 		pflag.PrintDefaults()
@@ -49,9 +408,16 @@ func main() {
 		fmt.Fprintln(out, "  sqlite-scanner /tmp ~")
 		fmt.Fprintln(out, "  sqlite-scanner --workers 16 /tmp")
 		fmt.Fprintln(out, "  sqlite-scanner --json")
+		fmt.Fprintln(out, "  sqlite-scanner --since /var/state/last-scan /srv")
+		fmt.Fprintln(out, "  sqlite-scanner --parquet --output scan.parquet /data")
+		fmt.Fprintln(out, "  sqlite-scanner --db-output scan.db /data")
+		fmt.Fprintln(out, "  sqlite-scanner --quiet --exec 'cp {} /backup/' /data")
+		fmt.Fprintln(out, "  sqlite-scanner --max-schema-version 1 --schema-format ~")
+		fmt.Fprintln(out, "  sqlite-scanner --json-schema --jsonl --size")
 		fmt.Fprintln(out)
 		fmt.Fprintln(out, "Notes:")
 		fmt.Fprintln(out, "  - Matches files with header bytes: \"SQLite format 3\\x00\".")
+		fmt.Fprintln(out, "  - Files holding only the magic (under 100 bytes) match, with header fields reported as 0.")
 		fmt.Fprintln(out, "  - Permission-denied paths are skipped.")
 		fmt.Fprintln(out, "  - Worker pool is controlled by `--workers`.")
 		fmt.Fprintln(out, "  - Output is streamed as entries are discovered.")
@@ -59,147 +425,1695 @@ func main() {
 	}
 
 	pflag.Parse()
+	// --version-check contacts GitHub, so only the command line can ask
+	// for it, not a config file or the environment.
+	checkForUpdate := *versionCheck
+
+	// Precedence is command line, then environment, then config file:
+	// each step only fills flags that are still unset.
+	if *envPrefix != "" {
+		if err := applyEnv(pflag.CommandLine, *envPrefix); err != nil {
+			fmt.Fprintf(os.Stderr, "environment: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if !*noConfig {
+		path, mustExist := *configPath, true
+		if path == "" {
+			path, mustExist = defaultConfigPath(), false
+		}
+		if path != "" {
+			if err := applyConfigFile(pflag.CommandLine, path, mustExist); err != nil {
+				fmt.Fprintf(os.Stderr, "config: %v\n", err)
+				os.Exit(2)
+			}
+		}
+	}
 
 	if *versionFlag {
 		fmt.Println(version)
 		return
 	}
-
-	positions := pflag.Args()
-	roots := positions
-	if len(roots) == 0 {
-		roots = []string{*root}
+	if checkForUpdate {
+		// A failed check is not worth an error: offline machines
+		// simply get no answer.
+		if err := checkVersion(context.Background(), os.Stderr, latestReleaseURL, version); err != nil {
+			fmt.Fprintf(os.Stderr, "version check skipped: %v\n", err)
+		}
+		return
+	}
+
+	outOpts := outputOptions{
+		JSON:             *jsonOutput,
+		JSONL:            *jsonl,
+		ShowSize:         *size,
+		ShowSchemaFormat: *schemaFormat,
+		ShowFreelist:     *minFreePages > 0,
+		ShowPageCount:    *minPageCount > 0 || *maxPageCount > 0,
+		ShowJournalMode:  *journalModeFlag != "",
+		ShowAutoVacuum:   *autovacuum,
+		ShowVersion:      *sqliteVersion,
+		ShowMode:         *modeFlag || *worldReadable,
+		ShowTables:       *minTables > 0,
+		ShowHash:         (*hash || *uniqueContent) && !*blake3Flag,
+		ShowBlake3:       *blake3Flag,
+		ShowLabel:        len(*labelFlags) > 0,
+		ShowOpenable:     *openCheckFlag,
+		ShowLocked:       *lockCheck,
+		ShowSharedLocks:  *readOnlyCheck,
+		ShowKind:         *walFiles || *includeZeroSize,
+		TruncatePath:     *truncate,
+	}
+	// needsStat is set by every feature that reads a match's size, mtime,
+	// owner or mode, so --no-stat only skips the stat when none is on.
+	needsStat := outOpts.ShowSize || outOpts.ShowMode
+	if err := validateJSONKey(*jsonKey); err != nil {
+		fmt.Fprintf(os.Stderr, "--json-key: %v\n", err)
+		os.Exit(2)
+	}
+	outOpts.JSONKey = *jsonKey
+	if *nullSafeJSON {
+		if !*jsonOutput && !*jsonl {
+			fmt.Fprintln(os.Stderr, "--null-safe-json requires --jsonl or --json")
+			os.Exit(2)
+		}
+		// --json already prints an empty entries array.
+		outOpts.NullSafe = *jsonl
+	}
+	if *jsonIndent < 0 || *jsonIndent > 16 {
+		fmt.Fprintln(os.Stderr, "--json-indent must be between 0 and 16")
+		os.Exit(2)
+	}
+	outOpts.JSONIndent = *jsonIndent
+	outOpts.JSONCompact = *jsonIndent == 0
+	if *cwdRelative {
+		cwd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--cwd-relative: %v\n", err)
+			os.Exit(2)
+		}
+		outOpts.RelativeTo = cwd
+	}
+	if *trimPrefix != "" {
+		if *cwdRelative {
+			fmt.Fprintln(os.Stderr, "--trim-prefix cannot be combined with --cwd-relative")
+			os.Exit(2)
+		}
+		// Paths are made absolute before the prefix is removed, so a
+		// relative prefix is resolved the same way.
+		outOpts.TrimPrefix = filepath.Clean(formatPath(*trimPrefix))
+		if isRemotePath(*trimPrefix) {
+			outOpts.TrimPrefix = *trimPrefix
+		}
+	}
+	outOpts.Host = *hostFlag
+	if *resolveHostname {
+		if *hostFlag != "" {
+			fmt.Fprintln(os.Stderr, "--resolve-hostname cannot be combined with --host")
+			os.Exit(2)
+		}
+		name, err := os.Hostname()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--resolve-hostname: %v\n", err)
+			os.Exit(2)
+		}
+		outOpts.Host = name
+	}
+	if *truncate < 0 || (*truncate > 0 && *truncate < 4) {
+		fmt.Fprintln(os.Stderr, "--truncate-path must be at least 4")
+		os.Exit(2)
+	}
+
+	if pflag.CommandLine.Changed("depth") && !*dirsOnly {
+		fmt.Fprintln(os.Stderr, "--depth requires --report-dirs-only")
+		os.Exit(2)
+	}
+	if *dirsOnly {
+		if *depth < 0 {
+			fmt.Fprintln(os.Stderr, "--depth cannot be negative")
+			os.Exit(2)
+		}
+		// Only the directory is reported, so per-file fields are
+		// dropped from the output.
+		outOpts = outputOptions{
+			JSON:         outOpts.JSON,
+			JSONL:        outOpts.JSONL,
+			NullSafe:     outOpts.NullSafe,
+			ShowLabel:    outOpts.ShowLabel,
+			Host:         outOpts.Host,
+			TruncatePath: outOpts.TruncatePath,
+			RelativeTo:   outOpts.RelativeTo,
+			TrimPrefix:   outOpts.TrimPrefix,
+			JSONKey:      outOpts.JSONKey,
+			JSONIndent:   outOpts.JSONIndent,
+			JSONCompact:  outOpts.JSONCompact,
+		}
+	}
+
+	if *jsonSchema {
+		b, _ := json.MarshalIndent(outputSchema(outOpts), "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+
+	logger, err := newLogger(os.Stderr, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	positions := pflag.Args()
+	roots := positions
+	// --fd-from is local, but like the remote scans it has no paths to
+	// hand to the file-based checks.
+	remoteFlags := []string{"aws-s3", "gcs", "sftp", "fd-from"}
+	if remote := firstSet(pflag.CommandLine, remoteFlags); remote != "" {
+		n := 0
+		for _, name := range remoteFlags {
+			if pflag.CommandLine.Changed(name) {
+				n++
+			}
+		}
+		if n > 1 {
+			fmt.Fprintln(os.Stderr, "only one of --aws-s3, --gcs, --sftp and --fd-from can be given")
+			os.Exit(2)
+		}
+		if len(positions) > 0 || *fromMounts {
+			fmt.Fprintf(os.Stderr, "--%s cannot be combined with local paths or --roots-from-mounts\n", remote)
+			os.Exit(2)
+		}
+		if remote == "fd-from" && !fdFromSupported {
+			fmt.Fprintln(os.Stderr, "--fd-from is only supported on Unix")
+			os.Exit(2)
+		}
+		if name := firstSet(pflag.CommandLine, localOnlyFlags); name != "" {
+			fmt.Fprintf(os.Stderr, "--%s does not apply to --%s\n", name, remote)
+			os.Exit(2)
+		}
+	}
+	if *fromMounts {
+		mounts, err := mountRoots()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--roots-from-mounts: %v\n", err)
+			os.Exit(2)
+		}
+		roots = append(roots, mounts...)
+	}
+	if len(roots) == 0 {
+		roots = []string{*root}
+	}
+	if *expandHomeFlag {
+		roots, err = expandHomeAll(roots)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--expand-home: %v\n", err)
+			os.Exit(2)
+		}
+		for i, v := range *labelFlags {
+			if r, label, ok := strings.Cut(v, "="); ok {
+				if r, err = expandHome(r); err != nil {
+					fmt.Fprintf(os.Stderr, "--expand-home: %v\n", err)
+					os.Exit(2)
+				}
+				(*labelFlags)[i] = r + "=" + label
+			}
+		}
+	}
+	var labels map[string]string
+	if len(*labelFlags) > 0 {
+		labels, err = rootLabels(roots, *labelFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--label: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	roots = resolveRoots(roots)
+	if *echoConfig {
+		if !*jsonOutput {
+			fmt.Fprintln(os.Stderr, "--echo-config requires --json")
+			os.Exit(2)
+		}
+		configRoots := roots
+		if firstSet(pflag.CommandLine, remoteFlags) != "" {
+			configRoots = nil
+		}
+		outOpts.Config = scanConfig(pflag.CommandLine, configRoots)
+	}
+
+	if *workers <= 0 {
+		fmt.Fprintln(os.Stderr, "workers must be > 0")
+		os.Exit(2)
+	}
+	if *affinity != "" {
+		cpus, err := parseCPUList(*affinity)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--workers-affinity: %v\n", err)
+			os.Exit(2)
+		}
+		if err := setCPUAffinity(cpus); err != nil {
+			fmt.Fprintf(os.Stderr, "--workers-affinity: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *outputParquet != "" {
+		if *output != "" && *output != *outputParquet {
+			fmt.Fprintln(os.Stderr, "--output-parquet cannot be combined with a different --output")
+			os.Exit(2)
+		}
+		*output = *outputParquet
+		*parquetOutput = true
+	}
+	var encryptKey []byte
+	if *encryptOutput != "" {
+		encryptKey, err = readKeyFile(*encryptOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--encrypt-output: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *gzipOutput {
+		if *compressOutput != "" && *compressOutput != "gzip" {
+			fmt.Fprintln(os.Stderr, "--gzip-output cannot be combined with --compress-output "+*compressOutput)
+			os.Exit(2)
+		}
+		*compressOutput = "gzip"
+	}
+	if *compressOutput != "" {
+		if _, ok := compressSuffixes[*compressOutput]; !ok {
+			fmt.Fprintln(os.Stderr, "--compress-output must be gzip or zstd")
+			os.Exit(2)
+		}
+		if *output == "" && !*gzipOutput {
+			fmt.Fprintln(os.Stderr, "--compress-output requires --output FILE")
+			os.Exit(2)
+		}
+		if *parquetOutput {
+			// Parquet compresses its own column chunks.
+			fmt.Fprintln(os.Stderr, "--compress-output and --gzip-output cannot be combined with --parquet")
+			os.Exit(2)
+		}
+		if *output != "" {
+			*output = compressedName(*output, *compressOutput)
+		}
+	}
+	if *parquetOutput {
+		if *output == "" {
+			fmt.Fprintln(os.Stderr, "--parquet requires --output FILE")
+			os.Exit(2)
+		}
+		if *jsonOutput || *jsonl {
+			fmt.Fprintln(os.Stderr, "--parquet cannot be combined with --json or --jsonl")
+			os.Exit(2)
+		}
+		if *outEncoding != "utf8" {
+			fmt.Fprintln(os.Stderr, "--encoding does not apply to --parquet output")
+			os.Exit(2)
+		}
+		if *dirsOnly {
+			fmt.Fprintln(os.Stderr, "--parquet cannot be combined with --report-dirs-only")
+			os.Exit(2)
+		}
+		needsStat = true
+	}
+	opts := scanOptions{Workers: *workers, Logger: logger, OneFileSystem: *oneFS || *fromMounts, Deterministic: *deterministic, Labels: labels, Watch: *watch}
+	switch *scanOrder {
+	case "lexical":
+	case "mtime":
+		if *deterministic {
+			fmt.Fprintln(os.Stderr, "--deterministic always scans in lexical order; it cannot be combined with --scan-order=mtime")
+			os.Exit(2)
+		}
+		opts.NewestFirst = true
+	default:
+		fmt.Fprintf(os.Stderr, "--scan-order must be lexical or mtime, not %q\n", *scanOrder)
+		os.Exit(2)
+	}
+	if *reportDenied {
+		opts.Denied = &deniedPaths{}
+	}
+	opts.Check.NoATime = !*touchATime
+	if *sparse && !fadviseSupported {
+		fmt.Fprintln(os.Stderr, "--sparse is only supported on Linux")
+		os.Exit(2)
+	}
+	opts.Check.Fadvise = *sparse
+	if *mmapFlag && !mmapSupported {
+		fmt.Fprintln(os.Stderr, "--mmap is not supported on this platform")
+		os.Exit(2)
+	}
+	opts.Check.Mmap = *mmapFlag
+	if *retries < 0 {
+		fmt.Fprintln(os.Stderr, "--retry cannot be negative")
+		os.Exit(2)
+	}
+	opts.Check.Retries = *retries
+	opts.Check.RetryDelay = *retryDelay
+	if *minSchema < 0 || *maxSchema < 0 {
+		fmt.Fprintln(os.Stderr, "--min-schema-version and --max-schema-version cannot be negative")
+		os.Exit(2)
+	}
+	if *minSchema > 0 || *maxSchema > 0 {
+		if *maxSchema > 0 && *minSchema > *maxSchema {
+			fmt.Fprintln(os.Stderr, "--min-schema-version cannot be greater than --max-schema-version")
+			os.Exit(2)
+		}
+		opts.Filters = append(opts.Filters, schemaFormatFilter(*minSchema, *maxSchema))
+	}
+	if *minPageCount < 0 || *maxPageCount < 0 {
+		fmt.Fprintln(os.Stderr, "--min-page-count and --max-page-count cannot be negative")
+		os.Exit(2)
+	}
+	if *minPageCount > 0 || *maxPageCount > 0 {
+		if *maxPageCount > 0 && *minPageCount > *maxPageCount {
+			fmt.Fprintln(os.Stderr, "--min-page-count cannot be greater than --max-page-count")
+			os.Exit(2)
+		}
+		opts.Filters = append(opts.Filters, pageCountFilter(*minPageCount, *maxPageCount))
+		// The count falls back to the file size.
+		needsStat = true
+	}
+	if *minFreePages < 0 {
+		fmt.Fprintln(os.Stderr, "--min-free-pages cannot be negative")
+		os.Exit(2)
+	}
+	if *minFreePages > 0 {
+		opts.Filters = append(opts.Filters, minFreelistFilter(*minFreePages))
+	}
+	if *minTables < 0 {
+		fmt.Fprintln(os.Stderr, "--min-tables cannot be negative")
+		os.Exit(2)
+	}
+	if *minTables > 0 {
+		opts.Check.CountTables = true
+		opts.Filters = append(opts.Filters, minTablesFilter(*minTables))
+	}
+	if *journalModeFlag != "" {
+		if !slices.Contains(journalModes, *journalModeFlag) {
+			fmt.Fprintf(os.Stderr, "--journal-mode must be one of %s\n", strings.Join(journalModes, ", "))
+			os.Exit(2)
+		}
+		opts.Filters = append(opts.Filters, journalModeFilter(*journalModeFlag))
+	}
+	if *ownerFlag != "" || *groupFlag != "" {
+		if !ownerSupported {
+			logger.Warn("--owner and --group are ignored on this platform")
+		} else {
+			uid, gid := -1, -1
+			if *ownerFlag != "" {
+				if uid, err = lookupOwner(*ownerFlag); err != nil {
+					fmt.Fprintf(os.Stderr, "--owner: %v\n", err)
+					os.Exit(2)
+				}
+			}
+			if *groupFlag != "" {
+				if gid, err = lookupGroup(*groupFlag); err != nil {
+					fmt.Fprintf(os.Stderr, "--group: %v\n", err)
+					os.Exit(2)
+				}
+			}
+			opts.Check.Owner = true
+			needsStat = true
+			opts.Filters = append(opts.Filters, ownerFilter(uid, gid))
+		}
+	}
+	if *worldReadable {
+		opts.Filters = append(opts.Filters, worldReadableFilter)
+	}
+	if *blake3Flag && *hash {
+		fmt.Fprintln(os.Stderr, "--blake3 cannot be combined with --hash; pick one algorithm")
+		os.Exit(2)
+	}
+	opts.Check.Hash = *hash || *uniqueContent || *blake3Flag
+	opts.Check.Blake3 = *blake3Flag
+	if !slices.Contains(keepStrategies, *keep) {
+		fmt.Fprintf(os.Stderr, "--keep must be one of %s\n", strings.Join(keepStrategies, ", "))
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("keep") && !*uniqueContent {
+		fmt.Fprintln(os.Stderr, "--keep requires --unique-content")
+		os.Exit(2)
+	}
+	if *keep == "newest" || *keep == "oldest" {
+		needsStat = true
+	}
+	if *hashMaxBytes < 0 {
+		fmt.Fprintln(os.Stderr, "--hash-max-bytes cannot be negative")
+		os.Exit(2)
+	}
+	opts.Check.HashMaxBytes = *hashMaxBytes
+	if *skipLargerThanRAM < 0 || *skipLargerThanRAM > 1 {
+		fmt.Fprintln(os.Stderr, "--skip-larger-than-ram must be a fraction between 0 and 1")
+		os.Exit(2)
+	}
+	if *skipLargerThanRAM > 0 {
+		if !opts.Check.Hash {
+			fmt.Fprintln(os.Stderr, "--skip-larger-than-ram requires --hash, --blake3 or --unique-content")
+			os.Exit(2)
+		}
+		avail, err := availableMemory()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--skip-larger-than-ram: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Check.RAMFraction = *skipLargerThanRAM
+		opts.Check.MaxWholeFileSize = max(int64(float64(avail)**skipLargerThanRAM), 1)
+	}
+	opts.Check.OpenCheck = *openCheckFlag
+	opts.Check.WALFiles = *walFiles
+	opts.Check.ZeroSize = *includeZeroSize
+	if *lockCheck && !lockCheckSupported {
+		fmt.Fprintln(os.Stderr, "--lock-check is not supported on this platform")
+		os.Exit(2)
+	}
+	opts.Check.LockCheck = *lockCheck
+	if *readOnlyCheck && !readOnlyCheckSupported {
+		fmt.Fprintln(os.Stderr, "--read-only-check is only supported on Linux")
+		os.Exit(2)
+	}
+	opts.Check.SharedLocks = *readOnlyCheck
+	if *since != "" {
+		info, err := os.Stat(*since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--since: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Since = info.ModTime()
+	}
+	if *extReport {
+		if *cacheFile != "" {
+			// Unchanged directories are replayed from the cache
+			// without listing their files.
+			fmt.Fprintln(os.Stderr, "--extensions-report cannot be combined with --cache-file")
+			os.Exit(2)
+		}
+		opts.Extensions = newFileExtStats()
+	}
+	if *tarFlag {
+		if *openCheckFlag || *lockCheck || *readOnlyCheck || *minTables > 0 {
+			// Archive members are not files the driver or the lock
+			// tests could open, nor seek through for the schema.
+			fmt.Fprintln(os.Stderr, "--tar cannot be combined with --open-check, --lock-check, --read-only-check or --min-tables")
+			os.Exit(2)
+		}
+		if *cacheFile != "" {
+			// The cache stores matches per directory and cannot
+			// represent a member inside an archive.
+			fmt.Fprintln(os.Stderr, "--tar cannot be combined with --cache-file")
+			os.Exit(2)
+		}
+		opts.Tar = true
+	}
+	var baseline map[string]manifestEntry
+	if *compare != "" {
+		switch {
+		case *parquetOutput:
+			fmt.Fprintln(os.Stderr, "--compare cannot be combined with --parquet")
+			os.Exit(2)
+		case *watch || *inotifyWatch:
+			fmt.Fprintln(os.Stderr, "--compare needs the scan to finish and cannot be combined with --watch or --inotify-watch")
+			os.Exit(2)
+		case *dirsOnly:
+			fmt.Fprintln(os.Stderr, "--compare cannot be combined with --report-dirs-only")
+			os.Exit(2)
+		}
+		baseline, err = loadManifest(*compare)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--compare: %v\n", err)
+			os.Exit(2)
+		}
+		needsStat = true
+	}
+	// The sinks, --largest and --count-by-size-bucket are only set up
+	// once the output is open, after the cache below is keyed on
+	// SkipStat, so they are accounted for here: every sink but --exec
+	// and --statsd-addr reports sizes, and the other two rank by them.
+	if *dbOutput != "" || *streamTo != "" || *syslogFlag || *journaldFlag || len(*kafkaBrokers) > 0 || *natsURL != "" || *redisURL != "" || *etcdPrefix != "" {
+		needsStat = true
+	}
+	if *largest > 0 || *sizeBucketsFlag {
+		needsStat = true
+	}
+	opts.Check.SkipStat = *noStat && !needsStat
+	if *cacheFile != "" {
+		if *since != "" {
+			// Files skipped by --since would never be recorded, so the
+			// cache would silently forget them on later runs.
+			fmt.Fprintln(os.Stderr, "--cache-file cannot be combined with --since")
+			os.Exit(2)
+		}
+		if *lockCheck || *readOnlyCheck {
+			// Lock state changes from moment to moment; a cached
+			// answer would be meaningless.
+			fmt.Fprintln(os.Stderr, "--cache-file cannot be combined with --lock-check or --read-only-check")
+			os.Exit(2)
+		}
+		if *countFirst {
+			// Cached directories are replayed without visiting their
+			// files, so progress would never reach the count.
+			fmt.Fprintln(os.Stderr, "--cache-file cannot be combined with --count-first")
+			os.Exit(2)
+		}
+		cache, err := loadScanCache(*cacheFile, opts.Check.cacheKey())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--cache-file: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Cache = cache
+	}
+	if pflag.CommandLine.Changed("cloud-watch-namespace") && !*cloudWatchFlag {
+		fmt.Fprintln(os.Stderr, "--cloud-watch-namespace requires --cloud-watch")
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("statsd-prefix") && *statsdAddr == "" {
+		fmt.Fprintln(os.Stderr, "--statsd-prefix requires --statsd-addr")
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("progress-fd") && !*progressJSON {
+		fmt.Fprintln(os.Stderr, "--progress-fd requires --progress-json")
+		os.Exit(2)
+	}
+	if *progressFD < 0 {
+		fmt.Fprintln(os.Stderr, "--progress-fd must not be negative")
+		os.Exit(2)
+	}
+	if *progressFD == 1 && *output == "" {
+		fmt.Fprintln(os.Stderr, "--progress-fd 1 requires --output, or progress lines mix with the results on stdout")
+		os.Exit(2)
+	}
+	progressOut := os.Stderr
+	if *progressFD != 2 {
+		progressOut = os.NewFile(uintptr(*progressFD), "progress")
+		// A descriptor that is not open would otherwise swallow every
+		// progress line without a word.
+		if _, err := progressOut.Stat(); err != nil {
+			fmt.Fprintf(os.Stderr, "--progress-fd %d: %v\n", *progressFD, err)
+			os.Exit(2)
+		}
+	}
+	if *etcdPrefix == "" {
+		for _, name := range []string{"etcd-endpoints", "etcd-ttl", "etcd-cert", "etcd-key", "etcd-ca"} {
+			if pflag.CommandLine.Changed(name) {
+				fmt.Fprintf(os.Stderr, "--%s requires --etcd-prefix\n", name)
+				os.Exit(2)
+			}
+		}
+	}
+	if *webhookSecret != "" && *streamTo == "" {
+		fmt.Fprintln(os.Stderr, "--webhook-secret requires --stream-to")
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("size-buckets") && !*sizeBucketsFlag {
+		fmt.Fprintln(os.Stderr, "--size-buckets requires --count-by-size-bucket")
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("archive-depth") {
+		if !*tarFlag {
+			fmt.Fprintln(os.Stderr, "--archive-depth requires --tar")
+			os.Exit(2)
+		}
+		if *archiveDepth < 1 {
+			fmt.Fprintln(os.Stderr, "--archive-depth must be at least 1")
+			os.Exit(2)
+		}
+		opts.Check.ArchiveDepth = *archiveDepth
+	}
+	if pflag.CommandLine.Changed("concurrent-archives") {
+		if !*tarFlag {
+			fmt.Fprintln(os.Stderr, "--concurrent-archives requires --tar")
+			os.Exit(2)
+		}
+		if *concurrentArchives <= 0 {
+			fmt.Fprintln(os.Stderr, "--concurrent-archives must be at least 1")
+			os.Exit(2)
+		}
+		if *deterministic {
+			fmt.Fprintln(os.Stderr, "--concurrent-archives cannot be combined with --deterministic")
+			os.Exit(2)
+		}
+		opts.ConcurrentArchives = *concurrentArchives
+	}
+	if *validateUTF8 {
+		opts.UTF8Paths = utf8PathsWarn
+	}
+	if *skipInvalidUTF8 {
+		opts.UTF8Paths = utf8PathsSkip
+	}
+	if *maxBuffer < 0 {
+		fmt.Fprintln(os.Stderr, "--max-buffer cannot be negative")
+		os.Exit(2)
+	}
+	if *maxBuffer > 0 {
+		opts.Buffer = newBufferLimit(*maxBuffer)
+		// --sample, --largest and --reorder-window hold at most N
+		// matches, so they can be checked before scanning.
+		if *sample > *maxBuffer || *largest > *maxBuffer {
+			fmt.Fprintln(os.Stderr, "--sample and --largest cannot hold more matches than --max-buffer")
+			os.Exit(2)
+		}
+	}
+	if pflag.CommandLine.Changed("sample") && *sample <= 0 {
+		fmt.Fprintln(os.Stderr, "--sample must be at least 1")
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("seed") && *sample == 0 {
+		fmt.Fprintln(os.Stderr, "--seed requires --sample")
+		os.Exit(2)
+	}
+	if *sample > 0 && *watch {
+		// A watch never finishes, so the sample would never be printed.
+		fmt.Fprintln(os.Stderr, "--sample cannot be combined with --watch")
+		os.Exit(2)
+	}
+	if pflag.CommandLine.Changed("largest") && *largest <= 0 {
+		fmt.Fprintln(os.Stderr, "--largest must be at least 1")
+		os.Exit(2)
+	}
+	if *largest > 0 && *sample > 0 {
+		fmt.Fprintln(os.Stderr, "--largest cannot be combined with --sample")
+		os.Exit(2)
+	}
+	if *largest > 0 && *watch {
+		fmt.Fprintln(os.Stderr, "--largest cannot be combined with --watch")
+		os.Exit(2)
+	}
+	if *reorderWindow < 0 {
+		fmt.Fprintln(os.Stderr, "--reorder-window cannot be negative")
+		os.Exit(2)
+	}
+	if *reorderWindow > 0 {
+		if *largest > 0 {
+			// --largest prints biggest first, which reordering by path
+			// would undo.
+			fmt.Fprintln(os.Stderr, "--reorder-window cannot be combined with --largest")
+			os.Exit(2)
+		}
+		if *watch || *inotifyWatch {
+			// A match found while watching would sit in the window
+			// until enough others arrived behind it.
+			fmt.Fprintln(os.Stderr, "--reorder-window cannot be combined with --watch or --inotify-watch")
+			os.Exit(2)
+		}
+	}
+	if *inotifyWatch {
+		if !inotifySupported {
+			fmt.Fprintln(os.Stderr, "--inotify-watch is only supported on Linux")
+			os.Exit(2)
+		}
+		if *watch {
+			fmt.Fprintln(os.Stderr, "--inotify-watch cannot be combined with --watch")
+			os.Exit(2)
+		}
+		if *sample > 0 || *largest > 0 {
+			fmt.Fprintln(os.Stderr, "--inotify-watch cannot be combined with --sample or --largest")
+			os.Exit(2)
+		}
+		if *jsonOutput || *parquetOutput || *dirsOnly {
+			// Removals are reported as events, which only JSON lines
+			// can carry alongside matches.
+			fmt.Fprintln(os.Stderr, "--inotify-watch writes JSON lines; it cannot be combined with --json, --parquet or --report-dirs-only")
+			os.Exit(2)
+		}
+		outOpts.JSONL = true
+		opts.InotifyWatch = true
+	}
+	if *sample > 0 && !pflag.CommandLine.Changed("seed") {
+		*seed = rand.Uint64()
+	}
+	if *maxPerDir < 0 {
+		fmt.Fprintln(os.Stderr, "--max-matches-per-dir cannot be negative")
+		os.Exit(2)
+	}
+	if *maxPerDir > 0 {
+		// Placed before the content and path dedup filters: a copy
+		// dropped here must not hide the same database elsewhere.
+		opts.Filters = append(opts.Filters, maxPerDirFilter(*maxPerDir, opts.Buffer))
+	}
+	if *dedupBy != "" {
+		if !slices.Contains(dedupModes, *dedupBy) {
+			fmt.Fprintf(os.Stderr, "--dedup-by must be one of %s\n", strings.Join(dedupModes, ", "))
+			os.Exit(2)
+		}
+		// Like --unique-content, this must follow the other filters so
+		// a dropped match does not claim its path.
+		opts.Filters = append(opts.Filters, dedupPathFilter(*dedupBy, opts.Buffer))
+	}
+	if *uniqueContent && *keep == "first" {
+		// Keep this filter last: a match dropped by another filter must
+		// not claim its hash.
+		opts.Filters = append(opts.Filters, uniqueContentFilter(opts.Buffer))
+	}
+	scanStart := time.Now()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore default signal handling so a second Ctrl-C exits at once.
+		<-ctx.Done()
+		stop()
+	}()
+	var tracing *sdktrace.TracerProvider
+	if *otelEndpoint != "" {
+		tracing, err = newTracerProvider(ctx, *otelEndpoint)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--otel-endpoint: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if opts.Buffer != nil {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		opts.Buffer.cancel = cancel
+	}
+
+	if *noOutput {
+		if *output != "" || *parquetOutput {
+			fmt.Fprintln(os.Stderr, "--no-output cannot be combined with --output or --parquet")
+			os.Exit(2)
+		}
+		outOpts.Discard = true
+	}
+	if *benchmarkMode {
+		if *output != "" || *watch || *inotifyWatch {
+			fmt.Fprintln(os.Stderr, "--benchmark-mode cannot be combined with --output, --watch or --inotify-watch")
+			os.Exit(2)
+		}
+		opts.Check.Stats = &scanStats{}
+	}
+
+	var total int64
+	if *countFirst {
+		total, err = countCandidates(ctx, roots, opts.OneFileSystem)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("counting files failed", "error", err)
+		}
+		logger.Info("counted candidate files", "files", total)
+		opts.Check.Stats = &scanStats{}
+	}
+	var metricsClient cloudWatchAPI
+	if *cloudWatchFlag {
+		client, err := newCloudWatchClient(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--cloud-watch: %v\n", err)
+			os.Exit(1)
+		}
+		metricsClient = client
+	}
+	if (*cloudWatchFlag || *statsdAddr != "" || *progressJSON) && opts.Check.Stats == nil {
+		opts.Check.Stats = &scanStats{}
+	}
+
+	chain, err := newOutputChain(*output, encryptKey, *compressOutput)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var sinks []matchSink
+	// sinkErrs carries failures that sinks only learn of in the
+	// background, such as Kafka deliveries, to be counted with the scan
+	// errors. Unlike errs it stays open until the sinks are closed.
+	sinkErrs := make(chan error, *workers)
+	// From here on exits set exitCode and return instead of calling
+	// os.Exit, so this cleanup runs on every path: completion, errors and
+	// Ctrl-C or SIGTERM, which cancel ctx rather than kill the process.
+	// The output file is only committed once the printer has finished
+	// cleanly; otherwise it is removed.
+	exitCode, commitOutput := 0, false
+	defer func() {
+		// A finished scan has closed its sinks already; this catches
+		// the early returns. A failure to deliver fails the run like
+		// an unwritable output does.
+		if err := closeSinks(sinks, logger); err != nil {
+			exitCode = max(exitCode, 1)
+		}
+		if tracing != nil {
+			shutdownTracing(tracing, logger)
+		}
+		if err := chain.close(commitOutput); err != nil {
+			logger.Error("could not write output", "path", *output, "error", err)
+			exitCode = max(exitCode, 1)
+		}
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+	out, closeEncoding, err := encodeWriter(chain.W, *outEncoding)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--encoding: %v\n", err)
+		exitCode = 2
+		return
+	}
+
+	if *dbOutput != "" {
+		db, err := openDBSink(*dbOutput)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--db-output: %v\n", err)
+			exitCode = 1
+			return
+		}
+		sinks = append(sinks, db)
+	}
+	if *execCmd != "" {
+		argv := strings.Fields(*execCmd)
+		if len(argv) == 0 {
+			fmt.Fprintln(os.Stderr, "--exec requires a command")
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, newExecSink(ctx, argv, *execJobs, logger))
+	}
+	if *syslogFlag {
+		if !syslogSupported {
+			fmt.Fprintln(os.Stderr, "--syslog is not supported on Windows")
+			exitCode = 2
+			return
+		}
+		// An unreachable daemon should not cost the scan its results.
+		if w, err := dialSyslog(); err != nil {
+			logger.Warn("could not connect to syslog; continuing without it", "error", err)
+		} else {
+			sinks = append(sinks, &syslogSink{w: w})
+		}
+	}
+	if *journaldFlag {
+		switch {
+		case !journaldSupported:
+			logger.Warn("journald is not supported on this platform; writing matches to stderr instead")
+			sinks = append(sinks, stderrJournal{w: os.Stderr})
+		case !journaldAvailable():
+			logger.Warn("systemd journal socket not found; writing matches to stderr instead")
+			sinks = append(sinks, stderrJournal{w: os.Stderr})
+		default:
+			sinks = append(sinks, journaldSink{})
+		}
+	}
+	if *streamTo != "" {
+		streamer, err := newHTTPStreamer(ctx, *streamTo, *streamMethod, *streamHeaders, *streamTimeout, *webhookSecret, sinkErrs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--stream-to: %v\n", err)
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, streamer)
+	}
+	if *natsURL != "" || *natsSubject != "" {
+		if *natsURL == "" || *natsSubject == "" {
+			fmt.Fprintln(os.Stderr, "--nats-url and --nats-subject must be used together")
+			exitCode = 2
+			return
+		}
+		if *natsRetry < 0 {
+			fmt.Fprintln(os.Stderr, "--nats-retry cannot be negative")
+			exitCode = 2
+			return
+		}
+		// Like --syslog, an unreachable server should not cost the
+		// scan its results.
+		if conn, err := dialNATS(*natsURL, *natsCreds, *natsRetry, logger); err != nil {
+			logger.Warn("could not connect to NATS; continuing without it", "url", *natsURL, "error", err)
+		} else {
+			sinks = append(sinks, &natsSink{conn: conn, subject: *natsSubject, now: time.Now})
+		}
+	}
+	if *statsdAddr != "" {
+		statsd, err := newStatsdSink(*statsdAddr, *statsdPrefix, &opts.Check.Stats.FilesChecked, scanStart)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--statsd-addr: %v\n", err)
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, statsd)
+	}
+	if *redisURL != "" {
+		list, err := newRedisSink(ctx, *redisURL, *redisKey, *redisTTL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, list)
+	}
+	if *etcdPrefix != "" {
+		kv, err := newEtcdSink(ctx, *etcdPrefix, *etcdEndpoints, *etcdTTL, *etcdCert, *etcdKey, *etcdCA)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, kv)
+	}
+	if len(*kafkaBrokers) > 0 || *kafkaTopic != "" {
+		producer, err := newKafkaSink(ctx, *kafkaBrokers, *kafkaTopic, *kafkaPartitionKey, sinkErrs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, producer)
+	}
+	counter := &matchCounter{}
+	sinks = append(sinks, counter)
+	var exts *extStats
+	if *extStatsFlag {
+		// teeMatches adds each match to its sinks before forwarding
+		// it, so the counts are final when the printer sees the end.
+		exts = newExtStats()
+		sinks = append(sinks, exts)
+		if *jsonOutput && !*quiet && !*parquetOutput {
+			outOpts.ExtStats = exts
+		}
+	}
+	var buckets *sizeBuckets
+	if *sizeBucketsFlag {
+		buckets, err = newSizeBuckets(*sizeBucketsSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--size-buckets: %v\n", err)
+			exitCode = 2
+			return
+		}
+		sinks = append(sinks, buckets)
+		if *jsonOutput && !*quiet && !*parquetOutput {
+			outOpts.SizeBuckets = buckets
+		}
+	}
+
+	matches := make(chan matchResult, *workers*2)
+	errs := make(chan error, *workers)
+	var scanned <-chan matchResult = matches
+	if *uniqueContent && *keep != "first" {
+		scanned = pickByContent(matches, *keep, opts.Buffer)
+	}
+	if *sample > 0 {
+		scanned = sampleMatches(scanned, *sample, *seed)
+	}
+	if *largest > 0 {
+		scanned = largestMatches(scanned, *largest)
+	}
+	printed := teeMatches(scanned, sinks, logger)
+	if *reorderWindow > 0 {
+		// Only the printed output waits in the window; the sinks
+		// still see each match as soon as it is found.
+		printed = reorderMatches(printed, *reorderWindow)
+	}
+	if *dirsOnly {
+		printed = reportDirsOnly(printed, roots, *depth, opts.Buffer)
+	}
+
+	var printErr error
+	var printWg sync.WaitGroup
+	printWg.Add(1)
+	go func() {
+		defer printWg.Done()
+		if *parquetOutput {
+			printErr = writeParquet(out, printed, outOpts)
+			return
+		}
+		if *quiet || *benchmarkMode {
+			// Drain without formatting, so --benchmark-mode measures
+			// the scan and not the printer.
+			for range printed {
+			}
+			return
+		}
+		if baseline != nil {
+			printErr = writeComparison(out, printed, baseline, outOpts)
+			if err := closeEncoding(); printErr == nil {
+				printErr = err
+			}
+			return
+		}
+		streamMatches(ctx, out, printed, outOpts)
+		printErr = closeEncoding()
+	}()
+
+	var warnWg sync.WaitGroup
+	warnWg.Add(1)
+	var scanErrors int64
+	go func() {
+		defer warnWg.Done()
+		scanErrs, deliveryErrs := (<-chan error)(errs), (<-chan error)(sinkErrs)
+		for scanErrs != nil || deliveryErrs != nil {
+			select {
+			case err, ok := <-scanErrs:
+				if !ok {
+					scanErrs = nil
+					continue
+				}
+				scanErrors++
+				logger.Warn("scan error", "error", err)
+			case err, ok := <-deliveryErrs:
+				if !ok {
+					deliveryErrs = nil
+					continue
+				}
+				scanErrors++
+				logger.Warn("sink error", "error", err)
+			}
+		}
+	}()
+
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	if *countFirst {
+		go reportProgress(progressCtx, os.Stderr, total, opts.Check.Stats, time.Second)
+	}
+	var stopProgressJSON chan struct{}
+	progressJSONDone := make(chan struct{})
+	if *progressJSON {
+		stopProgressJSON = make(chan struct{})
+		go reportProgressJSON(stopProgressJSON, progressJSONDone, progressOut, opts.Check.Stats, &counter.n, time.Second)
+	}
+	var scanSpan trace.Span
+	if tracing != nil {
+		opts.Check.Trace, scanSpan = startScanSpan(ctx, tracing, roots, *workers)
+	}
+	var walkErr error
+	if *awsS3 != "" {
+		client, err := newS3Client(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--aws-s3: %v\n", err)
+			exitCode = 1
+			return
+		}
+		walkErr = scanS3Prefix(ctx, client, *awsS3, opts, matches, errs)
+	} else if *gcsURI != "" {
+		client, err := newGCSClient(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--gcs: %v\n", err)
+			exitCode = 1
+			return
+		}
+		walkErr = scanGCSPrefix(ctx, client, *gcsURI, opts, matches, errs)
+		client.Close()
+	} else if *sftpFlag != "" {
+		target, err := parseSFTPTarget(*sftpFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--sftp: %v\n", err)
+			exitCode = 2
+			return
+		}
+		client, closeSFTP, err := dialSFTP(target, *sftpKey, *sftpTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--sftp: %v\n", err)
+			exitCode = 1
+			return
+		}
+		walkErr = scanSFTP(ctx, client, target, opts, matches, errs)
+		closeSFTP()
+	} else if *fdFrom >= 0 {
+		root, display, closeRoot, err := openDirFD(*fdFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--fd-from: %v\n", err)
+			exitCode = 2
+			return
+		}
+		walkErr = scanRoot(ctx, root, display, opts, matches, errs)
+		closeRoot()
+	} else {
+		walkErr = scanPaths(ctx, roots, opts, matches, errs)
+	}
+	stopProgress()
+	if *countFirst {
+		fmt.Fprintln(os.Stderr, formatProgress(opts.Check.Stats.FilesExamined.Load(), total, time.Since(scanStart)))
+	}
+
+	printWg.Wait()
+	// Every match has reached the sinks, so they are closed here rather
+	// than in the deferred cleanup: their last deliveries then fail in
+	// time to be counted below and reported to --cloud-watch.
+	if err := closeSinks(sinks, logger); err != nil {
+		exitCode = max(exitCode, 1)
+	}
+	sinks = nil
+	close(sinkErrs)
+	if stopProgressJSON != nil {
+		// After the printer, so the final event counts every match.
+		close(stopProgressJSON)
+		<-progressJSONDone
+	}
+	if scanSpan != nil {
+		endScanSpan(scanSpan, counter.n.Load())
+	}
+	warnWg.Wait()
+	if metricsClient != nil {
+		// Sent even for an interrupted scan, which still did the work
+		// it reports.
+		metrics := scanMetrics{
+			FilesChecked: opts.Check.Stats.FilesChecked.Load(),
+			Matches:      counter.n.Load(),
+			Errors:       scanErrors,
+			Duration:     time.Since(scanStart),
+		}
+		scanned := roots
+		if remote := firstSet(pflag.CommandLine, remoteFlags); remote != "" {
+			scanned = []string{pflag.Lookup(remote).Value.String()}
+		}
+		putCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		if err := putScanMetrics(putCtx, metricsClient, *cloudWatchNamespace, scanned, metrics, time.Now()); err != nil {
+			logger.Warn("could not put --cloud-watch metrics", "error", err)
+		}
+		cancel()
+	}
+	if opts.Denied != nil {
+		opts.Denied.report(os.Stderr)
+	}
+	if exts != nil && outOpts.ExtStats == nil {
+		exts.report(os.Stderr)
+	}
+	if buckets != nil && outOpts.SizeBuckets == nil {
+		buckets.report(os.Stderr)
+	}
+	if opts.Extensions != nil {
+		opts.Extensions.report(os.Stderr)
+	}
+	if *benchmarkMode {
+		report := newBenchmarkReport(opts.Check.Stats.FilesChecked.Load(), counter.n.Load(), time.Since(scanStart))
+		report.write(os.Stderr)
+	} else if *noOutput {
+		// With nothing on stdout the count is the only result, so it is
+		// printed even to a terminal and despite --no-summary.
+		fmt.Fprintln(os.Stderr, summaryLine(counter.n.Load()))
+	} else if !*noSummary {
+		printSummary(os.Stderr, int(os.Stdout.Fd()), counter.n.Load())
+	}
+
+	bufErr := opts.Buffer.Err()
+	commitOutput = printErr == nil && bufErr == nil
+	if printErr == nil {
+		// Close now rather than in the deferred cleanup so the output
+		// is in place before the cache and --since stamp are written.
+		printErr = chain.close(commitOutput)
+	}
+	if printErr != nil {
+		logger.Error("could not write output", "path", *output, "error", printErr)
+		exitCode = 1
+		return
+	}
+
+	if bufErr != nil {
+		logger.Error("scan stopped", "error", bufErr)
+		exitCode = 1
+		return
+	}
+	if ctx.Err() != nil {
+		logger.Warn("scan interrupted; results are partial")
+		exitCode = 130
+		return
+	}
+	if walkErr != nil {
+		logger.Error("scan completed with walk error", "error", walkErr)
+		return
+	}
+	if opts.Cache != nil {
+		if err := opts.Cache.save(*cacheFile); err != nil {
+			logger.Error("could not write --cache-file", "path", *cacheFile, "error", err)
+		}
 	}
-	roots = resolveRoots(roots)
-
-	if *workers <= 0 {
-		fmt.Fprintln(os.Stderr, "workers must be > 0")
-		os.Exit(2)
+	if *since != "" {
+		// Use the scan start time so files modified mid-scan are picked up
+		// again by the next incremental run.
+		if err := os.Chtimes(*since, scanStart, scanStart); err != nil {
+			logger.Error("could not update --since reference file", "path", *since, "error", err)
+		}
 	}
-	matches := make(chan matchResult, *workers*2)
-	errs := make(chan error, *workers)
+}
 
-	var printWg sync.WaitGroup
-	printWg.Add(1)
-	go func() {
-		defer printWg.Done()
-		showSize := *size
-		streamMatches(matches, *jsonOutput, *jsonl, showSize)
-	}()
+// findSQLiteFiles scans roots and yields every match as it is found,
+// without holding the results in memory. If the scan fails, or ctx is
+// cancelled, the last pair carries the error (wrapping ctx.Err() for a
+// cancellation) and a zero matchResult. Stopping the loop early cancels
+// the scan and waits for it to finish.
+func findSQLiteFiles(ctx context.Context, roots []string, workers int) iter.Seq2[matchResult, error] {
+	return func(yield func(matchResult, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		matches := make(chan matchResult, workers*2)
+		errs := make(chan error, workers)
 
-	var warnWg sync.WaitGroup
-	warnWg.Add(1)
-	go func() {
-		defer warnWg.Done()
-		for err := range errs {
-			fmt.Fprintln(os.Stderr, "warning:", err)
-		}
-	}()
+		go func() {
+			for range errs {
+			}
+		}()
+
+		var walkErr error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			opts := scanOptions{Workers: workers, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+			walkErr = scanPaths(ctx, roots, opts, matches, errs)
+		}()
 
-	walkErr := scanPaths(roots, *workers, matches, errs)
+		for m := range matches {
+			if !yield(m, nil) {
+				cancel()
+				for range matches {
+				}
+				<-done
+				return
+			}
+		}
+		<-done
+		if walkErr != nil {
+			yield(matchResult{}, walkErr)
+		}
+	}
+}
 
-	printWg.Wait()
-	warnWg.Wait()
+// outputOptions selects the output format and which optional fields are
+// included for each match.
+type outputOptions struct {
+	JSON             bool
+	JSONL            bool
+	ShowSize         bool
+	ShowSchemaFormat bool
+	ShowFreelist     bool
+	ShowPageCount    bool
+	ShowJournalMode  bool
+	ShowAutoVacuum   bool
+	ShowVersion      bool
+	ShowMode         bool
+	ShowTables       bool
+	ShowHash         bool
+	ShowBlake3       bool
+	ShowLabel        bool
+	ShowOpenable     bool
+	ShowLocked       bool
+	ShowSharedLocks  bool
+	ShowKind         bool
+	// Host, when set, names the machine in every entry (--host,
+	// --resolve-hostname).
+	Host string
+	// TruncatePath, when > 0, shortens displayed paths to this many
+	// characters (see truncatePath).
+	TruncatePath int
+	// RelativeTo, when set, reports paths relative to this directory
+	// (--cwd-relative) instead of absolute.
+	RelativeTo string
+	// TrimPrefix, when set, is removed from the start of absolute paths
+	// that lie under it (--trim-prefix).
+	TrimPrefix string
+	// NullSafe makes --jsonl print an emptyJSONL line when there are no
+	// matches, so every scan produces at least one object.
+	NullSafe bool
+	// Discard drains matches without formatting or writing anything
+	// (--no-output).
+	Discard bool
+	// JSONKey renames the "entries" array of the --json document.
+	JSONKey string
+	// JSONIndent is the number of spaces per level of the --json
+	// document (2 when zero); JSONCompact puts it on one line instead.
+	JSONIndent  int
+	JSONCompact bool
+	// ExtStats, when set, is written as an "ext_stats" field of the
+	// --json document. It must be complete before matches is closed.
+	ExtStats *extStats
+	// SizeBuckets, when set, is written as a "size_buckets" field of
+	// the --json document. Like ExtStats it must be complete first.
+	SizeBuckets *sizeBuckets
+	// Config, when set, is written as a "config" field of the --json
+	// document (--echo-config).
+	Config map[string]any
+}
 
-	if walkErr != nil {
-		fmt.Fprintf(os.Stderr, "scan completed with walk error: %v\n", walkErr)
+// jsonIndent returns the indentation unit of the --json document.
+func (opts outputOptions) jsonIndent() string {
+	switch {
+	case opts.JSONCompact:
+		return ""
+	case opts.JSONIndent > 0:
+		return strings.Repeat(" ", opts.JSONIndent)
 	}
+	return "  "
 }
 
-func findSQLiteFiles(roots []string, workers int) ([]matchResult, error) {
-	matches := make(chan matchResult, workers*2)
-	errs := make(chan error, workers)
+// defaultJSONKey names the array of matches in the --json document.
+const defaultJSONKey = "entries"
 
-	var out []matchResult
-	var collectWg sync.WaitGroup
-	collectWg.Add(1)
-	go func() {
-		defer collectWg.Done()
-		for m := range matches {
-			out = append(out, m)
-		}
-	}()
+func (opts outputOptions) jsonKey() string {
+	if opts.JSONKey == "" {
+		return defaultJSONKey
+	}
+	return opts.JSONKey
+}
 
-	var drainWg sync.WaitGroup
-	drainWg.Add(1)
-	go func() {
-		defer drainWg.Done()
-		for range errs {
+// validateJSONKey accepts identifier-like keys that downstream tools can
+// address without quoting, and rejects "truncated", which the document
+// already uses.
+func validateJSONKey(key string) error {
+	if key == "" || len(key) > 64 {
+		return errors.New("must be 1 to 64 characters")
+	}
+	for i, r := range key {
+		ok := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(i > 0 && (r == '-' || (r >= '0' && r <= '9')))
+		if !ok {
+			return fmt.Errorf("%q must start with a letter or underscore and contain only letters, digits, _ and -", key)
 		}
-	}()
+	}
+	if key == "truncated" {
+		return errors.New(`"truncated" is reserved`)
+	}
+	return nil
+}
 
-	walkErr := scanPaths(roots, workers, matches, errs)
-	collectWg.Wait()
-	drainWg.Wait()
+// displayPath returns the path to print for a match: absolute, with
+// opts.TrimPrefix removed when it applies, or relative to opts.RelativeTo
+// when that is set and a relative path exists (on Windows there is none
+// across volumes).
+func (opts outputOptions) displayPath(path string) string {
+	abs := formatPath(path)
+	if opts.TrimPrefix != "" {
+		return trimPathPrefix(abs, opts.TrimPrefix)
+	}
+	if opts.RelativeTo == "" {
+		return abs
+	}
+	if rel, err := filepath.Rel(opts.RelativeTo, abs); err == nil {
+		return rel
+	}
+	return abs
+}
 
-	return out, walkErr
+// trimPathPrefix removes prefix and the separator after it from path when
+// path lies under prefix as a whole path component, so /data/exports does
+// not trim /data/exports2/a.db. Other paths, and prefix itself, are
+// returned unchanged.
+func trimPathPrefix(path, prefix string) string {
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok || rest == "" {
+		return path
+	}
+	if strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, string(filepath.Separator)) {
+		return rest
+	}
+	if rest[0] != '/' && rest[0] != filepath.Separator {
+		return path
+	}
+	return rest[1:]
 }
 
-func streamMatches(matches <-chan matchResult, jsonOutput bool, jsonl bool, showSize bool) {
-	if jsonl {
+// streamMatches writes matches to w as they arrive. In --json mode a
+// "truncated": true field is added when ctx was cancelled before the scan
+// finished, so consumers can tell the entries are incomplete.
+func streamMatches(ctx context.Context, w io.Writer, matches <-chan matchResult, opts outputOptions) {
+	if opts.Discard {
+		for range matches {
+		}
+		return
+	}
+	if opts.JSONL {
+		n := 0
 		for m := range matches {
-			fmt.Println(formatJSONLine(m, showSize))
+			fmt.Fprintln(w, formatJSONLine(m, opts))
+			n++
+		}
+		if n == 0 && opts.NullSafe {
+			fmt.Fprintln(w, marshalJSON(emptyJSONL{Empty: true, Truncated: ctx.Err() != nil}, "", ""))
 		}
 		return
 	}
 
-	if jsonOutput {
-		fmt.Println("{")
-		fmt.Println("  \"entries\": [")
+	if opts.JSON {
+		// In compact mode nl and colon drop their whitespace and ind is
+		// empty, so the same writes produce a single line.
+		ind, nl, colon := opts.jsonIndent(), "\n", ": "
+		if opts.JSONCompact {
+			nl, colon = "", ":"
+		}
+		fmt.Fprintf(w, "{%s%s%s%s[%s", nl, ind, marshalJSON(opts.jsonKey(), "", ""), colon, nl)
 		first, ok := <-matches
 		if ok {
 			curr := first
 			for next := range matches {
-				entry := formatJSONEntry(curr, showSize)
-				fmt.Printf("%s,\n", entry)
+				fmt.Fprintf(w, "%s,%s", formatJSONEntry(curr, opts), nl)
 				curr = next
 			}
-			fmt.Println(formatJSONEntry(curr, showSize))
+			fmt.Fprintf(w, "%s%s", formatJSONEntry(curr, opts), nl)
+		}
+		var trailer []string
+		if opts.Config != nil {
+			trailer = append(trailer, `"config"`+colon+marshalJSON(opts.Config, ind, ind))
+		}
+		if opts.ExtStats != nil {
+			trailer = append(trailer, `"ext_stats"`+colon+marshalJSON(opts.ExtStats.counts, ind, ind))
 		}
-		fmt.Println("  ]")
-		fmt.Println("}")
+		if opts.SizeBuckets != nil {
+			trailer = append(trailer, `"size_buckets"`+colon+marshalJSON(opts.SizeBuckets.byLabel(), ind, ind))
+		}
+		if ctx.Err() != nil {
+			trailer = append(trailer, `"truncated"`+colon+`true`)
+		}
+		fmt.Fprint(w, ind+"]")
+		for _, field := range trailer {
+			fmt.Fprintf(w, ",%s%s%s", nl, ind, field)
+		}
+		fmt.Fprintf(w, "%s}\n", nl)
 		return
 	}
 
 	for m := range matches {
-		fmt.Println(formatPlainMatch(m, showSize))
+		fmt.Fprintln(w, formatPlainMatch(m, opts))
+	}
+}
+
+// emptyJSONL is the line --null-safe-json prints for a scan without
+// matches. Truncated is set, as in the --json document, when the scan was
+// interrupted.
+type emptyJSONL struct {
+	Empty     bool `json:"_empty"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// newLogger returns a text logger writing to w that drops records below
+// the named level. The timestamp is omitted to keep stderr readable.
+func newLogger(w io.Writer, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid --log-level %q: must be debug, info, warn, or error", level)
 	}
+	h := slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: lvl,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(h), nil
 }
 
 func formatPath(path string) string {
+	if isRemotePath(path) {
+		return path
+	}
 	if ap, err := filepath.Abs(path); err == nil {
 		return ap
 	}
 	return path
 }
 
-func formatJSONLine(m matchResult, showSize bool) string {
-	path := formatPath(m.Path)
-	if showSize {
-		return fmt.Sprintf("{\"path\": %s, \"size\": %d}", marshalString(path), m.Size)
-	}
-	return fmt.Sprintf("{\"path\": %s}", marshalString(path))
+// entryJSON is the JSON shape of a single match. Optional fields are
+// pointers or omitempty strings so they only appear when their flag is set.
+type entryJSON struct {
+	Path         string  `json:"path"`
+	Host         string  `json:"host,omitempty"`
+	Label        string  `json:"label,omitempty"`
+	Kind         string  `json:"kind,omitempty"`
+	Size         *int64  `json:"size,omitempty"`
+	SchemaFormat *uint8  `json:"schema_format,omitempty"`
+	Freelist     *int    `json:"freelist_pages,omitempty"`
+	PageCount    *int    `json:"page_count,omitempty"`
+	JournalMode  string  `json:"journal_mode,omitempty"`
+	AutoVacuum   *bool   `json:"autovacuum,omitempty"`
+	Incremental  *bool   `json:"incremental_vacuum,omitempty"`
+	RootPage     *uint32 `json:"largest_root_page,omitempty"`
+	Version      string  `json:"sqlite_version,omitempty"`
+	VersionStale bool    `json:"sqlite_version_stale,omitempty"`
+	UserVersion  *int32  `json:"user_version,omitempty"`
+	AppID        *uint32 `json:"application_id,omitempty"`
+	Tables       *int    `json:"tables,omitempty"`
+	SHA256       string  `json:"sha256,omitempty"`
+	Blake3       string  `json:"blake3,omitempty"`
+	HashPartial  bool    `json:"hash_partial,omitempty"`
+	HashedBytes  int64   `json:"hashed_bytes,omitempty"`
+	Openable     *bool   `json:"openable,omitempty"`
+	Locked       *bool   `json:"locked,omitempty"`
+	SharedLocks  *int    `json:"shared_locks,omitempty"`
+	Mode         string  `json:"mode,omitempty"`
+	DisplayPath  string  `json:"display_path,omitempty"`
 }
 
-func formatJSONEntry(m matchResult, showSize bool) string {
-	path := formatPath(m.Path)
-	if showSize {
-		return fmt.Sprintf("    {\n      \"path\": %s,\n      \"size\": %d\n    }", marshalString(path), m.Size)
+func newEntryJSON(m matchResult, opts outputOptions) entryJSON {
+	e := entryJSON{Path: opts.displayPath(m.Path), Host: opts.Host}
+	if opts.ShowSize {
+		e.Size = &m.Size
+	}
+	if opts.ShowSchemaFormat {
+		e.SchemaFormat = &m.SchemaFormat
+	}
+	if opts.ShowFreelist {
+		e.Freelist = &m.FreelistPages
+	}
+	if opts.ShowPageCount {
+		e.PageCount = &m.PageCount
+	}
+	if opts.ShowJournalMode {
+		e.JournalMode = m.JournalMode
+	}
+	if opts.ShowAutoVacuum && m.Kind == kindDatabase {
+		e.AutoVacuum = &m.AutoVacuum
+		e.Incremental = &m.IncrementalVacuum
+		e.RootPage = &m.LargestRootPage
+	}
+	if opts.ShowVersion && m.Kind == kindDatabase {
+		e.Version = m.SQLiteVersion
+		e.VersionStale = m.VersionStale
+		e.UserVersion = &m.UserVersion
+		e.AppID = &m.ApplicationID
+	}
+	if opts.ShowTables {
+		e.Tables = &m.Tables
+	}
+	if opts.ShowHash || opts.ShowBlake3 {
+		e.SHA256 = m.SHA256
+		e.Blake3 = m.Blake3
+		e.HashPartial = m.HashPartial
+		e.HashedBytes = m.HashedBytes
+	}
+	if opts.ShowLabel {
+		e.Label = m.Label
+	}
+	if opts.ShowKind {
+		e.Kind = m.Kind
+	}
+	if opts.ShowOpenable {
+		e.Openable = &m.Openable
+	}
+	if opts.ShowLocked {
+		e.Locked = &m.Locked
 	}
-	return fmt.Sprintf("    {\n      \"path\": %s\n    }", marshalString(path))
+	if opts.ShowSharedLocks {
+		e.SharedLocks = &m.SharedLockCount
+	}
+	if opts.ShowMode {
+		e.Mode = formatMode(m.Mode)
+	}
+	if opts.TruncatePath > 0 {
+		e.DisplayPath = truncatePath(e.Path, opts.TruncatePath)
+	}
+	return e
 }
 
-func formatPlainMatch(m matchResult, showSize bool) string {
-	path := formatPath(m.Path)
-	if !showSize {
+// truncatePath shortens path to max characters by replacing its beginning
+// with "...", keeping the file name end visible. Paths that already fit are
+// returned unchanged.
+func truncatePath(path string, max int) string {
+	r := []rune(path)
+	if len(r) <= max {
 		return path
 	}
-	return fmt.Sprintf("%s (%d bytes)", path, m.Size)
+	if max <= 3 {
+		return string(r[len(r)-max:])
+	}
+	return "..." + string(r[len(r)-(max-3):])
+}
+
+// marshalJSON encodes v without HTML escaping, so paths containing &, <
+// or > stay readable, and without the encoder's trailing newline.
+func marshalJSON(v any, prefix, indent string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, indent)
+	enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func formatJSONLine(m matchResult, opts outputOptions) string {
+	if m.Event != "" {
+		return marshalJSON(removedEvent{Event: m.Event, Host: opts.Host, Path: opts.displayPath(m.Path)}, "", "")
+	}
+	return marshalJSON(newEntryJSON(m, opts), "", "")
+}
+
+// formatJSONEntry renders an entry indented to sit inside the "entries"
+// array of the --json document.
+func formatJSONEntry(m matchResult, opts outputOptions) string {
+	ind := opts.jsonIndent()
+	return ind + ind + marshalJSON(newEntryJSON(m, opts), ind+ind, ind)
 }
 
-func marshalString(v string) string {
-	b, _ := json.Marshal(v)
-	return string(b)
+func formatPlainMatch(m matchResult, opts outputOptions) string {
+	out := opts.displayPath(m.Path)
+	if opts.TruncatePath > 0 {
+		out = truncatePath(out, opts.TruncatePath)
+	}
+	if opts.Host != "" {
+		out = opts.Host + ":" + out
+	}
+	if opts.ShowKind && m.Kind != kindDatabase {
+		out += " [" + m.Kind + "]"
+	}
+	if opts.ShowSize {
+		out = fmt.Sprintf("%s (%d bytes)", out, m.Size)
+	}
+	if opts.ShowSchemaFormat {
+		out = fmt.Sprintf("%s [schema format %d]", out, m.SchemaFormat)
+	}
+	if opts.ShowFreelist {
+		out = fmt.Sprintf("%s [%d free pages]", out, m.FreelistPages)
+	}
+	if opts.ShowPageCount {
+		out = fmt.Sprintf("%s [%d pages]", out, m.PageCount)
+	}
+	if opts.ShowJournalMode && m.JournalMode != "" {
+		out = fmt.Sprintf("%s [journal %s]", out, m.JournalMode)
+	}
+	if opts.ShowAutoVacuum && m.AutoVacuum {
+		mode := "full"
+		if m.IncrementalVacuum {
+			mode = "incremental"
+		}
+		out = fmt.Sprintf("%s [auto-vacuum %s]", out, mode)
+	}
+	if opts.ShowVersion && m.SQLiteVersion != "" {
+		if m.VersionStale {
+			out = fmt.Sprintf("%s [sqlite %s, stale]", out, m.SQLiteVersion)
+		} else {
+			out = fmt.Sprintf("%s [sqlite %s]", out, m.SQLiteVersion)
+		}
+	}
+	if opts.ShowVersion && (m.UserVersion != 0 || m.ApplicationID != 0) {
+		out = fmt.Sprintf("%s [user_version %d, application_id %#x]", out, m.UserVersion, m.ApplicationID)
+	}
+	if opts.ShowTables {
+		out = fmt.Sprintf("%s [%d tables]", out, m.Tables)
+	}
+	if opts.ShowHash || opts.ShowBlake3 {
+		if opts.ShowBlake3 {
+			out = fmt.Sprintf("%s blake3:%s", out, m.Blake3)
+		} else {
+			out = fmt.Sprintf("%s sha256:%s", out, m.SHA256)
+		}
+		if m.HashPartial {
+			out = fmt.Sprintf("%s (first %d bytes)", out, m.HashedBytes)
+		}
+	}
+	if opts.ShowOpenable && !m.Openable {
+		out = fmt.Sprintf("%s [%s]", out, m.OpenFailure)
+	}
+	if opts.ShowLocked && m.Locked {
+		out += " [LOCKED]"
+	}
+	if opts.ShowSharedLocks && m.SharedLockCount > 0 {
+		out = fmt.Sprintf("%s [%d shared locks]", out, m.SharedLockCount)
+	}
+	if opts.ShowMode {
+		out = fmt.Sprintf("%s [mode %s]", out, formatMode(m.Mode))
+	}
+	if opts.ShowLabel {
+		out = fmt.Sprintf("[%s] %s", m.Label, out)
+	}
+	return out
 }
 
 func resolveRoots(roots []string) []string {
@@ -227,25 +2141,133 @@ func resolveRoots(roots []string) []string {
 	return resolved
 }
 
-func scanPaths(roots []string, workers int, matches chan<- matchResult, errs chan<- error) error {
-	paths := make(chan string, workers*4)
+// candidateKind tells the workers how to check a queued file.
+type candidateKind int
+
+const (
+	// candidateFile is checked with checkSQLiteMagic.
+	candidateFile candidateKind = iota
+	// candidateArchive is a tar archive whose members are checked with
+	// scanTar (--tar).
+	candidateArchive
+)
+
+// candidate is a regular file queued for checking.
+type candidate struct {
+	kind  candidateKind
+	path  string
+	label string
+}
+
+// scanPaths walks roots and sends every SQLite file found to matches. When
+// ctx is cancelled the walkers stop, queued paths are drained without being
+// checked, and the context error is included in the returned error.
+func scanPaths(ctx context.Context, roots []string, opts scanOptions, matches chan<- matchResult, errs chan<- error) error {
+	logger := opts.Logger
+	paths := make(chan candidate, opts.Workers*4)
+
+	// known records reported paths for --inotify-watch, which reports
+	// their removal and does not report them again.
+	var known *knownPaths
+	if opts.InotifyWatch {
+		known = newKnownPaths(opts.Buffer)
+	}
+
+	checkOpts := opts.Check
+	if checkOpts.Retries > 0 {
+		checkOpts.OnRetry = func(err error) {
+			errs <- fmt.Errorf("retrying: %w", err)
+		}
+	}
+
+	// archiveSem bounds the archive members checked at once across all
+	// workers; it is separate from the worker pool.
+	var archiveSem chan struct{}
+	if opts.ConcurrentArchives > 0 {
+		archiveSem = make(chan struct{}, opts.ConcurrentArchives)
+	}
+
+	// classify tags a file found under a root for the workers.
+	classify := func(p, label string) candidate {
+		c := candidate{kind: candidateFile, path: p, label: label}
+		if opts.Tar && isTarArchive(p) {
+			c.kind = candidateArchive
+		}
+		return c
+	}
+
+	// check inspects one candidate and reports it if it matches. It
+	// returns whether the file is a SQLite database, even if a filter
+	// dropped it; archives never are.
+	check := func(c candidate) bool {
+		p, label := c.path, c.label
+		if c.kind == candidateArchive {
+			err := scanTar(p, checkOpts, archiveSem, func(res matchResult) {
+				res.Label = label
+				if keepMatch(res, opts.Filters) {
+					if known != nil {
+						known.add(res.Path)
+					}
+					matches <- res
+				}
+			})
+			if errors.Is(err, fs.ErrPermission) {
+				logger.Debug("skipping unreadable archive", "path", p)
+				opts.Denied.add(p)
+			} else if err != nil {
+				errs <- fmt.Errorf("%s: %w", p, err)
+			}
+			return false
+		}
+		res, ok, err := checkSQLiteMagic(p, checkOpts)
+		if err != nil {
+			if opts.Cache != nil {
+				opts.Cache.markIncomplete(p)
+			}
+			if errors.Is(err, fs.ErrPermission) {
+				logger.Debug("skipping unreadable file", "path", p)
+				opts.Denied.add(p)
+			} else {
+				errs <- fmt.Errorf("%s: %w", p, err)
+			}
+			return false
+		}
+		if !ok {
+			logger.Debug("skipping non-SQLite file", "path", p)
+			return false
+		}
+		if opts.Cache != nil {
+			// Cache before filtering so later runs with other filters
+			// still see this match.
+			opts.Cache.recordMatch(res)
+		}
+		res.Label = label
+		if !keepMatch(res, opts.Filters) {
+			logger.Debug("skipping filtered match", "path", p)
+			return true
+		}
+		if known != nil {
+			known.add(res.Path)
+		}
+		matches <- res
+		return true
+	}
 
+	workers := opts.Workers
+	if opts.Deterministic {
+		// Files are checked inline by the single walker instead.
+		workers = 0
+	}
 	var workerWg sync.WaitGroup
 	for i := 0; i < workers; i++ {
 		workerWg.Add(1)
 		go func() {
 			defer workerWg.Done()
-			for p := range paths {
-				res, ok, err := checkSQLiteMagic(p)
-				if err != nil {
-					if !errors.Is(err, fs.ErrPermission) {
-						errs <- fmt.Errorf("%s: %w", p, err)
-					}
+			for c := range paths {
+				if ctx.Err() != nil {
 					continue
 				}
-				if ok {
-					matches <- res
-				}
+				check(c)
 			}
 		}()
 	}
@@ -254,27 +2276,155 @@ func scanPaths(roots []string, workers int, matches chan<- matchResult, errs cha
 	var walkErrMu sync.Mutex
 	var walkWg sync.WaitGroup
 
-	for _, root := range roots {
-		walkWg.Add(1)
-		go func(r string) {
-			defer walkWg.Done()
-			err := filepath.WalkDir(r, func(path string, d fs.DirEntry, err error) error {
+	// offer queues a regular file found under a root with the given label
+	// for checking, applying walk-time filters.
+	offer := func(label, path string, d fs.DirEntry) error {
+		if opts.Check.Stats != nil {
+			opts.Check.Stats.FilesExamined.Add(1)
+		}
+		if opts.Extensions != nil {
+			opts.Extensions.addPath(path)
+		}
+		if !checkUTF8Path(logger, opts.UTF8Paths, path) {
+			if opts.Cache != nil {
+				opts.Cache.markIncomplete(path)
+			}
+			return nil
+		}
+		if !opts.Since.IsZero() {
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if !info.ModTime().After(opts.Since) {
+				logger.Debug("skipping file not modified since reference", "path", path)
+				return nil
+			}
+		}
+		c := classify(path, label)
+		if opts.Deterministic {
+			check(c)
+			return nil
+		}
+		select {
+		case paths <- c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	// emitCached reports a match remembered by --cache-file without
+	// opening the file again.
+	emitCached := func(label string, m matchResult) error {
+		if !checkUTF8Path(logger, opts.UTF8Paths, m.Path) {
+			return nil
+		}
+		m.Label = label
+		if !keepMatch(m, opts.Filters) {
+			return nil
+		}
+		if known != nil {
+			known.add(m.Path)
+		}
+		select {
+		case matches <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	walkRoot := func(r string) {
+		label := opts.Labels[r]
+		// enterDir applies --one-file-system by refusing directories
+		// on a different device from the root.
+		var enterDir func(string, fs.FileInfo) bool
+		if opts.OneFileSystem {
+			if info, err := os.Stat(r); err == nil {
+				if rootDev, ok := deviceID(info); ok {
+					enterDir = func(path string, info fs.FileInfo) bool {
+						dev, ok := deviceID(info)
+						if ok && dev != rootDev {
+							logger.Debug("not crossing into another filesystem", "path", path)
+							return false
+						}
+						return true
+					}
+				}
+			}
+		}
+		var err error
+		if opts.Cache != nil {
+			err = opts.Cache.walk(ctx, r, walkHooks{
+				logger:   logger,
+				denied:   opts.Denied,
+				enterDir: enterDir,
+				offer: func(path string, d fs.DirEntry) error {
+					return offer(label, path, d)
+				},
+				emit: func(m matchResult) error {
+					return emitCached(label, m)
+				},
+				newestFirst: opts.NewestFirst,
+			})
+		} else {
+			err = filepath.WalkDir(r, func(path string, d fs.DirEntry, err error) error {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
 				if err != nil {
 					if errors.Is(err, fs.ErrPermission) {
+						logger.Debug("skipping unreadable path", "path", path)
+						opts.Denied.add(path)
 						return nil
 					}
 					return err
 				}
-				if d.Type().IsRegular() {
-					paths <- path
+				if d.IsDir() {
+					if enterDir != nil && path != r {
+						info, err := d.Info()
+						if err == nil && !enterDir(path, info) {
+							return filepath.SkipDir
+						}
+					}
+					logger.Debug("entering directory", "path", path)
+					if opts.NewestFirst {
+						// WalkDir only yields entries in lexical
+						// order, so read the directory a second time
+						// to offer its files newest first; errors
+						// are left for WalkDir to report.
+						entries, _ := os.ReadDir(path)
+						for _, e := range newestFiles(entries) {
+							if err := offer(label, filepath.Join(path, e.Name()), e); err != nil {
+								return err
+							}
+						}
+					}
+					return nil
+				}
+				if !d.Type().IsRegular() || opts.NewestFirst {
+					return nil
 				}
-				return nil
+				return offer(label, path, d)
 			})
-			if err != nil {
-				walkErrMu.Lock()
-				walkErr = errors.Join(walkErr, err)
-				walkErrMu.Unlock()
-			}
+		}
+		if err != nil {
+			walkErrMu.Lock()
+			walkErr = errors.Join(walkErr, err)
+			walkErrMu.Unlock()
+		}
+	}
+
+	for _, root := range roots {
+		if opts.Deterministic {
+			walkRoot(root)
+			continue
+		}
+		walkWg.Add(1)
+		go func(r string) {
+			defer walkWg.Done()
+			walkRoot(r)
 		}(root)
 	}
 
@@ -284,38 +2434,152 @@ func scanPaths(roots []string, workers int, matches chan<- matchResult, errs cha
 	}()
 
 	workerWg.Wait()
+	// The watchers find files by path and label alone.
+	checkPath := func(p, label string) bool {
+		return check(classify(p, label))
+	}
+	if opts.Watch && walkErr == nil && ctx.Err() == nil {
+		if err := watchRoots(ctx, roots, opts, errs, checkPath); err != nil {
+			walkErr = fmt.Errorf("--watch: %w", err)
+		}
+	}
+	if opts.InotifyWatch && walkErr == nil && ctx.Err() == nil {
+		removed := func(path, label string) {
+			matches <- matchResult{Path: path, Label: label, Event: eventRemoved}
+		}
+		if err := inotifyWatchRoots(ctx, roots, opts, errs, checkPath, known, removed); err != nil {
+			walkErr = fmt.Errorf("--inotify-watch: %w", err)
+		}
+	}
 	close(matches)
 	close(errs)
 
 	return walkErr
 }
 
-func checkSQLiteMagic(path string) (matchResult, bool, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return matchResult{}, false, err
+func keepMatch(m matchResult, filters []matchFilter) bool {
+	for _, f := range filters {
+		if !f(m) {
+			return false
+		}
 	}
-	defer f.Close()
+	return true
+}
 
-	buf := make([]byte, len(sqliteMagic))
-	if _, err := io.ReadFull(f, buf); err != nil {
-		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
-			return matchResult{}, false, nil
+// openFile is swapped out in tests to observe the flags used for opening.
+var openFile = os.OpenFile
+
+// openForCheck opens path read-only, adding O_NOATIME when requested. The
+// kernel refuses O_NOATIME for files the caller does not own, so that case
+// retries with a plain open.
+func openForCheck(path string, noATime bool) (*os.File, error) {
+	if noATime && oNoATime != 0 {
+		f, err := openFile(path, os.O_RDONLY|oNoATime, 0)
+		if err == nil || !errors.Is(err, fs.ErrPermission) {
+			return f, err
 		}
+	}
+	return openFile(path, os.O_RDONLY, 0)
+}
+
+func checkSQLiteMagic(path string, opts checkOptions) (res matchResult, ok bool, err error) {
+	span := startCheckSpan(opts, path)
+	defer func() { endCheckSpan(span, ok, err) }()
+	f, buf, n, err := readHeader(path, opts)
+	if opts.Stats != nil {
+		opts.Stats.FilesChecked.Add(1)
+	}
+	if err != nil {
 		return matchResult{}, false, err
 	}
+	defer func() {
+		if opts.Fadvise {
+			adviseDontNeed(f)
+		}
+		f.Close()
+	}()
 
-	if !bytes.Equal(buf, sqliteMagic) {
+	res, ok = matchHeader(buf[:n], opts.WALFiles)
+	if !ok {
+		if n == 0 && opts.ZeroSize && isPlaceholderName(path) {
+			return placeholderMatch(f, path, opts)
+		}
 		return matchResult{}, false, nil
 	}
+	res.Path = path
+	res.Size = -1
+	res.UID, res.GID = -1, -1
+
+	if opts.MaxWholeFileSize > 0 && opts.readsWholeFile(opts.MaxWholeFileSize) {
+		info, err := f.Stat()
+		if err != nil {
+			return matchResult{}, false, err
+		}
+		if info.Size() > opts.MaxWholeFileSize {
+			return matchResult{}, false, fmt.Errorf("%w: %d bytes, limit %d", errLargerThanRAM, info.Size(), opts.MaxWholeFileSize)
+		}
+	}
+
+	if opts.Hash {
+		sum, extra, err := hashFile(opts.newHash(), buf[:n], f, opts.HashMaxBytes)
+		if opts.Stats != nil {
+			opts.Stats.BytesRead.Add(extra)
+		}
+		if err != nil {
+			return matchResult{}, false, err
+		}
+		res.setHash(sum, opts)
+		if opts.HashMaxBytes > 0 {
+			hashed := min(int64(n), opts.HashMaxBytes) + extra
+			info, err := f.Stat()
+			if err != nil {
+				return matchResult{}, false, err
+			}
+			if info.Size() > hashed {
+				res.HashPartial = true
+				res.HashedBytes = hashed
+			}
+		}
+	}
+
+	if opts.LockCheck {
+		res.Locked, err = fileLocked(f)
+		if err != nil {
+			return matchResult{}, false, err
+		}
+	}
+
+	if opts.SharedLocks {
+		res.SharedLockCount, err = sharedLockCount(f)
+		if err != nil {
+			return matchResult{}, false, err
+		}
+	}
+
+	if opts.CountTables && res.Kind == kindDatabase {
+		res.Tables = countTables(f, buf[:n])
+	}
+
+	if opts.OpenCheck && res.Kind == kindDatabase {
+		res.Openable, res.OpenFailure = openCheck(path)
+	}
+
+	if opts.SkipStat {
+		return res, true, nil
+	}
 
 	info, err := f.Stat()
 	if err != nil {
 		return matchResult{}, false, err
 	}
-
-	return matchResult{
-		Path: path,
-		Size: info.Size(),
-	}, true, nil
+	res.Size = info.Size()
+	res.ModTime = info.ModTime()
+	res.estimatePageCount()
+	res.Mode = info.Mode().Perm()
+	if opts.Owner {
+		if uid, gid, ok := fileOwner(info); ok {
+			res.UID, res.GID = uid, gid
+		}
+	}
+	return res, true, nil
 }
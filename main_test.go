@@ -2,13 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCheckSQLiteMagic(t *testing.T) {
@@ -19,7 +24,7 @@ func TestCheckSQLiteMagic(t *testing.T) {
 		t.Fatalf("write db: %v", err)
 	}
 
-	res, ok, err := checkSQLiteMagic(dbPath)
+	res, ok, err := checkSQLiteMagic(dbPath, checkOptions{})
 	if err != nil {
 		t.Fatalf("checkSQLiteMagic: %v", err)
 	}
@@ -38,13 +43,118 @@ func TestCheckSQLiteMagic(t *testing.T) {
 		t.Fatalf("write bad file: %v", err)
 	}
 
-	if _, ok, err := checkSQLiteMagic(badPath); err != nil {
+	if _, ok, err := checkSQLiteMagic(badPath, checkOptions{}); err != nil {
 		t.Fatalf("check bad file: %v", err)
 	} else if ok {
 		t.Fatalf("expected bad header to be rejected")
 	}
 }
 
+func TestCheckSQLiteMagicSkipStat(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "good.db")
+	content := append(append([]byte{}, sqliteMagic...), []byte("payload")...)
+	if err := os.WriteFile(dbPath, content, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	res, ok, err := checkSQLiteMagic(dbPath, checkOptions{SkipStat: true})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if res.Size != -1 {
+		t.Fatalf("expected size -1 without stat, got %d", res.Size)
+	}
+}
+
+func BenchmarkCheckSQLiteMagic(b *testing.B) {
+	dbPath := filepath.Join(b.TempDir(), "bench.db")
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 4096)...)
+	if err := os.WriteFile(dbPath, content, 0o600); err != nil {
+		b.Fatalf("write db: %v", err)
+	}
+	for _, skip := range []bool{false, true} {
+		name := "stat"
+		if skip {
+			name = "no-stat"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := checkSQLiteMagic(dbPath, checkOptions{SkipStat: skip}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScanNoOutput scans a synthetic tree printing plain text to
+// io.Discard and with --no-output, to separate the cost of formatting
+// matches from the scan itself.
+func BenchmarkScanNoOutput(b *testing.B) {
+	root := b.TempDir()
+	for i := 0; i < 2000; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%02d", i%20))
+		os.MkdirAll(dir, 0o755)
+		content := []byte("not a database")
+		if i%4 == 0 {
+			content = sqliteMagic
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d.db", i)), content, 0o600); err != nil {
+			b.Fatalf("write: %v", err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, discard := range []bool{false, true} {
+		b.Run(fmt.Sprintf("no-output=%t", discard), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matches := make(chan matchResult, 64)
+				errs := make(chan error, 64)
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					streamMatches(context.Background(), io.Discard, matches, outputOptions{Discard: discard, ShowSize: true})
+				}()
+				opts := scanOptions{Workers: 4, Logger: logger}
+				if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+					b.Fatal(err)
+				}
+				<-done
+			}
+		})
+	}
+}
+
+func TestStreamMatchesDiscardPrintsNothing(t *testing.T) {
+	for _, opts := range []outputOptions{{Discard: true}, {Discard: true, JSON: true}, {Discard: true, JSONL: true, NullSafe: true}} {
+		matches := make(chan matchResult, 2)
+		matches <- matchResult{Path: "/data/a.db"}
+		matches <- matchResult{Path: "/data/b.db"}
+		close(matches)
+		out := captureStdout(t, func() {
+			streamMatches(context.Background(), os.Stdout, matches, opts)
+		})
+		if out != "" {
+			t.Fatalf("expected no stdout with %+v, got %q", opts, out)
+		}
+		if len(matches) != 0 {
+			t.Fatalf("expected every match drained, %d left", len(matches))
+		}
+	}
+}
+
+// collectSQLiteFiles gathers everything findSQLiteFiles yields, returning
+// the error from its final pair, if any.
+func collectSQLiteFiles(ctx context.Context, roots []string, workers int) ([]matchResult, error) {
+	var out []matchResult
+	for m, err := range findSQLiteFiles(ctx, roots, workers) {
+		if err != nil {
+			return out, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
 func TestFindSQLiteFilesMultipleRoots(t *testing.T) {
 	rootA := t.TempDir()
 	rootB := t.TempDir()
@@ -59,7 +169,7 @@ func TestFindSQLiteFilesMultipleRoots(t *testing.T) {
 		t.Fatalf("create placeholder: %v", err)
 	}
 
-	results, err := findSQLiteFiles([]string{rootA, rootB}, runtime.NumCPU())
+	results, err := collectSQLiteFiles(context.Background(), []string{rootA, rootB}, runtime.NumCPU())
 	if err != nil {
 		t.Fatalf("findSQLiteFiles: %v", err)
 	}
@@ -75,6 +185,56 @@ func TestFindSQLiteFilesMultipleRoots(t *testing.T) {
 	}
 }
 
+func TestFindSQLiteFilesCancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := collectSQLiteFiles(ctx, []string{root}, runtime.NumCPU())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results after cancel, got %v", results)
+	}
+}
+
+func TestFindSQLiteFilesStopEarly(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 200; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%03d.db", i)), sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+
+	// Breaking out must cancel the scan and return rather than block on
+	// the unread matches.
+	done := make(chan int)
+	go func() {
+		n := 0
+		for _, err := range findSQLiteFiles(context.Background(), []string{root}, 4) {
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if n++; n == 3 {
+				break
+			}
+		}
+		done <- n
+	}()
+	select {
+	case n := <-done:
+		if n != 3 {
+			t.Fatalf("expected to stop after 3 matches, got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("findSQLiteFiles did not stop after break")
+	}
+}
+
 func TestResolveRootsFollowsSymlinks(t *testing.T) {
 	root := t.TempDir()
 	target := filepath.Join(root, "real")
@@ -106,7 +266,7 @@ func TestJSONCommaPlacement(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, true, false, true)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, ShowSize: true})
 	})
 	if strings.Contains(out, "\n,\n") {
 		t.Fatalf("got comma on its own line:\n%s", out)
@@ -122,7 +282,7 @@ func TestStreamMatchesPlainTextNoSize(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, false, false, false)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{})
 	})
 	if strings.Contains(out, "bytes") {
 		t.Fatalf("expected no size in output, got: %s", out)
@@ -138,7 +298,7 @@ func TestStreamMatchesPlainTextWithSize(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, false, false, true)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{ShowSize: true})
 	})
 	if !strings.Contains(out, "(456 bytes)") {
 		t.Fatalf("expected size in output, got: %s", out)
@@ -151,7 +311,7 @@ func TestStreamMatchesJSONNoSize(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, true, false, false)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true})
 	})
 	if strings.Contains(out, "\"size\"") {
 		t.Fatalf("expected no size field, got: %s", out)
@@ -170,7 +330,7 @@ func TestStreamMatchesJSONWithSize(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, true, false, true)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, ShowSize: true})
 	})
 	if !strings.Contains(out, "\"size\": 222") {
 		t.Fatalf("expected size field, got: %s", out)
@@ -187,7 +347,7 @@ func TestStreamMatchesJSONLNoSize(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, false, true, false)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSONL: true})
 	})
 	lines := strings.Split(strings.TrimSpace(out), "\n")
 	if len(lines) != 2 {
@@ -213,7 +373,7 @@ func TestStreamMatchesJSONLWithSize(t *testing.T) {
 	close(matches)
 
 	out := captureStdout(t, func() {
-		streamMatches(matches, false, true, true)
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSONL: true, ShowSize: true})
 	})
 	line := strings.TrimSpace(out)
 	if !strings.Contains(line, "\"size\"") {
@@ -228,6 +388,204 @@ func TestStreamMatchesJSONLWithSize(t *testing.T) {
 	}
 }
 
+func TestStreamMatchesJSONNoMatches(t *testing.T) {
+	matches := make(chan matchResult)
+	close(matches)
+
+	out := captureStdout(t, func() {
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, ShowSize: true})
+	})
+	var obj struct {
+		Entries []map[string]any `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(out), &obj); err != nil {
+		t.Fatalf("invalid JSON for zero matches: %v\n%s", err, out)
+	}
+	if obj.Entries == nil || len(obj.Entries) != 0 {
+		t.Fatalf("expected empty entries array, got: %s", out)
+	}
+}
+
+func TestStreamMatchesJSONLNullSafe(t *testing.T) {
+	for _, nullSafe := range []bool{false, true} {
+		matches := make(chan matchResult)
+		close(matches)
+		var buf bytes.Buffer
+		streamMatches(context.Background(), &buf, matches, outputOptions{JSONL: true, NullSafe: nullSafe})
+		want := ""
+		if nullSafe {
+			want = "{\"_empty\":true}\n"
+		}
+		if buf.String() != want {
+			t.Fatalf("NullSafe=%v: expected %q for zero matches, got %q", nullSafe, want, buf.String())
+		}
+	}
+
+	matches := make(chan matchResult, 1)
+	matches <- matchResult{Path: "/data/app.db"}
+	close(matches)
+	var buf bytes.Buffer
+	streamMatches(context.Background(), &buf, matches, outputOptions{JSONL: true, NullSafe: true})
+	if strings.Contains(buf.String(), "_empty") {
+		t.Fatalf("expected no sentinel alongside a match, got %q", buf.String())
+	}
+}
+
+func TestHostTagsEntries(t *testing.T) {
+	m := matchResult{Path: "/data/app.db"}
+	if got := formatJSONLine(m, outputOptions{}); strings.Contains(got, `"host"`) {
+		t.Fatalf("expected no host field by default, got %s", got)
+	}
+	opts := outputOptions{Host: "db-01"}
+	if got := formatJSONLine(m, opts); !strings.Contains(got, `"host":"db-01"`) {
+		t.Fatalf("expected the host override in JSON, got %s", got)
+	}
+	if got := formatPlainMatch(m, opts); got != "db-01:/data/app.db" {
+		t.Fatalf("expected host:path in plain output, got %s", got)
+	}
+}
+
+func TestOutputSchemaReflectsFlags(t *testing.T) {
+	schema := outputSchema(outputOptions{ShowSize: true})
+	if schema["$schema"] != "http://json-schema.org/draft-07/schema#" {
+		t.Fatalf("expected draft-07 schema, got %v", schema["$schema"])
+	}
+	items := schema["properties"].(map[string]any)["entries"].(map[string]any)["items"].(map[string]any)
+	if _, ok := items["properties"].(map[string]any)["size"]; !ok {
+		t.Fatalf("expected size property with --size, got %v", items)
+	}
+
+	line := outputSchema(outputOptions{JSONL: true})
+	if line["type"] != "object" {
+		t.Fatalf("expected JSONL schema to describe an object, got %v", line["type"])
+	}
+	if _, ok := line["properties"].(map[string]any)["size"]; ok {
+		t.Fatalf("expected no size property without --size, got %v", line)
+	}
+	if _, err := json.Marshal(line); err != nil {
+		t.Fatalf("schema not serializable: %v", err)
+	}
+}
+
+func TestScanPathsSince(t *testing.T) {
+	root := t.TempDir()
+	content := append(append([]byte{}, sqliteMagic...), []byte("payload")...)
+	oldDB := filepath.Join(root, "old.db")
+	newDB := filepath.Join(root, "new.db")
+	for _, p := range []string{oldDB, newDB} {
+		if err := os.WriteFile(p, content, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	ref := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(oldDB, ref.Add(-time.Hour), ref.Add(-time.Hour)); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Since: ref}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	var got []string
+	for m := range matches {
+		got = append(got, m.Path)
+	}
+	if len(got) != 1 || got[0] != newDB {
+		t.Fatalf("expected only %q, got %v", newDB, got)
+	}
+}
+
+func TestScanPathsCancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	err := scanPaths(ctx, []string{root}, opts, matches, errs)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for m := range matches {
+		t.Fatalf("expected no matches after cancel, got %v", m)
+	}
+}
+
+func TestStreamMatchesJSONTruncated(t *testing.T) {
+	matches := make(chan matchResult, 1)
+	matches <- matchResult{Path: "a.db", Size: 1}
+	close(matches)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out := captureStdout(t, func() {
+		streamMatches(ctx, os.Stdout, matches, outputOptions{JSON: true})
+	})
+	var obj struct {
+		Entries   []map[string]any `json:"entries"`
+		Truncated bool             `json:"truncated"`
+	}
+	if err := json.Unmarshal([]byte(out), &obj); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if !obj.Truncated || len(obj.Entries) != 1 {
+		t.Fatalf("expected truncated output with 1 entry, got:\n%s", out)
+	}
+}
+
+func TestScanPathsLogLevels(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("not sqlite"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	scan := func(level string) string {
+		var buf bytes.Buffer
+		logger, err := newLogger(&buf, level)
+		if err != nil {
+			t.Fatalf("newLogger: %v", err)
+		}
+		matches := make(chan matchResult, 4)
+		errs := make(chan error, 4)
+		go func() {
+			for range matches {
+			}
+		}()
+		go func() {
+			for range errs {
+			}
+		}()
+		if err := scanPaths(context.Background(), []string{root}, scanOptions{Workers: 2, Logger: logger}, matches, errs); err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+		logger.Warn("synthetic warning")
+		return buf.String()
+	}
+
+	if out := scan("error"); strings.Contains(out, "level=WARN") || strings.Contains(out, "level=DEBUG") {
+		t.Fatalf("expected no warn or debug records at error level, got:\n%s", out)
+	}
+	out := scan("debug")
+	if !strings.Contains(out, "entering directory") || !strings.Contains(out, root) {
+		t.Fatalf("expected directory-entry log at debug level, got:\n%s", out)
+	}
+	if !strings.Contains(out, "skipping non-SQLite file") {
+		t.Fatalf("expected skipped-file log at debug level, got:\n%s", out)
+	}
+}
+
+func TestNewLoggerRejectsUnknownLevel(t *testing.T) {
+	if _, err := newLogger(io.Discard, "loud"); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+}
+
 func captureStdout(t *testing.T, fn func()) string {
 	t.Helper()
 	r, w, err := os.Pipe()
@@ -251,3 +609,135 @@ func captureStdout(t *testing.T, fn func()) string {
 
 	return buf.String()
 }
+
+func TestScanPathsDeterministicOrder(t *testing.T) {
+	rootA := t.TempDir()
+	rootB := t.TempDir()
+	for _, p := range []string{
+		filepath.Join(rootA, "z.db"),
+		filepath.Join(rootA, "a.db"),
+		filepath.Join(rootA, "m", "inner.db"),
+		filepath.Join(rootB, "b.db"),
+		filepath.Join(rootB, "a.db"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+
+	run := func() []string {
+		opts := scanOptions{Workers: 8, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Deterministic: true}
+		matches := make(chan matchResult, 8)
+		errs := make(chan error, 8)
+		if err := scanPaths(context.Background(), []string{rootA, rootB}, opts, matches, errs); err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+		var got []string
+		for m := range matches {
+			got = append(got, m.Path)
+		}
+		return got
+	}
+
+	want := []string{
+		filepath.Join(rootA, "a.db"),
+		filepath.Join(rootA, "m", "inner.db"),
+		filepath.Join(rootA, "z.db"),
+		filepath.Join(rootB, "a.db"),
+		filepath.Join(rootB, "b.db"),
+	}
+	for i := 0; i < 5; i++ {
+		if got := run(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("run %d: expected order\n%v\ngot\n%v", i, want, got)
+		}
+	}
+}
+
+func TestCWDRelativeOutput(t *testing.T) {
+	base := t.TempDir()
+	cwd := filepath.Join(base, "work")
+	root := filepath.Join(base, "data", "nested")
+	for _, dir := range []string{cwd, root} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "app.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Chdir(cwd)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+
+	results, err := collectSQLiteFiles(context.Background(), []string{root}, 1)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected one match, got %v (err %v)", results, err)
+	}
+	opts := outputOptions{RelativeTo: wd}
+	want := filepath.Join("..", "data", "nested", "app.db")
+	if got := formatPlainMatch(results[0], opts); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	opts.JSONL = true
+	if got := formatJSONLine(results[0], opts); got != `{"path":`+marshalJSON(want, "", "")+`}` {
+		t.Fatalf("unexpected JSONL: %s", got)
+	}
+}
+
+func TestTrimPrefixOutput(t *testing.T) {
+	opts := outputOptions{TrimPrefix: "/data/exports"}
+	for path, want := range map[string]string{
+		"/data/exports/app.db":          "app.db",
+		"/data/exports/a/b/app.db":      "a/b/app.db",
+		"/data/exports2/app.db":         "/data/exports2/app.db",
+		"/srv/app.db":                   "/srv/app.db",
+		"/data/exports":                 "/data/exports",
+		"s3://bucket/data/exports/a.db": "s3://bucket/data/exports/a.db",
+	} {
+		if got := formatPlainMatch(matchResult{Path: path}, opts); got != want {
+			t.Fatalf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+	if got := trimPathPrefix("/data/exports/app.db", "/"); got != "data/exports/app.db" {
+		t.Fatalf("trimming the root: got %q", got)
+	}
+	opts.JSONL = true
+	if got := formatJSONLine(matchResult{Path: "/data/exports/a/app.db"}, opts); got != `{"path":"a/app.db"}` {
+		t.Fatalf("unexpected JSONL: %s", got)
+	}
+}
+
+func TestMatchResultText(t *testing.T) {
+	m := matchResult{Path: "data/app.db", Size: 4096, Kind: kindDatabase, UID: -1, GID: -1}
+	want, err := filepath.Abs("data/app.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.String(); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if text, err := m.MarshalText(); err != nil || string(text) != want {
+		t.Fatalf("expected MarshalText to return %q, got %q, %v", want, text, err)
+	}
+	if got := (matchResult{Path: "s3://bucket/app.db"}).String(); got != "s3://bucket/app.db" {
+		t.Fatalf("expected a remote URI unchanged, got %q", got)
+	}
+
+	// JSON still carries every field, as --cache-file needs.
+	b, err := json.Marshal([]matchResult{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back []matchResult
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatalf("unmarshal %s: %v", b, err)
+	}
+	if len(back) != 1 || back[0].Path != m.Path || back[0].Size != m.Size || back[0].UID != -1 {
+		t.Fatalf("expected %+v back, got %s", m, b)
+	}
+}
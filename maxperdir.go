@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// maxPerDirFilter keeps at most max matches from each directory, dropping
+// the rest, so a cache directory full of tiny databases cannot drown out
+// everything else. It is safe for concurrent use by workers; which matches
+// are kept depends on the order workers finish. Each directory counted
+// takes a slot from limit.
+func maxPerDirFilter(max int, limit *bufferLimit) matchFilter {
+	var mu sync.Mutex
+	counts := map[string]int{}
+	return func(m matchResult) bool {
+		dir := filepath.Dir(m.Path)
+		mu.Lock()
+		defer mu.Unlock()
+		if counts[dir] >= max {
+			return false
+		}
+		if counts[dir] == 0 && !limit.take("--max-matches-per-dir") {
+			return false
+		}
+		counts[dir]++
+		return true
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMaxMatchesPerDir(t *testing.T) {
+	root := t.TempDir()
+	cache := filepath.Join(root, "cache")
+	if err := os.Mkdir(cache, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(cache, fmt.Sprintf("%d.db", i)), sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "app.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	matches := make(chan matchResult, 8)
+	errs := make(chan error, 8)
+	opts := scanOptions{Workers: 3, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Filters: []matchFilter{maxPerDirFilter(2, nil)}}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	perDir := map[string]int{}
+	for m := range matches {
+		perDir[filepath.Dir(m.Path)]++
+	}
+	if perDir[cache] != 2 || perDir[root] != 1 {
+		t.Fatalf("expected 2 matches from cache and 1 from the root, got %v", perDir)
+	}
+}
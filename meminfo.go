@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// errLargerThanRAM is the warning for a match --skip-larger-than-ram
+// skipped rather than reading it whole.
+var errLargerThanRAM = errors.New("skipped by --skip-larger-than-ram")
+
+// parseMemAvailable returns the MemAvailable line of /proc/meminfo in
+// bytes.
+func parseMemAvailable(r io.Reader) (int64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		rest, ok := strings.CutPrefix(s.Text(), "MemAvailable:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) != 2 || fields[1] != "kB" {
+			return 0, fmt.Errorf("unexpected MemAvailable line %q", s.Text())
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected MemAvailable line %q", s.Text())
+		}
+		return kb * 1024, nil
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("no MemAvailable line")
+}
+
+// readsWholeFile reports whether checking a match with opts reads the
+// whole file: hashing without a --hash-max-bytes below limit.
+func (o checkOptions) readsWholeFile(limit int64) bool {
+	return o.Hash && (o.HashMaxBytes == 0 || o.HashMaxBytes > limit)
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package main
+
+import "os"
+
+// availableMemory is how much memory can be used without swapping, from
+// MemAvailable in /proc/meminfo.
+func availableMemory() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMemAvailable(f)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func availableMemory() (int64, error) {
+	return 0, errors.New("--skip-larger-than-ram is not supported on this platform")
+}
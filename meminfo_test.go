@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMemAvailable(t *testing.T) {
+	meminfo := "MemTotal:       16318412 kB\nMemFree:         1203344 kB\nMemAvailable:    8123456 kB\n"
+	got, err := parseMemAvailable(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 8123456*1024 {
+		t.Fatalf("expected %d bytes, got %d", 8123456*1024, got)
+	}
+	if _, err := parseMemAvailable(strings.NewReader("MemTotal: 1 kB\n")); err == nil {
+		t.Fatal("expected an error without a MemAvailable line")
+	}
+}
+
+func TestSkipLargerThanRAM(t *testing.T) {
+	root := t.TempDir()
+	small := filepath.Join(root, "small.db")
+	large := filepath.Join(root, "large.db")
+	if err := os.WriteFile(small, sqliteMagic, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(large, append(append([]byte{}, sqliteMagic...), bytes.Repeat([]byte{0}, 8192)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	opts.Check.Hash = true
+	opts.Check.MaxWholeFileSize = 4096
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	var found []string
+	for m := range matches {
+		found = append(found, m.Path)
+	}
+	var warnings []error
+	for err := range errs {
+		warnings = append(warnings, err)
+	}
+	if len(found) != 1 || found[0] != small {
+		t.Fatalf("expected only %s to be reported, got %v", small, found)
+	}
+	if len(warnings) != 1 || !errors.Is(warnings[0], errLargerThanRAM) || !strings.Contains(warnings[0].Error(), large) {
+		t.Fatalf("expected a warning naming %s, got %v", large, warnings)
+	}
+
+	// A --hash-max-bytes below the limit never reads the file whole.
+	opts.Check.HashMaxBytes = 1024
+	if _, ok, err := checkSQLiteMagic(large, opts.Check); !ok || err != nil {
+		t.Fatalf("expected the truncated hash to be kept, got %v, %v", ok, err)
+	}
+}
+
+func TestSkipLargerThanRAMIsNotCached(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big.db")
+	if err := os.WriteFile(big, append(append([]byte{}, sqliteMagic...), bytes.Repeat([]byte{0}, 8192)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	limited := checkOptions{Hash: true, MaxWholeFileSize: 4096, RAMFraction: 0.5}
+	unlimited := checkOptions{Hash: true}
+	if limited.cacheKey() == unlimited.cacheKey() {
+		t.Fatal("expected --skip-larger-than-ram to change the cache key")
+	}
+
+	run := func(check checkOptions, key string) []string {
+		cache, err := loadScanCache(cachePath, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Check: check, Cache: cache}
+		matches := make(chan matchResult, 4)
+		errs := make(chan error, 4)
+		if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+		var found []string
+		for m := range matches {
+			found = append(found, m.Path)
+		}
+		for range errs {
+		}
+		if err := cache.save(cachePath); err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	if found := run(limited, limited.cacheKey()); len(found) != 0 {
+		t.Fatalf("expected big.db to be skipped, got %v", found)
+	}
+	// Even a run sharing the key must look at the skipped file again,
+	// e.g. once more memory is free.
+	if found := run(unlimited, limited.cacheKey()); len(found) != 1 || found[0] != big {
+		t.Fatalf("expected big.db to be found on the next run, got %v", found)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSupported is false where golang.org/x/sys/unix.Mmap is unavailable,
+// making --mmap an error.
+const mmapSupported = false
+
+func readHeaderMmap(*os.File, []byte) (int, error) {
+	return 0, errors.New("--mmap is not supported on this platform")
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const mmapSupported = true
+
+// mmapWindow is how much of each file --mmap maps: one page on the
+// platforms we run on, which covers the 100-byte header.
+const mmapWindow = 4096
+
+// readHeaderMmap fills buf from the start of f by mapping its first page
+// (or the whole file when smaller) read-only, copying the bytes out and
+// unmapping it again, instead of calling read. Like io.ReadFull it returns
+// the number of bytes copied; a short file is not an error. Files that
+// cannot be mapped, such as those on some FUSE or /proc filesystems, fall
+// back to read.
+func readHeaderMmap(f *os.File, buf []byte) (int, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// Mapping an empty file succeeds, but touching the page faults.
+	size := min(info.Size(), mmapWindow)
+	if size <= 0 {
+		return 0, nil
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return io.ReadFull(f, buf)
+	}
+	n := copy(buf, data)
+	if err := unix.Munmap(data); err != nil {
+		return n, err
+	}
+	// Leave the offset after the header, as read would, for --hash.
+	if _, err := f.Seek(int64(n), io.SeekStart); err != nil {
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSQLiteMagicMmap(t *testing.T) {
+	dir := t.TempDir()
+	db := writeHeader(t, dir, "main.db", func(hdr []byte) {
+		hdr[18], hdr[19] = 2, 2
+	})
+	bare := filepath.Join(dir, "bare.db")
+	empty := filepath.Join(dir, "empty")
+	text := filepath.Join(dir, "notes.txt")
+	os.WriteFile(bare, sqliteMagic, 0o600)
+	os.WriteFile(empty, nil, 0o600)
+	os.WriteFile(text, []byte("not a database"), 0o600)
+
+	for _, path := range []string{db, bare, empty, text} {
+		want, wantOK, err := checkSQLiteMagic(path, checkOptions{Hash: true})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		got, ok, err := checkSQLiteMagic(path, checkOptions{Hash: true, Mmap: true})
+		if err != nil {
+			t.Fatalf("%s with --mmap: %v", path, err)
+		}
+		// The hash covers the whole file, so it also checks that reading
+		// continues after the mapped header rather than from the start.
+		if ok != wantOK || got != want {
+			t.Fatalf("%s: --mmap gave %+v ok=%v, read gave %+v ok=%v", path, got, ok, want, wantOK)
+		}
+	}
+}
+
+// BenchmarkCheckSQLiteMagicMmap compares --mmap with read on warm files in
+// the temporary directory and, where it exists, on the /dev/shm ramdisk.
+func BenchmarkCheckSQLiteMagicMmap(b *testing.B) {
+	dirs := map[string]string{"tmpdir": b.TempDir()}
+	if shm, err := os.MkdirTemp("/dev/shm", "sqlite-scanner-bench"); err == nil {
+		defer os.RemoveAll(shm)
+		dirs["shm"] = shm
+	}
+	content := append(append([]byte{}, sqliteMagic...), make([]byte, 8192)...)
+	for name, dir := range dirs {
+		var paths []string
+		for i := 0; i < 1000; i++ {
+			p := filepath.Join(dir, fmt.Sprintf("%04d.db", i))
+			if err := os.WriteFile(p, content, 0o600); err != nil {
+				b.Fatalf("write: %v", err)
+			}
+			paths = append(paths, p)
+		}
+		for _, mmap := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/mmap=%t", name, mmap), func(b *testing.B) {
+				opts := checkOptions{SkipStat: true, Mmap: mmap}
+				for i := 0; i < b.N; i++ {
+					if _, ok, err := checkSQLiteMagic(paths[i%len(paths)], opts); err != nil || !ok {
+						b.Fatalf("check: ok=%v err=%v", ok, err)
+					}
+				}
+			})
+		}
+	}
+}
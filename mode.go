@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// formatMode renders permission bits in octal as chmod takes them, e.g.
+// 0644.
+func formatMode(mode fs.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// worldReadableFilter keeps files whose other-read bit is set, which any
+// user on the machine can open (--world-readable-only). Matches of
+// unknown mode are dropped.
+func worldReadableFilter(m matchResult) bool {
+	return m.Mode&0o004 != 0
+}
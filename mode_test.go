@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestModeOutputAndWorldReadableFilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no other-read bit")
+	}
+	dir := t.TempDir()
+	modes := map[string]os.FileMode{"private.db": 0o600, "shared.db": 0o644}
+	results := map[string]matchResult{}
+	for name, mode := range modes {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("chmod: %v", err)
+		}
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+		}
+		if res.Mode != mode {
+			t.Fatalf("%s: expected mode %o, got %o", name, mode, res.Mode)
+		}
+		results[name] = res
+	}
+
+	opts := outputOptions{ShowMode: true}
+	if got := formatPlainMatch(results["shared.db"], opts); !strings.HasSuffix(got, "[mode 0644]") {
+		t.Fatalf("expected [mode 0644] in plain output, got %s", got)
+	}
+	if got := formatJSONLine(results["private.db"], opts); !strings.Contains(got, `"mode":"0600"`) {
+		t.Fatalf("expected mode in JSON, got %s", got)
+	}
+
+	if worldReadableFilter(results["private.db"]) {
+		t.Fatalf("expected a 0600 file to be dropped")
+	}
+	if !worldReadableFilter(results["shared.db"]) {
+		t.Fatalf("expected a 0644 file to be kept")
+	}
+}
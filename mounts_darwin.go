@@ -0,0 +1,33 @@
+//go:build darwin
+
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// mountRoots lists local mount points via getfsstat (what getmntinfo wraps),
+// skipping network filesystems and devfs/autofs.
+func mountRoots() ([]string, error) {
+	n, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]unix.Statfs_t, n)
+	n, err = unix.Getfsstat(buf, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	for _, st := range buf[:n] {
+		if st.Flags&unix.MNT_LOCAL == 0 {
+			continue
+		}
+		switch unix.ByteSliceToString(st.Fstypename[:]) {
+		case "devfs", "autofs":
+			continue
+		}
+		roots = append(roots, unix.ByteSliceToString(st.Mntonname[:]))
+	}
+	return roots, nil
+}
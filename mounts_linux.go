@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// skippedMountTypes are pseudo, memory-backed and network filesystems that
+// --roots-from-mounts leaves out.
+var skippedMountTypes = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "tmpfs": true,
+	"cgroup": true, "cgroup2": true, "securityfs": true, "debugfs": true,
+	"tracefs": true, "pstore": true, "bpf": true, "mqueue": true, "hugetlbfs": true,
+	"configfs": true, "fusectl": true, "autofs": true, "binfmt_misc": true,
+	"rpc_pipefs": true, "nsfs": true, "efivarfs": true, "ramfs": true,
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true,
+	"ceph": true, "glusterfs": true, "afs": true, "9p": true, "davfs": true,
+	"fuse.sshfs": true, "fuse.rclone": true,
+}
+
+func mountRoots() ([]string, error) {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseProcMounts(f)
+}
+
+// parseProcMounts returns the mount points listed in /proc/mounts format,
+// skipping skippedMountTypes and duplicates.
+func parseProcMounts(r io.Reader) ([]string, error) {
+	var roots []string
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mountPoint, fsType := unescapeMount(fields[1]), fields[2]
+		if skippedMountTypes[fsType] || seen[mountPoint] {
+			continue
+		}
+		seen[mountPoint] = true
+		roots = append(roots, mountPoint)
+	}
+	return roots, sc.Err()
+}
+
+// unescapeMount decodes the octal escapes (\040 for space, etc.) the kernel
+// uses for whitespace and backslashes in /proc/mounts.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
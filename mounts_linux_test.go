@@ -0,0 +1,31 @@
+//go:build linux
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const procMountsFixture = `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/nvme0n1p2 / ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,size=3256544k,mode=755 0 0
+/dev/nvme0n1p1 /boot/efi vfat rw,relatime 0 0
+/dev/sdb1 /mnt/backup\040disk xfs rw,relatime 0 0
+server:/export /mnt/nfs nfs4 rw,relatime,vers=4.2 0 0
+//nas/share /mnt/smb cifs rw,relatime 0 0
+/dev/nvme0n1p2 / ext4 rw,relatime 0 0
+`
+
+func TestParseProcMounts(t *testing.T) {
+	got, err := parseProcMounts(strings.NewReader(procMountsFixture))
+	if err != nil {
+		t.Fatalf("parseProcMounts: %v", err)
+	}
+	want := []string{"/", "/boot/efi", "/mnt/backup disk"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
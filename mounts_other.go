@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func mountRoots() ([]string, error) {
+	return nil, errors.New("not supported on this platform")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsRetryDelay is the wait before the first --nats-retry attempt; it
+// doubles after every failed attempt.
+const natsRetryDelay = 500 * time.Millisecond
+
+// natsConn is the part of a NATS connection the sink uses. Drain must not
+// return until every published message has been flushed.
+type natsConn interface {
+	Publish(subject string, data []byte) error
+	Drain() error
+}
+
+// natsSink is a matchSink publishing every match to a NATS subject
+// (--nats-url, --nats-subject) as a streamEvent JSON message.
+type natsSink struct {
+	conn    natsConn
+	subject string
+	now     func() time.Time
+}
+
+func (s *natsSink) Add(m matchResult) error {
+	body, err := json.Marshal(streamEvent{
+		Path:      formatPath(m.Path),
+		Size:      m.Size,
+		Timestamp: s.now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.conn.Publish(s.subject, body); err != nil {
+		return fmt.Errorf("--nats-url: %w", err)
+	}
+	return nil
+}
+
+// Close drains the connection so messages still buffered are delivered.
+func (s *natsSink) Close() error { return s.conn.Drain() }
+
+// drainingConn wraps *nats.Conn so Drain waits for the connection to
+// close; nats.Conn.Drain only starts draining.
+type drainingConn struct {
+	*nats.Conn
+	closed chan struct{}
+}
+
+func (c *drainingConn) Drain() error {
+	if err := c.Conn.Drain(); err != nil {
+		return err
+	}
+	<-c.closed
+	return c.Conn.LastError()
+}
+
+// dialNATS connects to url, authenticating with the credentials file
+// creds when it is set. A failed connection is retried up to retries
+// times with exponential back-off, each failure logged as a warning.
+func dialNATS(url, creds string, retries int, logger *slog.Logger) (natsConn, error) {
+	closed := make(chan struct{})
+	opts := []nats.Option{
+		nats.Name("sqlite-scanner"),
+		nats.ClosedHandler(func(*nats.Conn) { close(closed) }),
+	}
+	if creds != "" {
+		opts = append(opts, nats.UserCredentials(creds))
+	}
+	var nc *nats.Conn
+	err := withBackoff(retries, natsRetryDelay, func() error {
+		var err error
+		nc, err = nats.Connect(url, opts...)
+		return err
+	}, func(attempt int, err error) {
+		logger.Warn("could not connect to NATS; retrying", "url", url, "attempt", attempt, "attempts", retries+1, "error", err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &drainingConn{Conn: nc, closed: closed}, nil
+}
+
+// withBackoff calls try until it succeeds or has failed retries+1 times,
+// sleeping delay after the first failure and twice as long after each
+// later one. onRetry is called with every failure that will be retried.
+func withBackoff(retries int, delay time.Duration, try func() error, onRetry func(attempt int, err error)) error {
+	for attempt := 1; ; attempt++ {
+		err := try()
+		if err == nil || attempt > retries {
+			return err
+		}
+		onRetry(attempt, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordingNATS collects the messages a natsSink publishes.
+type recordingNATS struct {
+	subjects []string
+	msgs     [][]byte
+	drained  bool
+}
+
+func (r *recordingNATS) Publish(subject string, data []byte) error {
+	r.subjects = append(r.subjects, subject)
+	r.msgs = append(r.msgs, data)
+	return nil
+}
+
+func (r *recordingNATS) Drain() error {
+	r.drained = true
+	return nil
+}
+
+func TestNATSSink(t *testing.T) {
+	fixed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := &recordingNATS{}
+	sink := &natsSink{conn: rec, subject: "sqlite.findings", now: func() time.Time { return fixed }}
+	if err := sink.Add(matchResult{Path: "/data/app.db", Size: 8192}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil || !rec.drained {
+		t.Fatalf("expected the connection to be drained, got %v", err)
+	}
+	if len(rec.msgs) != 1 || rec.subjects[0] != "sqlite.findings" {
+		t.Fatalf("unexpected publishes to %q", rec.subjects)
+	}
+	var ev streamEvent
+	if err := json.Unmarshal(rec.msgs[0], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Path != "/data/app.db" || ev.Size != 8192 || ev.Timestamp != "2024-05-01T12:00:00Z" {
+		t.Fatalf("unexpected message %+v", ev)
+	}
+}
+
+func TestWithBackoff(t *testing.T) {
+	fail := errors.New("connection refused")
+	calls := 0
+	err := withBackoff(3, time.Millisecond, func() error {
+		calls++
+		return fail
+	}, func(attempt int, err error) {
+		if attempt != calls || err != fail {
+			t.Fatalf("unexpected retry %d after %d calls: %v", attempt, calls, err)
+		}
+	})
+	if err != fail || calls != 4 {
+		t.Fatalf("expected 4 failed attempts, got %d: %v", calls, err)
+	}
+
+	calls = 0
+	err = withBackoff(3, time.Millisecond, func() error {
+		if calls++; calls < 2 {
+			return fail
+		}
+		return nil
+	}, func(int, error) {})
+	if err != nil || calls != 2 {
+		t.Fatalf("expected success on the second attempt, got %d: %v", calls, err)
+	}
+}
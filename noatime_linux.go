@@ -0,0 +1,9 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// oNoATime asks the kernel not to update the file's access time. Only the
+// file's owner (or CAP_FOWNER) may use it; openForCheck falls back otherwise.
+const oNoATime = syscall.O_NOATIME
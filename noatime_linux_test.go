@@ -0,0 +1,66 @@
+//go:build linux
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOpenForCheckRequestsNoATime(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "a.db")
+	if err := os.WriteFile(dbPath, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	var flags []int
+	orig := openFile
+	t.Cleanup(func() { openFile = orig })
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		flags = append(flags, flag)
+		return orig(name, flag, perm)
+	}
+
+	if _, ok, err := checkSQLiteMagic(dbPath, checkOptions{NoATime: true}); err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	if len(flags) != 1 || flags[0]&syscall.O_NOATIME == 0 {
+		t.Fatalf("expected a single O_NOATIME open, got flags %v", flags)
+	}
+
+	flags = nil
+	if _, _, err := checkSQLiteMagic(dbPath, checkOptions{}); err != nil {
+		t.Fatalf("checkSQLiteMagic: %v", err)
+	}
+	if len(flags) != 1 || flags[0]&syscall.O_NOATIME != 0 {
+		t.Fatalf("expected a plain open by default, got flags %v", flags)
+	}
+}
+
+func TestOpenForCheckFallsBackWhenNoATimeRefused(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "a.db")
+	if err := os.WriteFile(dbPath, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	var flags []int
+	orig := openFile
+	t.Cleanup(func() { openFile = orig })
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		flags = append(flags, flag)
+		if flag&syscall.O_NOATIME != 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+		}
+		return orig(name, flag, perm)
+	}
+
+	if _, ok, err := checkSQLiteMagic(dbPath, checkOptions{NoATime: true}); err != nil || !ok {
+		t.Fatalf("expected fallback open to match, got ok=%v err=%v", ok, err)
+	}
+	if len(flags) != 2 || flags[1]&syscall.O_NOATIME != 0 {
+		t.Fatalf("expected O_NOATIME attempt then plain open, got flags %v", flags)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package main
+
+// oNoATime is zero where O_NOATIME does not exist, making
+// --touch-access-time=false a no-op.
+const oNoATime = 0
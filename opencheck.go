@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// openCheck opens path read-only with the SQLite driver and reads the
+// schema version, which fails for files whose magic bytes are fine but whose
+// contents are not a usable database. The failure is reported as "locked"
+// when another connection holds a lock and "corrupt" otherwise.
+func openCheck(path string) (ok bool, failure string) {
+	u := url.URL{Scheme: "file", OmitHost: true, Path: path, RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite", u.String())
+	if err != nil {
+		return false, "corrupt"
+	}
+	defer db.Close()
+
+	var v int64
+	err = db.QueryRow("PRAGMA schema_version").Scan(&v)
+	if err == nil {
+		return true, ""
+	}
+	var serr *sqlite.Error
+	if errors.As(err, &serr) {
+		switch serr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return false, "locked"
+		}
+	}
+	return false, "corrupt"
+}
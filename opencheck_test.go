@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simonw/sqlite-scanner/internal/testutil"
+)
+
+func TestOpenCheckMagicOnlyFileIsCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "magic-only.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{OpenCheck: true})
+	if err != nil || !ok {
+		t.Fatalf("expected magic match, got ok=%v err=%v", ok, err)
+	}
+	if res.Openable || res.OpenFailure != "corrupt" {
+		t.Fatalf("expected corrupt, got openable=%v failure=%q", res.Openable, res.OpenFailure)
+	}
+	opts := outputOptions{ShowOpenable: true}
+	if got := formatPlainMatch(res, opts); got != path+" [corrupt]" {
+		t.Fatalf("unexpected plain output %q", got)
+	}
+	opts.JSONL = true
+	if got := formatJSONLine(res, opts); got != `{"path":`+marshalJSON(path, "", "")+`,"openable":false}` {
+		t.Fatalf("unexpected JSONL %s", got)
+	}
+}
+
+func TestOpenCheckRealDatabase(t *testing.T) {
+	path := testutil.CreateSQLiteDB(t, t.TempDir(), "real.db", []string{"t"})
+
+	res, _, err := checkSQLiteMagic(path, checkOptions{OpenCheck: true})
+	if err != nil || !res.Openable {
+		t.Fatalf("expected openable database, got %+v (err %v)", res, err)
+	}
+
+	// Hold an exclusive lock from another connection.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	conn, err := db.Conn(t.Context())
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	if _, err := conn.ExecContext(t.Context(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("begin exclusive: %v", err)
+	}
+	res, _, err = checkSQLiteMagic(path, checkOptions{OpenCheck: true})
+	if err != nil || res.Openable || res.OpenFailure != "locked" {
+		t.Fatalf("expected locked, got openable=%v failure=%q err=%v", res.Openable, res.OpenFailure, err)
+	}
+	conn.ExecContext(t.Context(), "ROLLBACK")
+	conn.Close()
+	db.Close()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans sqlite-scanner creates.
+const tracerName = "github.com/simonw/sqlite-scanner"
+
+// otelShutdownTimeout bounds how long exporting the last spans may delay
+// exit when the collector is slow or unreachable.
+const otelShutdownTimeout = 5 * time.Second
+
+// newTracerProvider starts exporting spans over OTLP/gRPC to endpoint, a
+// URL such as https://otel.example.com:4317; http:// endpoints are sent
+// without TLS. Spans are batched, so the provider must be shut down to
+// flush them.
+func newTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", endpoint)
+	}
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewSchemaless(semconv.ServiceName("sqlite-scanner"), semconv.ServiceVersion(version))
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// startScanSpan starts the sqlite-scanner.scan root span. The returned
+// context carries it, for checkOptions.Trace.
+func startScanSpan(ctx context.Context, tp trace.TracerProvider, roots []string, workers int) (context.Context, trace.Span) {
+	return tp.Tracer(tracerName).Start(ctx, "sqlite-scanner.scan", trace.WithAttributes(
+		attribute.StringSlice("scan.roots", roots),
+		attribute.Int("scan.workers", workers),
+	))
+}
+
+// endScanSpan records the number of matches on the scan span and ends it.
+func endScanSpan(span trace.Span, results int64) {
+	span.SetAttributes(attribute.Int64("scan.result_count", results))
+	span.End()
+}
+
+// shutdownTracing exports the spans still batched, giving up after
+// otelShutdownTimeout.
+func shutdownTracing(tp *sdktrace.TracerProvider, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+	defer cancel()
+	if err := tp.Shutdown(ctx); err != nil {
+		logger.Warn("could not export traces", "error", err)
+	}
+}
+
+// startCheckSpan starts a sqlite-scanner.check_file span for path as a
+// child of the span in opts.Trace. Without --otel-endpoint it returns a
+// no-op span.
+func startCheckSpan(opts checkOptions, path string) trace.Span {
+	if opts.Trace == nil {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := trace.SpanFromContext(opts.Trace).TracerProvider().Tracer(tracerName).Start(opts.Trace,
+		"sqlite-scanner.check_file", trace.WithAttributes(attribute.String("file.path", path)))
+	return span
+}
+
+// endCheckSpan records the outcome of a check on span and ends it.
+func endCheckSpan(span trace.Span, matched bool, err error) {
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(attribute.Bool("sqlite.match", matched))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
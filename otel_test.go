@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCheckSpans(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "app.db")
+	other := filepath.Join(dir, "notes.txt")
+	os.WriteFile(db, append(append([]byte{}, sqliteMagic...), "payload"...), 0o600)
+	os.WriteFile(other, []byte("not sqlite"), 0o600)
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	ctx, scan := startScanSpan(context.Background(), tp, []string{dir}, 2)
+	for _, p := range []string{db, other} {
+		if _, _, err := checkSQLiteMagic(p, checkOptions{Trace: ctx}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	endScanSpan(scan, 1)
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	root := spans[2]
+	if root.Name() != "sqlite-scanner.scan" || !hasAttr(root.Attributes(), attribute.Int64("scan.result_count", 1)) ||
+		!hasAttr(root.Attributes(), attribute.Int("scan.workers", 2)) {
+		t.Fatalf("unexpected root span %s %v", root.Name(), root.Attributes())
+	}
+	for i, want := range []struct {
+		path  string
+		match bool
+	}{{db, true}, {other, false}} {
+		s := spans[i]
+		if s.Name() != "sqlite-scanner.check_file" || s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Fatalf("expected a check_file child of the scan span, got %s", s.Name())
+		}
+		if !hasAttr(s.Attributes(), attribute.String("file.path", want.path)) || !hasAttr(s.Attributes(), attribute.Bool("sqlite.match", want.match)) {
+			t.Fatalf("unexpected attributes %v", s.Attributes())
+		}
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNewTracerProviderRejectsBadEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"otel.example.com:4317", "grpc://otel.example.com:4317", "https://"} {
+		if _, err := newTracerProvider(context.Background(), endpoint); err == nil {
+			t.Fatalf("expected %q to be rejected", endpoint)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile writes to a temporary file next to the destination and only
+// renames it into place on Commit, so readers never see a half-written
+// result file.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp uses 0600; match what os.Create would normally produce.
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// Commit closes the temporary file and renames it over the destination.
+func (a *atomicFile) Commit() error {
+	if err := a.File.Close(); err != nil {
+		os.Remove(a.File.Name())
+		return err
+	}
+	return os.Rename(a.File.Name(), a.path)
+}
+
+// Abort closes and removes the temporary file, leaving the destination
+// untouched.
+func (a *atomicFile) Abort() {
+	a.File.Close()
+	os.Remove(a.File.Name())
+}
+
+// outputChain is the stack of writers the printer writes to: the optional
+// --output file, then --encrypt-output, --compress-output and a buffer on
+// top. Its writer is W.
+type outputChain struct {
+	W          io.Writer
+	file       *atomicFile
+	buf        *bufio.Writer
+	compressor io.WriteCloser
+	encryptor  *encryptWriter
+	closed     bool
+}
+
+// newOutputChain opens path (stdout when empty) and stacks the encryption
+// and compression layers on it. Compression goes inside encryption:
+// ciphertext does not compress.
+func newOutputChain(path string, key []byte, compress string) (*outputChain, error) {
+	c := &outputChain{W: os.Stdout}
+	if path != "" {
+		file, err := createAtomic(path)
+		if err != nil {
+			return nil, fmt.Errorf("--output: %w", err)
+		}
+		c.file, c.W = file, file
+	}
+	if key != nil {
+		encryptor, err := newEncryptWriter(c.W, key)
+		if err != nil {
+			c.close(false)
+			return nil, fmt.Errorf("--encrypt-output: %w", err)
+		}
+		c.encryptor, c.W = encryptor, encryptor
+	}
+	if compress != "" {
+		// Without --output, --gzip-output compresses stdout.
+		compressor, err := newCompressor(c.W, compress)
+		if err != nil {
+			c.close(false)
+			return nil, fmt.Errorf("--compress-output: %w", err)
+		}
+		c.compressor, c.W = compressor, compressor
+	}
+	if c.file != nil {
+		c.buf = bufio.NewWriter(c.W)
+		c.W = c.buf
+	}
+	return c, nil
+}
+
+// close flushes the buffer and finishes the compressed and encrypted
+// streams, then renames the --output file into place when commit is set
+// or removes it otherwise. Only the first call has any effect, so main can
+// defer it and still close early; it runs on interrupted scans too, which
+// leaves a valid file holding the matches printed before the interrupt.
+func (c *outputChain) close(commit bool) error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	var err error
+	if c.buf != nil {
+		err = c.buf.Flush()
+	}
+	if c.compressor != nil && err == nil {
+		// Finish the compressed stream before the rename makes the
+		// file visible.
+		err = c.compressor.Close()
+	}
+	if c.encryptor != nil && err == nil {
+		err = c.encryptor.Close()
+	}
+	if c.file == nil {
+		return err
+	}
+	if err != nil || !commit {
+		c.file.Abort()
+		return err
+	}
+	return c.file.Commit()
+}
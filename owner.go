@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// lookupOwner resolves the --owner value, a user name or numeric uid, to a
+// uid.
+func lookupOwner(s string) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil && id >= 0 {
+		return id, nil
+	}
+	u, err := user.Lookup(s)
+	if err != nil {
+		return 0, err
+	}
+	return parseID(u.Uid)
+}
+
+// lookupGroup resolves the --group value, a group name or numeric gid, to
+// a gid.
+func lookupGroup(s string) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil && id >= 0 {
+		return id, nil
+	}
+	g, err := user.LookupGroup(s)
+	if err != nil {
+		return 0, err
+	}
+	return parseID(g.Gid)
+}
+
+// parseID parses a uid or gid from os/user, which are strings because
+// Windows uses SIDs.
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a numeric id", s)
+	}
+	return id, nil
+}
+
+// ownerFilter keeps files owned by uid and group gid; -1 matches any.
+// Matches whose owner is unknown, such as remote objects, are dropped.
+func ownerFilter(uid, gid int) matchFilter {
+	return func(m matchResult) bool {
+		if uid >= 0 && m.UID != uid {
+			return false
+		}
+		if gid >= 0 && m.GID != gid {
+			return false
+		}
+		return true
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// Files have no uid or gid here, so --owner and --group are ignored.
+const ownerSupported = false
+
+func fileOwner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return -1, -1, false
+}
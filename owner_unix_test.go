@@ -0,0 +1,51 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestOwnerFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mine.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	res, ok, err := checkSQLiteMagic(path, checkOptions{Owner: true})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	if res.UID != uid || res.GID != gid {
+		t.Fatalf("expected owner %d:%d, got %d:%d", uid, gid, res.UID, res.GID)
+	}
+
+	owner, err := lookupOwner(strconv.Itoa(uid))
+	if err != nil || owner != uid {
+		t.Fatalf("lookupOwner(%d) = %d, %v", uid, owner, err)
+	}
+	if !ownerFilter(owner, -1)(res) || !ownerFilter(-1, gid)(res) || !ownerFilter(owner, gid)(res) {
+		t.Fatalf("expected the file to pass a filter on its own owner")
+	}
+	if ownerFilter(uid+1, -1)(res) || ownerFilter(-1, gid+1)(res) {
+		t.Fatalf("expected the file to fail a filter on another owner")
+	}
+	if ownerFilter(uid, -1)(matchResult{UID: -1, GID: -1}) {
+		t.Fatalf("expected a match of unknown owner to be dropped")
+	}
+
+	// Without --owner, or with --no-stat, the owner is unknown rather
+	// than root.
+	for _, opts := range []checkOptions{{}, {SkipStat: true}} {
+		res, ok, err := checkSQLiteMagic(path, opts)
+		if err != nil || !ok {
+			t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+		}
+		if res.UID != -1 || res.GID != -1 {
+			t.Fatalf("%+v: expected owner -1:-1, got %d:%d", opts, res.UID, res.GID)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRecord is the stable Parquet schema for --parquet output. Columns
+// are only ever appended so existing readers keep working.
+type parquetRecord struct {
+	Path string `parquet:"path"`
+	Size int64  `parquet:"size"`
+}
+
+// parquetRowGroupSize is the number of rows written per row group, so
+// readers can skip through large result sets without one huge group.
+const parquetRowGroupSize = 1000
+
+// parquetColumn is an optional column appended after path and size when
+// the output flag that shows the field is set.
+type parquetColumn struct {
+	name  string
+	field string
+	typ   reflect.Type
+	show  func(outputOptions) bool
+}
+
+var parquetColumns = []parquetColumn{
+	{"label", "Label", reflect.TypeOf(""), func(o outputOptions) bool { return o.ShowLabel }},
+	{"kind", "Kind", reflect.TypeOf(""), func(o outputOptions) bool { return o.ShowKind }},
+	{"schema_format", "SchemaFormat", reflect.TypeOf(int32(0)), func(o outputOptions) bool { return o.ShowSchemaFormat }},
+	{"freelist_pages", "FreelistPages", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowFreelist }},
+	{"autovacuum", "AutoVacuum", reflect.TypeOf(false), func(o outputOptions) bool { return o.ShowAutoVacuum }},
+	{"incremental_vacuum", "IncrementalVacuum", reflect.TypeOf(false), func(o outputOptions) bool { return o.ShowAutoVacuum }},
+	{"largest_root_page", "LargestRootPage", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowAutoVacuum }},
+	{"tables", "Tables", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowTables }},
+	{"journal_mode", "JournalMode", reflect.TypeOf(""), func(o outputOptions) bool { return o.ShowJournalMode }},
+	{"sha256", "SHA256", reflect.TypeOf(""), func(o outputOptions) bool { return o.ShowHash }},
+	{"blake3", "Blake3", reflect.TypeOf(""), func(o outputOptions) bool { return o.ShowBlake3 }},
+	{"openable", "Openable", reflect.TypeOf(false), func(o outputOptions) bool { return o.ShowOpenable }},
+	{"locked", "Locked", reflect.TypeOf(false), func(o outputOptions) bool { return o.ShowLocked }},
+	{"shared_locks", "SharedLockCount", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowSharedLocks }},
+	{"page_count", "PageCount", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowPageCount }},
+	{"sqlite_version", "SQLiteVersion", reflect.TypeOf(""), func(o outputOptions) bool { return o.ShowVersion }},
+	{"user_version", "UserVersion", reflect.TypeOf(int32(0)), func(o outputOptions) bool { return o.ShowVersion }},
+	{"application_id", "ApplicationID", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowVersion }},
+	{"mode", "Mode", reflect.TypeOf(int64(0)), func(o outputOptions) bool { return o.ShowMode }},
+	{"sqlite_version_stale", "VersionStale", reflect.TypeOf(false), func(o outputOptions) bool { return o.ShowVersion }},
+}
+
+// parquetRowType builds the row struct for opts: parquetRecord's columns
+// followed by the optional columns opts shows, in parquetColumns order.
+func parquetRowType(opts outputOptions) (reflect.Type, []parquetColumn) {
+	base := reflect.TypeOf(parquetRecord{})
+	fields := []reflect.StructField{base.Field(0), base.Field(1)}
+	var cols []parquetColumn
+	for _, c := range parquetColumns {
+		if !c.show(opts) {
+			continue
+		}
+		cols = append(cols, c)
+		fields = append(fields, reflect.StructField{
+			Name: c.field,
+			Type: c.typ,
+			Tag:  reflect.StructTag(`parquet:"` + c.name + `"`),
+		})
+	}
+	return reflect.StructOf(fields), cols
+}
+
+// writeParquet drains matches into a Parquet file written to w, with the
+// columns selected by opts. Parquet needs a footer describing every row
+// group, so nothing is usable until the channel is closed and the writer
+// is flushed.
+func writeParquet(w io.Writer, matches <-chan matchResult, opts outputOptions) error {
+	rowType, cols := parquetRowType(opts)
+	pw := parquet.NewWriter(w, parquet.SchemaOf(reflect.New(rowType).Interface()))
+	var writeErr error
+	rows := 0
+	for m := range matches {
+		if writeErr != nil {
+			continue
+		}
+		row := reflect.New(rowType).Elem()
+		row.Field(0).SetString(opts.displayPath(m.Path))
+		row.Field(1).SetInt(m.Size)
+		src := reflect.ValueOf(m)
+		for i, c := range cols {
+			row.Field(2 + i).Set(src.FieldByName(c.field).Convert(c.typ))
+		}
+		if err := pw.Write(row.Addr().Interface()); err != nil {
+			writeErr = err
+			continue
+		}
+		if rows++; rows%parquetRowGroupSize == 0 {
+			writeErr = pw.Flush()
+		}
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return pw.Close()
+}
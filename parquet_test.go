@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestWriteParquetRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	matches := make(chan matchResult, 2)
+	matches <- matchResult{Path: filepath.Join(dir, "a.db"), Size: 4096}
+	matches <- matchResult{Path: filepath.Join(dir, "b.db"), Size: 8192}
+	close(matches)
+
+	outPath := filepath.Join(dir, "scan.parquet")
+	f, err := createAtomic(outPath)
+	if err != nil {
+		t.Fatalf("createAtomic: %v", err)
+	}
+	if err := writeParquet(f, matches, outputOptions{}); err != nil {
+		f.Abort()
+		t.Fatalf("writeParquet: %v", err)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	rows, err := parquet.ReadFile[parquetRecord](outPath)
+	if err != nil {
+		t.Fatalf("read parquet: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	want := []parquetRecord{
+		{Path: filepath.Join(dir, "a.db"), Size: 4096},
+		{Path: filepath.Join(dir, "b.db"), Size: 8192},
+	}
+	for i, row := range rows {
+		if row != want[i] {
+			t.Fatalf("row %d: expected %+v, got %+v", i, want[i], row)
+		}
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".scan.parquet.tmp-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("expected temp file to be renamed away, found %v", leftovers)
+	}
+	if _, err := os.Stat(outPath); err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+}
+
+func TestWriteParquetOptionalColumns(t *testing.T) {
+	const n = 2500
+	matches := make(chan matchResult, n)
+	for i := 0; i < n; i++ {
+		matches <- matchResult{
+			Path:         "/data/" + strconv.Itoa(i) + ".db",
+			Size:         int64(i),
+			Label:        "prod",
+			SchemaFormat: 4,
+			SHA256:       "abc",
+			Locked:       i%2 == 0,
+		}
+	}
+	close(matches)
+
+	outPath := filepath.Join(t.TempDir(), "scan.parquet")
+	f, err := os.Create(outPath)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	opts := outputOptions{ShowLabel: true, ShowSchemaFormat: true, ShowHash: true}
+	if err := writeParquet(f, matches, opts); err != nil {
+		t.Fatalf("writeParquet: %v", err)
+	}
+	f.Close()
+
+	type row struct {
+		Path         string `parquet:"path"`
+		Size         int64  `parquet:"size"`
+		Label        string `parquet:"label"`
+		SchemaFormat int32  `parquet:"schema_format"`
+		SHA256       string `parquet:"sha256"`
+	}
+	rows, err := parquet.ReadFile[row](outPath)
+	if err != nil {
+		t.Fatalf("read parquet: %v", err)
+	}
+	if len(rows) != n {
+		t.Fatalf("expected %d rows, got %d", n, len(rows))
+	}
+	if want := (row{"/data/7.db", 7, "prod", 4, "abc"}); rows[7] != want {
+		t.Fatalf("expected %+v, got %+v", want, rows[7])
+	}
+
+	pf, err := os.Open(outPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer pf.Close()
+	info, _ := pf.Stat()
+	file, err := parquet.OpenFile(pf, info.Size())
+	if err != nil {
+		t.Fatalf("open parquet: %v", err)
+	}
+	var cols []string
+	for _, c := range file.Schema().Fields() {
+		cols = append(cols, c.Name())
+	}
+	if want := []string{"path", "size", "label", "schema_format", "sha256"}; !slices.Equal(cols, want) {
+		t.Fatalf("expected columns %v, got %v", want, cols)
+	}
+	if got := len(file.RowGroups()); got != 3 {
+		t.Fatalf("expected 3 row groups of up to %d rows, got %d", parquetRowGroupSize, got)
+	}
+}
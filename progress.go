@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// countCandidates walks roots the way scanPaths does and counts the regular
+// files it would examine, using only directory entries (no opens or stats
+// of files). Unreadable directories are skipped silently.
+func countCandidates(ctx context.Context, roots []string, oneFileSystem bool) (int64, error) {
+	var n int64
+	for _, r := range roots {
+		var rootDev uint64
+		checkDev := false
+		if oneFileSystem {
+			if info, err := os.Stat(r); err == nil {
+				rootDev, checkDev = deviceID(info)
+			}
+		}
+		err := filepath.WalkDir(r, func(path string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if checkDev && path != r {
+					if info, err := d.Info(); err == nil {
+						if dev, ok := deviceID(info); ok && dev != rootDev {
+							return filepath.SkipDir
+						}
+					}
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				n++
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// formatProgress describes how far a scan of total files has got after
+// examining done of them in elapsed time, with an ETA extrapolated from the
+// rate so far.
+func formatProgress(done, total int64, elapsed time.Duration) string {
+	if total <= 0 {
+		return fmt.Sprintf("progress: %d files examined", done)
+	}
+	if done > total {
+		// Files created since the count was taken.
+		done = total
+	}
+	pct := float64(done) * 100 / float64(total)
+	eta := "unknown"
+	if done > 0 {
+		remaining := time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+		eta = remaining.Round(time.Second).String()
+	}
+	return fmt.Sprintf("progress: %.1f%% (%d/%d files), ETA %s", pct, done, total, eta)
+}
+
+// reportProgress writes a progress line to w every interval until ctx is
+// done, reading the examined-file count from stats.
+func reportProgress(ctx context.Context, w io.Writer, total int64, stats *scanStats, interval time.Duration) {
+	start := time.Now()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			fmt.Fprintln(w, formatProgress(stats.FilesExamined.Load(), total, time.Since(start)))
+		}
+	}
+}
+
+// progressEvent is one line of --progress-json.
+type progressEvent struct {
+	Examined  int64 `json:"examined"`
+	Matched   int64 `json:"matched"`
+	ElapsedMS int64 `json:"elapsed_ms"`
+}
+
+// reportProgressJSON writes a progressEvent line to w every interval until
+// stop is closed, then a final one with the finished totals, and closes
+// done. Examined files are read from stats and matches from matched.
+func reportProgressJSON(stop <-chan struct{}, done chan<- struct{}, w io.Writer, stats *scanStats, matched *atomic.Int64, interval time.Duration) {
+	defer close(done)
+	start := time.Now()
+	emit := func() {
+		line, _ := json.Marshal(progressEvent{
+			Examined:  stats.FilesExamined.Load(),
+			Matched:   matched.Load(),
+			ElapsedMS: time.Since(start).Milliseconds(),
+		})
+		fmt.Fprintf(w, "%s\n", line)
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			emit()
+			return
+		case <-t.C:
+			emit()
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCountCandidates(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.db", "b.txt", filepath.Join("sub", "c"), filepath.Join("sub", "deeper", "d")} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a.db"), filepath.Join(root, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	n, err := countCandidates(context.Background(), []string{root}, false)
+	if err != nil {
+		t.Fatalf("countCandidates: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 regular files, got %d", n)
+	}
+
+	stats := &scanStats{}
+	matches := make(chan matchResult, 4)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Check: checkOptions{Stats: stats}}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	if got := stats.FilesExamined.Load(); got != n {
+		t.Fatalf("expected the scan to examine the %d counted files, got %d", n, got)
+	}
+}
+
+func TestReportProgressEmitsPercentage(t *testing.T) {
+	stats := &scanStats{}
+	stats.FilesExamined.Store(25)
+	var buf bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reportProgress(ctx, &buf, 100, stats, 5*time.Millisecond)
+	if !strings.Contains(buf.String(), "25.0% (25/100 files)") {
+		t.Fatalf("expected a percentage line, got %q", buf.String())
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	if got := formatProgress(50, 200, 10*time.Second); got != "progress: 25.0% (50/200 files), ETA 30s" {
+		t.Fatalf("unexpected progress line %q", got)
+	}
+	if got := formatProgress(0, 200, time.Second); !strings.HasSuffix(got, "ETA unknown") {
+		t.Fatalf("expected unknown ETA before any progress, got %q", got)
+	}
+}
+
+func TestReportProgressJSON(t *testing.T) {
+	stats := &scanStats{}
+	var matched atomic.Int64
+	stats.FilesExamined.Store(10)
+	matched.Store(2)
+	stop, done := make(chan struct{}), make(chan struct{})
+	out := captureStderr(t, func() {
+		go reportProgressJSON(stop, done, os.Stderr, stats, &matched, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		stats.FilesExamined.Store(25)
+		matched.Store(3)
+		close(stop)
+		<-done
+	})
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected periodic and final events, got %q", out)
+	}
+	var ev progressEvent
+	for _, line := range lines {
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid progress event %q: %v", line, err)
+		}
+	}
+	if ev.Examined != 25 || ev.Matched != 3 || ev.ElapsedMS < 20 {
+		t.Fatalf("expected a final event with the totals, got %+v", ev)
+	}
+}
+
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	oldStderr := os.Stderr
+	os.Stderr = w
+	defer func() {
+		os.Stderr = oldStderr
+	}()
+
+	fn()
+	w.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	r.Close()
+	return buf.String()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisBatchSize is how many matches are queued before they are pushed in
+// one pipeline.
+const redisBatchSize = 100
+
+// redisFlushInterval is how old a partial batch may get before the next
+// match pushes it without waiting for it to fill up, so slow scans still
+// push results as they go. Nothing is pushed between matches; whatever is
+// queued when the scan ends goes out in Close.
+const redisFlushInterval = time.Second
+
+// redisList is the part of Redis the sink uses: appending to a list and
+// setting its expiry.
+type redisList interface {
+	push(ctx context.Context, key string, values []string) error
+	expire(ctx context.Context, key string, ttl time.Duration) error
+	Close() error
+}
+
+// redisSink is a matchSink appending every match to a Redis list (--redis,
+// --redis-key) as a streamEvent JSON string. Matches are queued and pushed
+// in batches of redisBatchSize; Close pushes the rest, applies --redis-ttl
+// and closes the client, and fails if any batch was dropped.
+type redisSink struct {
+	ctx      context.Context
+	list     redisList
+	key      string
+	ttl      time.Duration
+	now      func() time.Time
+	queued   []string
+	queuedAt time.Time
+	// dropped counts the matches in batches that failed to push.
+	dropped int
+}
+
+// newRedisSink connects to the redis:// or rediss:// URL rawURL.
+func newRedisSink(ctx context.Context, rawURL, key string, ttl time.Duration) (*redisSink, error) {
+	if key == "" {
+		return nil, fmt.Errorf("--redis requires --redis-key")
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("--redis-ttl cannot be negative")
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("--redis: %w", err)
+	}
+	return &redisSink{
+		ctx:  ctx,
+		list: goRedisList{redis.NewClient(opts)},
+		key:  key,
+		ttl:  ttl,
+		now:  time.Now,
+	}, nil
+}
+
+func (s *redisSink) Add(m matchResult) error {
+	now := s.now()
+	body, err := json.Marshal(streamEvent{
+		Path:      formatPath(m.Path),
+		Size:      m.Size,
+		Timestamp: now.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if len(s.queued) == 0 {
+		s.queuedAt = now
+	}
+	s.queued = append(s.queued, string(body))
+	if len(s.queued) < redisBatchSize && now.Sub(s.queuedAt) < redisFlushInterval {
+		return nil
+	}
+	return s.flush(s.ctx)
+}
+
+// flush pushes the queued matches. A failed batch is dropped, so one
+// outage costs at most a batch and not every later match too.
+func (s *redisSink) flush(ctx context.Context) error {
+	if len(s.queued) == 0 {
+		return nil
+	}
+	values := s.queued
+	s.queued = nil
+	if err := s.list.push(ctx, s.key, values); err != nil {
+		s.dropped += len(values)
+		return fmt.Errorf("--redis: pushing %d matches: %w", len(values), err)
+	}
+	return nil
+}
+
+// Close pushes what is still queued even when the scan was interrupted,
+// so it does not use the scan's context.
+func (s *redisSink) Close() error {
+	ctx := context.WithoutCancel(s.ctx)
+	err := s.flush(ctx)
+	if err == nil && s.ttl > 0 {
+		if err = s.list.expire(ctx, s.key, s.ttl); err != nil {
+			err = fmt.Errorf("--redis-ttl: %w", err)
+		}
+	}
+	if cerr := s.list.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && s.dropped > 0 {
+		err = fmt.Errorf("--redis: %d matches could not be pushed", s.dropped)
+	}
+	return err
+}
+
+// goRedisList implements redisList with a go-redis client.
+type goRedisList struct {
+	client *redis.Client
+}
+
+// push sends one RPUSH per value in a single pipeline round trip.
+func (l goRedisList) push(ctx context.Context, key string, values []string) error {
+	_, err := l.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, v := range values {
+			pipe.RPush(ctx, key, v)
+		}
+		return nil
+	})
+	return err
+}
+
+func (l goRedisList) expire(ctx context.Context, key string, ttl time.Duration) error {
+	return l.client.Expire(ctx, key, ttl).Err()
+}
+
+func (l goRedisList) Close() error { return l.client.Close() }
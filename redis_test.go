@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingRedis collects the batches a redisSink pushes.
+type recordingRedis struct {
+	batches [][]string
+	ttl     time.Duration
+	closed  bool
+	// err, when set, fails every push.
+	err error
+}
+
+func (r *recordingRedis) push(_ context.Context, _ string, values []string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, values)
+	return nil
+}
+
+func (r *recordingRedis) expire(_ context.Context, _ string, ttl time.Duration) error {
+	r.ttl = ttl
+	return nil
+}
+
+func (r *recordingRedis) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestRedisSinkBatches(t *testing.T) {
+	fixed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := &recordingRedis{}
+	sink := &redisSink{ctx: context.Background(), list: rec, key: "sqlite:findings", ttl: time.Hour, now: func() time.Time { return fixed }}
+	for i := 0; i < redisBatchSize+5; i++ {
+		if err := sink.Add(matchResult{Path: "/data/app.db", Size: 8192}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rec.batches) != 1 || len(rec.batches[0]) != redisBatchSize {
+		t.Fatalf("expected one full batch before Close, got %d", len(rec.batches))
+	}
+	if err := sink.Close(); err != nil || !rec.closed {
+		t.Fatalf("expected the client to be closed, got %v", err)
+	}
+	if len(rec.batches) != 2 || len(rec.batches[1]) != 5 {
+		t.Fatalf("expected Close to push the last 5 matches, got %d batches", len(rec.batches))
+	}
+	if rec.ttl != time.Hour {
+		t.Fatalf("expected a one hour expiry, got %v", rec.ttl)
+	}
+	var ev streamEvent
+	if err := json.Unmarshal([]byte(rec.batches[0][0]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Path != "/data/app.db" || ev.Size != 8192 || ev.Timestamp != "2024-05-01T12:00:00Z" {
+		t.Fatalf("unexpected message %+v", ev)
+	}
+}
+
+func TestRedisSinkFlushesSlowScans(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := &recordingRedis{}
+	sink := &redisSink{ctx: context.Background(), list: rec, key: "sqlite:findings", now: func() time.Time { return now }}
+	sink.Add(matchResult{Path: "/data/a.db"})
+	now = now.Add(redisFlushInterval)
+	sink.Add(matchResult{Path: "/data/b.db"})
+	if len(rec.batches) != 1 || len(rec.batches[0]) != 2 {
+		t.Fatalf("expected both matches pushed once the interval passed, got %v", rec.batches)
+	}
+	sink.Close()
+	if len(rec.batches) != 1 || rec.ttl != 0 {
+		t.Fatalf("expected nothing left to push and no expiry, got %v, ttl %v", rec.batches, rec.ttl)
+	}
+}
+
+func TestRedisSinkFailsCloseAfterDroppedBatch(t *testing.T) {
+	rec := &recordingRedis{err: errors.New("connection refused")}
+	sink := &redisSink{ctx: context.Background(), list: rec, key: "k", now: time.Now}
+	var addErr error
+	for i := 0; i < redisBatchSize; i++ {
+		addErr = sink.Add(matchResult{Path: "/data/a.db"})
+	}
+	if addErr == nil {
+		t.Fatal("expected the failed batch to be reported")
+	}
+	rec.err = nil
+	if err := sink.Add(matchResult{Path: "/data/b.db"}); err != nil {
+		t.Fatal(err)
+	}
+	err := sink.Close()
+	if err == nil || !strings.Contains(err.Error(), "100 matches could not be pushed") {
+		t.Fatalf("expected Close to report the dropped batch, got %v", err)
+	}
+	if len(rec.batches) != 1 || len(rec.batches[0]) != 1 || !rec.closed {
+		t.Fatalf("expected the later match to still be pushed, got %v", rec.batches)
+	}
+}
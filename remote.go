@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// remoteObject is one object listed from a cloud bucket.
+type remoteObject struct {
+	// URI is reported as the match's path, e.g. s3://bucket/key.
+	URI     string
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// remoteSchemes are the URI schemes of remote matches, whose paths must not
+// be treated as local files.
+var remoteSchemes = []string{"s3://", "gs://", "sftp://"}
+
+func isRemotePath(path string) bool {
+	for _, s := range remoteSchemes {
+		if strings.HasPrefix(path, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// localOnlyFlags need a local file or directory tree and are rejected for
+// remote scans.
+var localOnlyFlags = []string{
+	"hash", "blake3", "hash-max-bytes", "skip-larger-than-ram", "unique-content", "open-check", "lock-check",
+	"read-only-check", "cache-file", "watch", "count-first", "scan-order",
+	"report-dirs-only", "one-file-system", "inotify-watch", "touch-access-time", "retry", "sparse", "mmap",
+	"tar", "extensions-report", "min-tables", "owner", "group",
+	"archive-depth", "concurrent-archives", "validate-utf8-paths", "skip-invalid-utf8-paths", "include-zero-size",
+}
+
+// firstSet returns the first of names that was set explicitly, or "".
+func firstSet(flags *pflag.FlagSet, names []string) string {
+	for _, name := range names {
+		if flags.Changed(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// scanObjects is the remote counterpart of scanPaths: list calls yield for
+// every object under the prefix, and opts.Workers goroutines fetch the
+// first bytes of each with readHead and report the ones that match. Like
+// scanPaths it closes matches and errs when done. Options that need the
+// whole file or a local file descriptor (--hash, --open-check, the lock
+// checks) do not apply and are rejected by main.
+func scanObjects(ctx context.Context, list func(ctx context.Context, yield func(remoteObject) error) error, readHead func(ctx context.Context, obj remoteObject, n int64) ([]byte, error), opts scanOptions, matches chan<- matchResult, errs chan<- error) error {
+	defer close(errs)
+	defer close(matches)
+
+	objects := make(chan remoteObject, opts.Workers*4)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range objects {
+				if ctx.Err() != nil {
+					continue
+				}
+				// Only the magic is required; ask for the full
+				// header so header fields can be reported too.
+				head, err := readHead(ctx, obj, min(obj.Size, sqliteHeaderSize))
+				if opts.Check.Stats != nil {
+					opts.Check.Stats.FilesChecked.Add(1)
+					opts.Check.Stats.BytesRead.Add(int64(len(head)))
+				}
+				if errors.Is(err, fs.ErrPermission) {
+					opts.Logger.Debug("skipping unreadable object", "path", obj.URI)
+					opts.Denied.add(obj.URI)
+					continue
+				}
+				if err != nil {
+					errs <- fmt.Errorf("%s: %w", obj.URI, err)
+					continue
+				}
+				res, ok := matchHeader(head, opts.Check.WALFiles)
+				if !ok {
+					opts.Logger.Debug("skipping non-SQLite object", "path", obj.URI)
+					continue
+				}
+				res.Path, res.Size, res.ModTime = obj.URI, obj.Size, obj.ModTime
+				res.estimatePageCount()
+				if !keepMatch(res, opts.Filters) {
+					continue
+				}
+				matches <- res
+			}
+		}()
+	}
+
+	err := list(ctx, func(obj remoteObject) error {
+		if opts.Check.Stats != nil {
+			opts.Check.Stats.FilesExamined.Add(1)
+		}
+		// Skip objects too small to hold any magic number.
+		minSize := int64(len(sqliteMagic))
+		if opts.Check.WALFiles {
+			minSize = 4
+		}
+		if obj.Size < minSize {
+			return nil
+		}
+		if !opts.Since.IsZero() && !obj.ModTime.After(opts.Since) {
+			return nil
+		}
+		select {
+		case objects <- obj:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(objects)
+	wg.Wait()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return ctx.Err()
+}
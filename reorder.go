@@ -0,0 +1,41 @@
+package main
+
+import "container/heap"
+
+// pathHeap is a min-heap of matches by path.
+type pathHeap []matchResult
+
+func (h pathHeap) Len() int           { return len(h) }
+func (h pathHeap) Less(i, j int) bool { return h[i].Path < h[j].Path }
+func (h pathHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pathHeap) Push(x any)        { *h = append(*h, x.(matchResult)) }
+func (h *pathHeap) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
+
+// reorderMatches implements --reorder-window: it holds up to n matches
+// from in and, once the window is full, forwards the lexically smallest
+// path before taking the next. The output is sorted within any n
+// consecutive matches but not overall, so a path smaller than everything
+// already sent can still follow. The rest are flushed in order when in is
+// closed.
+func reorderMatches(in <-chan matchResult, n int) <-chan matchResult {
+	out := make(chan matchResult, cap(in))
+	go func() {
+		defer close(out)
+		h := make(pathHeap, 0, n)
+		for m := range in {
+			heap.Push(&h, m)
+			if h.Len() > n {
+				out <- heap.Pop(&h).(matchResult)
+			}
+		}
+		for h.Len() > 0 {
+			out <- heap.Pop(&h).(matchResult)
+		}
+	}()
+	return out
+}
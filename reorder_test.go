@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestReorderMatches(t *testing.T) {
+	// Paths arrive shuffled but never more than two places from where
+	// they belong, so a window of 3 sorts them completely.
+	order := []int{2, 0, 1, 4, 5, 3, 7, 6, 9, 8}
+	in := make(chan matchResult, len(order))
+	for _, i := range order {
+		in <- matchResult{Path: fmt.Sprintf("/data/%02d.db", i)}
+	}
+	close(in)
+
+	var got []string
+	for m := range reorderMatches(in, 3) {
+		got = append(got, m.Path)
+	}
+	if len(got) != len(order) {
+		t.Fatalf("expected %d matches, got %d", len(order), len(got))
+	}
+	if !slices.IsSorted(got) {
+		t.Fatalf("expected locally sorted output, got %v", got)
+	}
+}
+
+func TestReorderMatchesWindowIsBounded(t *testing.T) {
+	// A path smaller than everything already sent still comes out, late:
+	// the window only sorts what it holds.
+	in := make(chan matchResult, 4)
+	for _, p := range []string{"/c.db", "/d.db", "/e.db", "/a.db"} {
+		in <- matchResult{Path: p}
+	}
+	close(in)
+
+	var got []string
+	for m := range reorderMatches(in, 2) {
+		got = append(got, m.Path)
+	}
+	if want := []string{"/c.db", "/a.db", "/d.db", "/e.db"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
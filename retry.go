@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// retryable reports whether err is a transient I/O error worth retrying,
+// as network filesystems produce under load. Permission errors and missing
+// files never are.
+func retryable(err error) bool {
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// readHeader opens path and reads up to sqliteHeaderSize bytes from it,
+// retrying the open and read up to opts.Retries times on transient errors.
+// A short read at end of file is not an error. On success the caller must
+// close the returned file.
+func readHeader(path string, opts checkOptions) (*os.File, []byte, int, error) {
+	buf := make([]byte, sqliteHeaderSize)
+	for attempt := 1; ; attempt++ {
+		f, err := openForCheck(path, opts.NoATime)
+		n := 0
+		if err == nil {
+			if opts.Fadvise {
+				adviseSequential(f)
+			}
+			if opts.Mmap {
+				n, err = readHeaderMmap(f, buf)
+			} else {
+				n, err = io.ReadFull(f, buf)
+			}
+			if opts.Stats != nil {
+				opts.Stats.BytesRead.Add(int64(n))
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = nil
+			}
+			if err == nil {
+				return f, buf, n, nil
+			}
+			f.Close()
+		}
+		if !retryable(err) || attempt > opts.Retries {
+			return nil, nil, 0, err
+		}
+		if opts.OnRetry != nil {
+			opts.OnRetry(fmt.Errorf("%s: attempt %d of %d: %w", path, attempt, opts.Retries+1, err))
+		}
+		time.Sleep(opts.RetryDelay)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// failOpens makes the next n opens fail with err.
+func failOpens(t *testing.T, n int, err error) *int {
+	t.Helper()
+	calls := 0
+	orig := openFile
+	t.Cleanup(func() { openFile = orig })
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		calls++
+		if calls <= n {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return orig(name, flag, perm)
+	}
+	return &calls
+}
+
+func TestCheckSQLiteMagicRetriesTransientErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flaky.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	calls := failOpens(t, 2, syscall.EIO)
+	var retried []error
+	opts := checkOptions{Retries: 2, RetryDelay: time.Millisecond, OnRetry: func(err error) { retried = append(retried, err) }}
+	if _, ok, err := checkSQLiteMagic(path, opts); err != nil || !ok {
+		t.Fatalf("expected match after retries, got ok=%v err=%v", ok, err)
+	}
+	if *calls != 3 || len(retried) != 2 {
+		t.Fatalf("expected 3 opens and 2 retries, got %d and %v", *calls, retried)
+	}
+
+	calls = failOpens(t, 3, syscall.ETIMEDOUT)
+	if _, _, err := checkSQLiteMagic(path, checkOptions{Retries: 2, RetryDelay: time.Millisecond}); err == nil {
+		t.Fatalf("expected error once retries are exhausted")
+	}
+	if *calls != 3 {
+		t.Fatalf("expected 3 opens, got %d", *calls)
+	}
+}
+
+func TestCheckSQLiteMagicDoesNotRetryPermissionErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	calls := failOpens(t, 1, syscall.EACCES)
+	if _, _, err := checkSQLiteMagic(path, checkOptions{Retries: 5, RetryDelay: time.Millisecond}); err == nil {
+		t.Fatalf("expected permission error")
+	}
+	if *calls != 1 {
+		t.Fatalf("expected a single open, got %d", *calls)
+	}
+}
+
+func TestScanPathsReportsRetries(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "flaky.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	failOpens(t, 1, syscall.EIO)
+
+	matches := make(chan matchResult, 1)
+	errs := make(chan error, 4)
+	opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Check: checkOptions{Retries: 1, RetryDelay: time.Millisecond}}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected the file to match after a retry")
+	}
+	err := <-errs
+	if err == nil || !strings.HasPrefix(err.Error(), "retrying: ") {
+		t.Fatalf("expected a retrying error, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3API is the part of the S3 client used by scanS3Prefix.
+type s3API interface {
+	s3.ListObjectsV2APIClient
+	GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// newS3Client builds a client from the standard AWS credential chain
+// (environment, shared config and credentials files, instance roles).
+func newS3Client(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg), nil
+}
+
+// parseBucketURI splits scheme://bucket/prefix into bucket and prefix.
+func parseBucketURI(uri, scheme string) (bucket, prefix string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != scheme || u.Host == "" {
+		return "", "", fmt.Errorf("%q is not a %s://bucket/prefix URI", uri, scheme)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// scanS3Prefix lists every object under an s3://bucket/prefix URI and
+// reports those starting with the SQLite magic, fetching only their first
+// bytes with a ranged GetObject. Sizes and mtimes come from the listing.
+func scanS3Prefix(ctx context.Context, client s3API, uri string, opts scanOptions, matches chan<- matchResult, errs chan<- error) error {
+	bucket, prefix, err := parseBucketURI(uri, "s3")
+	if err != nil {
+		close(matches)
+		close(errs)
+		return err
+	}
+	list := func(ctx context.Context, yield func(remoteObject) error) error {
+		p := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(prefix),
+		})
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, o := range page.Contents {
+				key := aws.ToString(o.Key)
+				if strings.HasSuffix(key, "/") {
+					// Folder placeholder objects.
+					continue
+				}
+				obj := remoteObject{
+					URI:  "s3://" + bucket + "/" + key,
+					Key:  key,
+					Size: aws.ToInt64(o.Size),
+				}
+				if o.LastModified != nil {
+					obj.ModTime = *o.LastModified
+				}
+				if err := yield(obj); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	readHead := func(ctx context.Context, obj remoteObject, n int64) ([]byte, error) {
+		out, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(obj.Key),
+			Range:  aws.String(fmt.Sprintf("bytes=0-%d", n-1)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer out.Body.Close()
+		return io.ReadAll(io.LimitReader(out.Body, n))
+	}
+	return scanObjects(ctx, list, readHead, opts, matches, errs)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 serves a fixed set of objects, two per listing page, and records
+// the ranges requested.
+type fakeS3 struct {
+	objects map[string][]byte
+	ranges  []string
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, aws.ToString(in.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if in.ContinuationToken != nil {
+		fmt.Sscan(*in.ContinuationToken, &start)
+	}
+	out := &s3.ListObjectsV2Output{}
+	for i := start; i < len(keys) && i < start+2; i++ {
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(keys[i]),
+			Size:         aws.Int64(int64(len(f.objects[keys[i]]))),
+			LastModified: aws.Time(time.Unix(1700000000, 0)),
+		})
+	}
+	if start+2 < len(keys) {
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(fmt.Sprint(start + 2))
+	}
+	return out, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.ranges = append(f.ranges, aws.ToString(in.Range))
+	var first, last int
+	fmt.Sscanf(aws.ToString(in.Range), "bytes=%d-%d", &first, &last)
+	data := f.objects[aws.ToString(in.Key)]
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data[first:min(last+1, len(data))]))}, nil
+}
+
+func TestScanS3Prefix(t *testing.T) {
+	big := append(append([]byte{}, sqliteMagic...), make([]byte, 4096)...)
+	client := &fakeS3{objects: map[string][]byte{
+		"backups/a.db":        big,
+		"backups/nested/b":    sqliteMagic,
+		"backups/notes.txt":   []byte("just some text, not a database"),
+		"backups/tiny":        []byte("x"),
+		"backups/folder/":     nil,
+		"elsewhere/c.db":      sqliteMagic,
+		"backups/fake.sqlite": []byte("SQLite format 2\x00 nope"),
+	}}
+
+	matches := make(chan matchResult, 10)
+	errs := make(chan error, 10)
+	stats := &scanStats{}
+	opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Check: checkOptions{Stats: stats}}
+	if err := scanS3Prefix(context.Background(), client, "s3://bucket/backups/", opts, matches, errs); err != nil {
+		t.Fatalf("scanS3Prefix: %v", err)
+	}
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for m := range matches {
+		got = append(got, fmt.Sprintf("%s %d", formatPath(m.Path), m.Size))
+	}
+	sort.Strings(got)
+	want := []string{"s3://bucket/backups/a.db 4112", "s3://bucket/backups/nested/b 16"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for _, r := range client.ranges {
+		var first, last int
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &first, &last); err != nil || first != 0 || last >= sqliteHeaderSize {
+			t.Fatalf("expected only header-sized ranges, got %q", r)
+		}
+	}
+	if len(client.ranges) != 4 {
+		t.Fatalf("expected the 4 objects under the prefix large enough to match to be fetched, got %v", client.ranges)
+	}
+	// --cloud-watch, --statsd-addr, --progress-json and --benchmark-mode
+	// read these counters.
+	if stats.FilesExamined.Load() != 5 || stats.FilesChecked.Load() != 4 {
+		t.Fatalf("expected 5 objects examined and 4 checked, got %d and %d", stats.FilesExamined.Load(), stats.FilesChecked.Load())
+	}
+}
+
+func TestParseBucketURI(t *testing.T) {
+	bucket, prefix, err := parseBucketURI("s3://my-bucket/some/prefix", "s3")
+	if err != nil || bucket != "my-bucket" || prefix != "some/prefix" {
+		t.Fatalf("unexpected parse: %q %q %v", bucket, prefix, err)
+	}
+	for _, bad := range []string{"my-bucket/prefix", "gs://bucket/x", "s3:///x"} {
+		if _, _, err := parseBucketURI(bad, "s3"); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sort"
+)
+
+// sampleMatches implements --sample: it keeps a uniformly random n of the
+// matches from in using reservoir sampling (Algorithm R), so memory stays
+// bounded by n however many databases the scan finds, and forwards them in
+// the order they were found once in is closed. The same seed over the
+// same input always picks the same sample.
+func sampleMatches(in <-chan matchResult, n int, seed uint64) <-chan matchResult {
+	out := make(chan matchResult, cap(in))
+	go func() {
+		defer close(out)
+		type slot struct {
+			seq int
+			m   matchResult
+		}
+		rng := rand.New(rand.NewPCG(seed, seed))
+		reservoir := make([]slot, 0, n)
+		seen := 0
+		for m := range in {
+			if len(reservoir) < n {
+				reservoir = append(reservoir, slot{seen, m})
+			} else if j := rng.IntN(seen + 1); j < n {
+				reservoir[j] = slot{seen, m}
+			}
+			seen++
+		}
+		sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].seq < reservoir[j].seq })
+		for _, s := range reservoir {
+			out <- s.m
+		}
+	}()
+	return out
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func runSample(n int, seed uint64, total int) []string {
+	in := make(chan matchResult, total)
+	for i := 0; i < total; i++ {
+		in <- matchResult{Path: fmt.Sprintf("/data/%03d.db", i)}
+	}
+	close(in)
+	var got []string
+	for m := range sampleMatches(in, n, seed) {
+		got = append(got, m.Path)
+	}
+	return got
+}
+
+func TestSampleMatchesDeterministic(t *testing.T) {
+	first := runSample(5, 42, 100)
+	if len(first) != 5 {
+		t.Fatalf("expected 5 sampled matches, got %d: %v", len(first), first)
+	}
+	if !slices.IsSorted(first) {
+		t.Fatalf("expected the sample in discovery order, got %v", first)
+	}
+	if again := runSample(5, 42, 100); !slices.Equal(first, again) {
+		t.Fatalf("same seed gave different samples: %v and %v", first, again)
+	}
+	if other := runSample(5, 7, 100); slices.Equal(first, other) {
+		t.Fatalf("different seeds gave the same sample %v", first)
+	}
+}
+
+func TestSampleMatchesFewerThanN(t *testing.T) {
+	got := runSample(10, 1, 3)
+	want := []string{"/data/000.db", "/data/001.db", "/data/002.db"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected every match when there are fewer than N, got %v", got)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// newestFiles returns the regular files among entries, most recently
+// modified first, for --scan-order=mtime. Entries whose mtime cannot be
+// read sort last, in their original order.
+func newestFiles(entries []fs.DirEntry) []fs.DirEntry {
+	type file struct {
+		d       fs.DirEntry
+		modTime time.Time
+	}
+	files := make([]file, 0, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		f := file{d: e}
+		if info, err := e.Info(); err == nil {
+			f.modTime = info.ModTime()
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	out := make([]fs.DirEntry, len(files))
+	for i, f := range files {
+		out[i] = f.d
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanPathsNewestFirstWithinDirectory(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	// Lexical order is the reverse of mtime order.
+	for i, name := range []string{"a-old.db", "b-middle.db", "c-new.db"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		mtime := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+
+	for _, useCache := range []bool{false, true} {
+		var order []string
+		orig := openFile
+		openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			order = append(order, filepath.Base(name))
+			return orig(name, flag, perm)
+		}
+
+		opts := scanOptions{Workers: 1, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), NewestFirst: true}
+		if useCache {
+			cache, err := loadScanCache(filepath.Join(t.TempDir(), "cache.json"), "")
+			if err != nil {
+				t.Fatalf("loadScanCache: %v", err)
+			}
+			opts.Cache = cache
+		}
+		matches := make(chan matchResult, 3)
+		errs := make(chan error, 3)
+		err := scanPaths(context.Background(), []string{root}, opts, matches, errs)
+		openFile = orig
+		if err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+		want := []string{"c-new.db", "b-middle.db", "a-old.db"}
+		if len(order) != len(want) {
+			t.Fatalf("cache=%v: expected files checked %v, got %v", useCache, want, order)
+		}
+		for i := range want {
+			if order[i] != want[i] {
+				t.Fatalf("cache=%v: expected files checked %v, got %v", useCache, want, order)
+			}
+		}
+	}
+}
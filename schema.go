@@ -0,0 +1,252 @@
+package main
+
+// outputSchema returns a JSON Schema (draft 7) describing the document that
+// the current flag combination produces. With jsonl set it describes a single
+// line; otherwise it describes the --json object with its entries array.
+func outputSchema(opts outputOptions) map[string]any {
+	entry := entrySchema(opts)
+	if opts.JSONL {
+		if opts.NullSafe {
+			entry = map[string]any{"oneOf": []any{entry, emptyJSONLSchema()}}
+		}
+		entry["$schema"] = "http://json-schema.org/draft-07/schema#"
+		entry["title"] = "sqlite-scanner JSONL entry"
+		return entry
+	}
+	return map[string]any{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "sqlite-scanner JSON output",
+		"type":                 "object",
+		"required":             []string{opts.jsonKey()},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			opts.jsonKey(): map[string]any{
+				"type":  "array",
+				"items": entry,
+			},
+			"ext_stats": map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "integer", "minimum": 1},
+				"description":          "matches per lower-cased file extension, with (none) for files without one; present with --ext-stats",
+			},
+			"size_buckets": map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "integer", "minimum": 0},
+				"description":          "matches per --size-buckets range, such as <1KB or >=100MB, including empty ranges; present with --count-by-size-bucket",
+			},
+			"config": map[string]any{
+				"type":        "object",
+				"description": "version, resolved roots, header_bytes and the effective value of every flag; present with --echo-config",
+			},
+			"truncated": map[string]any{
+				"type":        "boolean",
+				"description": "present and true when the scan was interrupted before finishing",
+			},
+		},
+	}
+}
+
+func entrySchema(opts outputOptions) map[string]any {
+	pathDesc := "absolute path of the matched file"
+	if opts.RelativeTo != "" {
+		pathDesc = "path of the matched file relative to the working directory"
+	}
+	props := map[string]any{
+		"path": map[string]any{
+			"type":        "string",
+			"description": pathDesc,
+		},
+	}
+	required := []string{"path"}
+	if opts.ShowKind {
+		props["kind"] = map[string]any{
+			"type":        "string",
+			"enum":        []string{kindDatabase, kindWAL, kindPlaceholder},
+			"description": "database, wal for a standalone write-ahead log, or placeholder for an empty .db or .sqlite file (--include-zero-size)",
+		}
+		required = append(required, "kind")
+	}
+	if opts.Host != "" {
+		props["host"] = map[string]any{
+			"type":        "string",
+			"description": "machine the scan ran on (--host or --resolve-hostname)",
+		}
+		required = append(required, "host")
+	}
+	if opts.ShowLabel {
+		props["label"] = map[string]any{
+			"type":        "string",
+			"description": "label of the root the file was found under (--label)",
+		}
+		required = append(required, "label")
+	}
+	if opts.ShowSize {
+		props["size"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"description": "file size in bytes",
+		}
+		required = append(required, "size")
+	}
+	if opts.ShowSchemaFormat {
+		props["schema_format"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"maximum":     255,
+			"description": "schema format number from header offset 44 (1-4), 0 if the header is incomplete",
+		}
+		required = append(required, "schema_format")
+	}
+	if opts.ShowFreelist {
+		props["freelist_pages"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"description": "freelist page count from header offset 36",
+		}
+		required = append(required, "freelist_pages")
+	}
+	if opts.ShowPageCount {
+		props["page_count"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"description": "database size in pages from header offset 28, or file size / page size when the header's count is stale; 0 if unknown",
+		}
+		required = append(required, "page_count")
+	}
+	if opts.ShowTables {
+		props["tables"] = map[string]any{
+			"type":        "integer",
+			"minimum":     -1,
+			"description": "user tables in sqlite_master, -1 if the schema could not be parsed",
+		}
+		required = append(required, "tables")
+	}
+	if opts.ShowJournalMode {
+		props["journal_mode"] = map[string]any{
+			"type":        "string",
+			"enum":        []string{journalWAL, journalRollback, journalUnknown},
+			"description": "journaling mode from the version bytes at header offsets 18-19 (absent for --wal-files logs)",
+		}
+	}
+	if opts.ShowAutoVacuum {
+		props["autovacuum"] = map[string]any{
+			"type":        "boolean",
+			"description": "whether auto_vacuum is enabled, i.e. largest_root_page is nonzero (absent for --wal-files logs)",
+		}
+		props["incremental_vacuum"] = map[string]any{
+			"type":        "boolean",
+			"description": "whether auto_vacuum is in incremental mode (header offset 64)",
+		}
+		props["largest_root_page"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"description": "largest root b-tree page from header offset 52, 0 unless auto_vacuum is enabled",
+		}
+	}
+	if opts.ShowVersion {
+		props["sqlite_version"] = map[string]any{
+			"type":        "string",
+			"description": "SQLite version that last wrote the file, from header offset 96 (not the version that created it); absent when unknown or for --wal-files logs",
+		}
+		props["sqlite_version_stale"] = map[string]any{
+			"type":        "boolean",
+			"const":       true,
+			"description": "present when the version-valid-for number (offset 92) differs from the change counter (offset 24), so a SQLite older than 3.7.0 has changed the file since sqlite_version wrote it",
+		}
+		props["user_version"] = map[string]any{
+			"type":        "integer",
+			"description": "user_version from header offset 60 (absent for --wal-files logs)",
+		}
+		props["application_id"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"description": "application_id from header offset 68 (absent for --wal-files logs)",
+		}
+	}
+	if opts.ShowMode {
+		props["mode"] = map[string]any{
+			"type":        "string",
+			"pattern":     "^[0-7]{4}$",
+			"description": "permission bits in octal, e.g. 0644; 0000 if unknown",
+		}
+		required = append(required, "mode")
+	}
+	if opts.ShowHash || opts.ShowBlake3 {
+		field, desc := "sha256", "hex SHA-256 of the whole file"
+		if opts.ShowBlake3 {
+			field, desc = "blake3", "hex BLAKE3 of the whole file (not comparable with sha256)"
+		}
+		props[field] = map[string]any{
+			"type":        "string",
+			"pattern":     "^[0-9a-f]{64}$",
+			"description": desc,
+		}
+		required = append(required, field)
+		props["hash_partial"] = map[string]any{
+			"type":        "boolean",
+			"const":       true,
+			"description": "present when --hash-max-bytes stopped hashing early, so " + field + " covers only hashed_bytes",
+		}
+		props["hashed_bytes"] = map[string]any{
+			"type":        "integer",
+			"minimum":     1,
+			"description": "number of leading bytes covered by a partial " + field,
+		}
+	}
+	if opts.ShowOpenable {
+		props["openable"] = map[string]any{
+			"type":        "boolean",
+			"description": "whether the SQLite driver could read the schema version",
+		}
+		required = append(required, "openable")
+	}
+	if opts.ShowLocked {
+		props["locked"] = map[string]any{
+			"type":        "boolean",
+			"description": "whether another process held a lock on the file when it was checked",
+		}
+		required = append(required, "locked")
+	}
+	if opts.ShowSharedLocks {
+		props["shared_locks"] = map[string]any{
+			"type":        "integer",
+			"minimum":     0,
+			"description": "number of read locks held on the file, from /proc/locks",
+		}
+		required = append(required, "shared_locks")
+	}
+	if opts.TruncatePath > 0 {
+		props["display_path"] = map[string]any{
+			"type":        "string",
+			"maxLength":   opts.TruncatePath,
+			"description": "path shortened from the left with ... for display",
+		}
+		required = append(required, "display_path")
+	}
+	return map[string]any{
+		"type":                 "object",
+		"required":             required,
+		"additionalProperties": false,
+		"properties":           props,
+	}
+}
+
+// emptyJSONLSchema describes the line --null-safe-json prints when nothing
+// matched.
+func emptyJSONLSchema() map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"required":             []string{"_empty"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"_empty": map[string]any{
+				"const":       true,
+				"description": "the scan found no matches (--null-safe-json)",
+			},
+			"truncated": map[string]any{
+				"type":        "boolean",
+				"description": "present and true when the scan was interrupted before finishing",
+			},
+		},
+	}
+}
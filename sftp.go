@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpTarget is a parsed --sftp argument: user@host[:port]:/path.
+type sftpTarget struct {
+	User string
+	Addr string // host:port
+	Path string
+}
+
+func parseSFTPTarget(s string) (sftpTarget, error) {
+	user, rest, ok := strings.Cut(s, "@")
+	i := strings.Index(rest, ":/")
+	if !ok || user == "" || i <= 0 {
+		return sftpTarget{}, fmt.Errorf("%q is not in user@host:/path form", s)
+	}
+	host := rest[:i]
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	return sftpTarget{User: user, Addr: host, Path: rest[i+1:]}, nil
+}
+
+// uri returns the sftp:// URL reported for a remote path.
+func (t sftpTarget) uri(path string) string {
+	host := t.Addr
+	if h, port, err := net.SplitHostPort(t.Addr); err == nil && port == "22" {
+		host = h
+	}
+	return "sftp://" + t.User + "@" + host + path
+}
+
+// dialSFTP connects over SSH, authenticating with keyFile when given and
+// otherwise with the keys in the running ssh-agent. The host key must be
+// listed in ~/.ssh/known_hosts. The returned function closes the session.
+func dialSFTP(t sftpTarget, keyFile string, timeout time.Duration) (*sftp.Client, func() error, error) {
+	var auth []ssh.AuthMethod
+	if keyFile != "" {
+		pem, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", keyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+		defer conn.Close()
+		signers, err := agent.NewClient(conn).Signers()
+		if err != nil {
+			return nil, nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signers...))
+	} else {
+		return nil, nil, fmt.Errorf("no ssh-agent running (SSH_AUTH_SOCK is unset); use --sftp-key")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("known_hosts: %w", err)
+	}
+
+	conn, err := ssh.Dial("tcp", t.Addr, &ssh.ClientConfig{
+		User:            t.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return client, func() error {
+		client.Close()
+		return conn.Close()
+	}, nil
+}
+
+// scanSFTP walks t.Path on the server and reports files starting with the
+// SQLite magic, reading only their first bytes. Matches are reported as
+// sftp:// URLs.
+func scanSFTP(ctx context.Context, client *sftp.Client, t sftpTarget, opts scanOptions, matches chan<- matchResult, errs chan<- error) error {
+	list := func(ctx context.Context, yield func(remoteObject) error) error {
+		w := client.Walk(t.Path)
+		for w.Step() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := w.Err(); err != nil {
+				if os.IsPermission(err) {
+					opts.Logger.Debug("skipping unreadable path", "path", w.Path())
+					opts.Denied.add(t.uri(w.Path()))
+					continue
+				}
+				errs <- fmt.Errorf("%s: %w", t.uri(w.Path()), err)
+				continue
+			}
+			info := w.Stat()
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			obj := remoteObject{URI: t.uri(w.Path()), Key: w.Path(), Size: info.Size(), ModTime: info.ModTime()}
+			if err := yield(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	readHead := func(ctx context.Context, obj remoteObject, n int64) ([]byte, error) {
+		f, err := client.Open(obj.Key)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		buf := make([]byte, n)
+		read, err := io.ReadFull(f, buf)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		}
+		return buf[:read], err
+	}
+	return scanObjects(ctx, list, readHead, opts, matches, errs)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// newTestSFTPClient connects a client to an in-memory SFTP server over a
+// pipe, skipping SSH entirely.
+func newTestSFTPClient(t *testing.T) *sftp.Client {
+	t.Helper()
+	c, s := net.Pipe()
+	server := sftp.NewRequestServer(s, sftp.InMemHandler())
+	go server.Serve()
+	client, err := sftp.NewClientPipe(c, c)
+	if err != nil {
+		t.Fatalf("sftp client: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client
+}
+
+func TestScanSFTP(t *testing.T) {
+	client := newTestSFTPClient(t)
+	files := map[string][]byte{
+		"/var/data/app.db":        append(append([]byte{}, sqliteMagic...), make([]byte, 300)...),
+		"/var/data/logs/app.log":  []byte("2024-01-01 started, not a database"),
+		"/var/data/nested/cache":  sqliteMagic,
+		"/var/other/elsewhere.db": sqliteMagic,
+	}
+	for _, dir := range []string{"/var/data/logs", "/var/data/nested", "/var/other"} {
+		if err := client.MkdirAll(dir); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for path, content := range files {
+		f, err := client.Create(path)
+		if err != nil {
+			t.Fatalf("create %s: %v", path, err)
+		}
+		f.Write(content)
+		f.Close()
+	}
+
+	target, err := parseSFTPTarget("alice@db-host:/var/data")
+	if err != nil {
+		t.Fatalf("parseSFTPTarget: %v", err)
+	}
+	matches := make(chan matchResult, 10)
+	errs := make(chan error, 10)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if err := scanSFTP(context.Background(), client, target, opts, matches, errs); err != nil {
+		t.Fatalf("scanSFTP: %v", err)
+	}
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for m := range matches {
+		got = append(got, formatPlainMatch(m, outputOptions{ShowSize: true}))
+	}
+	sort.Strings(got)
+	want := []string{
+		"sftp://alice@db-host/var/data/app.db (316 bytes)",
+		"sftp://alice@db-host/var/data/nested/cache (16 bytes)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseSFTPTarget(t *testing.T) {
+	for in, want := range map[string]sftpTarget{
+		"alice@host:/var/data":      {"alice", "host:22", "/var/data"},
+		"bob@host:2222:/srv":        {"bob", "host:2222", "/srv"},
+		"root@[2001:db8::1]:22:/db": {"root", "[2001:db8::1]:22", "/db"},
+	} {
+		got, err := parseSFTPTarget(in)
+		if err != nil || got != want {
+			t.Fatalf("parseSFTPTarget(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	if got := (sftpTarget{"bob", "host:2222", "/srv"}).uri("/srv/a.db"); got != "sftp://bob@host:2222/srv/a.db" {
+		t.Fatalf("unexpected uri %q", got)
+	}
+	for _, bad := range []string{"host:/var", "alice@host", "alice@host:relative", "@host:/x"} {
+		if _, err := parseSFTPTarget(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
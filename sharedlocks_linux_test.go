@@ -0,0 +1,54 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseProcLocks(t *testing.T) {
+	const listing = `1: POSIX  ADVISORY  READ  1234 08:01:1835009 1073741826 1073742335
+2: POSIX  ADVISORY  WRITE 1235 08:01:1835009 1073741824 1073741824
+2: -> POSIX  ADVISORY  READ  1236 08:01:1835009 1073741826 1073742335
+3: OFDLCK ADVISORY  READ  -1 08:01:1835009 0 EOF
+4: FLOCK  ADVISORY  READ  1237 08:01:99 0 EOF
+5: POSIX  ADVISORY  READ  1238 fd:01:1835009 0 EOF
+`
+	n, err := parseProcLocks(strings.NewReader(listing), 8, 1, 1835009)
+	if err != nil {
+		t.Fatalf("parseProcLocks: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 granted read locks, got %d", n)
+	}
+}
+
+func TestReadOnlyCheckCountsReadLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.db")
+	if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	res, _, err := checkSQLiteMagic(path, checkOptions{SharedLocks: true})
+	if err != nil || res.SharedLockCount != 0 {
+		t.Fatalf("expected no locks, got %d (err %v)", res.SharedLockCount, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	lk := unix.Flock_t{Type: unix.F_RDLCK}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	res, _, err = checkSQLiteMagic(path, checkOptions{SharedLocks: true})
+	if err != nil || res.SharedLockCount != 1 {
+		t.Fatalf("expected one shared lock, got %d (err %v)", res.SharedLockCount, err)
+	}
+}
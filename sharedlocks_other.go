@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+const readOnlyCheckSupported = false
+
+func sharedLockCount(f *os.File) (int, error) {
+	return 0, errors.New("--read-only-check is only supported on Linux")
+}
@@ -0,0 +1,50 @@
+package main
+
+import "log/slog"
+
+// matchSink receives every match in addition to the primary output. Add is
+// called from a single goroutine; Close flushes anything still buffered.
+type matchSink interface {
+	Add(m matchResult) error
+	Close() error
+}
+
+// teeMatches feeds each match to every sink before forwarding it to the
+// returned channel, passing events through untouched. Sink errors are
+// logged and do not stop the scan.
+func teeMatches(in <-chan matchResult, sinks []matchSink, logger *slog.Logger) <-chan matchResult {
+	if len(sinks) == 0 {
+		return in
+	}
+	out := make(chan matchResult, cap(in))
+	go func() {
+		defer close(out)
+		for m := range in {
+			if m.Event != "" {
+				// Sinks receive matches only, not --inotify-watch
+				// removal events.
+				out <- m
+				continue
+			}
+			for _, s := range sinks {
+				if err := s.Add(m); err != nil {
+					logger.Warn("sink error", "path", m.Path, "error", err)
+				}
+			}
+			out <- m
+		}
+	}()
+	return out
+}
+
+// closeSinks closes every sink, logging failures.
+func closeSinks(sinks []matchSink, logger *slog.Logger) error {
+	var failed error
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			logger.Error("could not close sink", "error", err)
+			failed = err
+		}
+	}
+	return failed
+}
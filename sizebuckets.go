@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// defaultSizeBuckets are the --size-buckets boundaries used when the flag
+// is not set.
+const defaultSizeBuckets = "1KB,1MB,100MB"
+
+// byteUnits are the suffixes parseByteSize accepts, in binary multiples.
+var byteUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseByteSize parses a size such as "512", "1KB" or "100MB". Units are
+// case-insensitive powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, mult = strings.TrimSpace(strings.TrimSuffix(upper, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/mult {
+		return 0, fmt.Errorf("%q is not a size like 512, 64KB or 100MB", s)
+	}
+	return n * mult, nil
+}
+
+// sizeBuckets is a matchSink tallying matches into size ranges for
+// --count-by-size-bucket. It is only read once the scan has finished.
+type sizeBuckets struct {
+	bounds []int64
+	labels []string
+	counts []int
+}
+
+// newSizeBuckets parses spec, a comma-separated list of increasing
+// boundaries. N boundaries make N+1 buckets: below the first, between each
+// pair and at or above the last.
+func newSizeBuckets(spec string) (*sizeBuckets, error) {
+	var bounds []int64
+	var names []string
+	for _, part := range strings.Split(spec, ",") {
+		n, err := parseByteSize(part)
+		if err != nil {
+			return nil, err
+		}
+		if len(bounds) > 0 && n <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("boundaries must increase, but %s follows %s", strings.TrimSpace(part), names[len(names)-1])
+		}
+		bounds = append(bounds, n)
+		names = append(names, strings.TrimSpace(part))
+	}
+	labels := []string{"<" + names[0]}
+	for i := 1; i < len(names); i++ {
+		labels = append(labels, names[i-1]+"-"+names[i])
+	}
+	labels = append(labels, ">="+names[len(names)-1])
+	return &sizeBuckets{bounds: bounds, labels: labels, counts: make([]int, len(labels))}, nil
+}
+
+// bucket returns the index of the bucket holding size.
+func (b *sizeBuckets) bucket(size int64) int {
+	for i, bound := range b.bounds {
+		if size < bound {
+			return i
+		}
+	}
+	return len(b.bounds)
+}
+
+func (b *sizeBuckets) Add(m matchResult) error {
+	b.counts[b.bucket(m.Size)]++
+	return nil
+}
+
+func (b *sizeBuckets) Close() error { return nil }
+
+// byLabel returns the counts keyed by bucket label, including empty
+// buckets, for the "size_buckets" field of --json output.
+func (b *sizeBuckets) byLabel() map[string]int {
+	counts := make(map[string]int, len(b.labels))
+	for i, label := range b.labels {
+		counts[label] = b.counts[i]
+	}
+	return counts
+}
+
+// report prints the histogram to w, smallest bucket first.
+func (b *sizeBuckets) report(w io.Writer) {
+	fmt.Fprintln(w, "matches by size:")
+	for i, label := range b.labels {
+		fmt.Fprintf(w, "  %-12s %d\n", label, b.counts[i])
+	}
+}
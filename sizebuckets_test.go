@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSizeBuckets(t *testing.T) {
+	dir := t.TempDir()
+	b, err := newSizeBuckets(defaultSizeBuckets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, size := range []int64{100, 1023, 1024, 500 << 10, 1 << 20, 50 << 20, 100 << 20} {
+		// Sparse files keep the larger sizes cheap.
+		path := filepath.Join(dir, fmt.Sprintf("%d.db", i))
+		if err := os.WriteFile(path, sqliteMagic, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(path, size); err != nil {
+			t.Fatal(err)
+		}
+		res, ok, err := checkSQLiteMagic(path, checkOptions{})
+		if err != nil || !ok {
+			t.Fatalf("expected %s to match, got ok=%v err=%v", path, ok, err)
+		}
+		b.Add(res)
+	}
+	want := map[string]int{"<1KB": 2, "1KB-1MB": 2, "1MB-100MB": 2, ">=100MB": 1}
+	got := b.byLabel()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for label, n := range want {
+		if got[label] != n {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	b.report(&buf)
+	wantReport := "matches by size:\n  <1KB         2\n  1KB-1MB      2\n  1MB-100MB    2\n  >=100MB      1\n"
+	if buf.String() != wantReport {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestNewSizeBucketsRejects(t *testing.T) {
+	for _, spec := range []string{"", "1MB,1KB", "1KB,1KB", "ten", "-5", "1PB"} {
+		if _, err := newSizeBuckets(spec); err == nil {
+			t.Fatalf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestStreamMatchesJSONSizeBuckets(t *testing.T) {
+	b, _ := newSizeBuckets("1MB")
+	matches := make(chan matchResult, 1)
+	m := matchResult{Path: "/data/a.db", Size: 4096}
+	b.Add(m)
+	matches <- m
+	close(matches)
+
+	out := captureStdout(t, func() {
+		streamMatches(context.Background(), os.Stdout, matches, outputOptions{JSON: true, SizeBuckets: b})
+	})
+	var doc struct {
+		SizeBuckets map[string]int `json:"size_buckets"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON document: %v\n%s", err, out)
+	}
+	if doc.SizeBuckets["<1MB"] != 1 || doc.SizeBuckets[">=1MB"] != 0 || len(doc.SizeBuckets) != 2 {
+		t.Fatalf("unexpected document:\n%s", out)
+	}
+}
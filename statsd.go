@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// statsdQueueSize is how many metric lines may wait to be sent; further
+// ones are dropped rather than slowing the scan down.
+const statsdQueueSize = 1024
+
+// statsdInterval is how often the files_checked counter is reported.
+const statsdInterval = time.Second
+
+// statsdSink is a matchSink sending metrics to a StatsD server over UDP
+// (--statsd-addr): matches_found for every match, files_checked as the
+// files checked since the last report, every statsdInterval, and
+// scan_duration as a timer when it is closed. Sends never block; when the
+// queue is full the metric is dropped.
+type statsdSink struct {
+	w      io.WriteCloser
+	prefix string
+	files  *atomic.Int64
+	start  time.Time
+	queue  chan string
+	stop   chan struct{}
+	done   chan struct{}
+	// reported is the files_checked total sent so far; only the sender
+	// goroutine uses it.
+	reported int64
+}
+
+// newStatsdSink dials addr and starts sending. files is the scan's
+// FilesChecked counter and start the time the scan began.
+func newStatsdSink(addr, prefix string, files *atomic.Int64, start time.Time) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &statsdSink{
+		w:      conn,
+		prefix: strings.TrimSuffix(prefix, ".") + ".",
+		files:  files,
+		start:  start,
+		queue:  make(chan string, statsdQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run(statsdInterval)
+	return s, nil
+}
+
+func (s *statsdSink) run(interval time.Duration) {
+	defer close(s.done)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case line := <-s.queue:
+			s.w.Write([]byte(line))
+		case <-tick.C:
+			s.reportFiles()
+		case <-s.stop:
+			for {
+				select {
+				case line := <-s.queue:
+					s.w.Write([]byte(line))
+				default:
+					s.reportFiles()
+					return
+				}
+			}
+		}
+	}
+}
+
+// reportFiles sends the files checked since the last report.
+func (s *statsdSink) reportFiles() {
+	n := s.files.Load()
+	if n == s.reported {
+		return
+	}
+	s.w.Write([]byte(s.metric("files_checked", n-s.reported, "c")))
+	s.reported = n
+}
+
+func (s *statsdSink) metric(name string, v int64, typ string) string {
+	return fmt.Sprintf("%s%s:%d|%s", s.prefix, name, v, typ)
+}
+
+// send queues line without blocking, dropping it when the queue is full.
+func (s *statsdSink) send(line string) {
+	select {
+	case s.queue <- line:
+	default:
+	}
+}
+
+func (s *statsdSink) Add(m matchResult) error {
+	s.send(s.metric("matches_found", 1, "c"))
+	return nil
+}
+
+// Close sends what is queued, the last files_checked count and the
+// scan_duration timer, then closes the socket.
+func (s *statsdSink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.w.Write([]byte(s.metric("scan_duration", time.Since(s.start).Milliseconds(), "ms")))
+	return s.w.Close()
+}
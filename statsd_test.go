@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatsdSink(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP: %v", err)
+	}
+	defer pc.Close()
+
+	var files atomic.Int64
+	s, err := newStatsdSink(pc.LocalAddr().String(), "myapp", &files, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.Store(5)
+	s.Add(matchResult{Path: "/data/a.db"})
+	s.Add(matchResult{Path: "/data/b.db"})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	buf := make([]byte, 512)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < 4; i++ {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("expected 4 packets, got %v after %d: %v", counts, i, err)
+		}
+		line := string(buf[:n])
+		name, _, _ := strings.Cut(line, ":")
+		counts[name]++
+		switch name {
+		case "myapp.matches_found":
+			if line != "myapp.matches_found:1|c" {
+				t.Fatalf("unexpected %q", line)
+			}
+		case "myapp.files_checked":
+			if line != "myapp.files_checked:5|c" {
+				t.Fatalf("unexpected %q", line)
+			}
+		case "myapp.scan_duration":
+			if !strings.HasSuffix(line, "|ms") {
+				t.Fatalf("unexpected %q", line)
+			}
+		default:
+			t.Fatalf("unexpected metric %q", line)
+		}
+	}
+	if counts["myapp.matches_found"] != 2 || counts["myapp.files_checked"] != 1 || counts["myapp.scan_duration"] != 1 {
+		t.Fatalf("unexpected metrics %v", counts)
+	}
+}
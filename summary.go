@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether fd is a terminal; tests replace it.
+var isTerminal = term.IsTerminal
+
+// matchCounter is a matchSink counting every match for the end-of-scan
+// summary.
+type matchCounter struct {
+	n atomic.Int64
+}
+
+func (c *matchCounter) Add(matchResult) error {
+	c.n.Add(1)
+	return nil
+}
+
+func (c *matchCounter) Close() error { return nil }
+
+// printSummary writes a "found N databases" line to w when stdoutFD is not
+// a terminal, so piped or redirected output still gets a status line on
+// stderr while interactive use stays clean.
+func printSummary(w io.Writer, stdoutFD int, found int64) {
+	if isTerminal(stdoutFD) {
+		return
+	}
+	fmt.Fprintln(w, summaryLine(found))
+}
+
+// summaryLine is the "found N databases" line.
+func summaryLine(found int64) string {
+	noun := "databases"
+	if found == 1 {
+		noun = "database"
+	}
+	return fmt.Sprintf("found %d %s", found, noun)
+}
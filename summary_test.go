@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintSummaryOnlyWhenRedirected(t *testing.T) {
+	orig := isTerminal
+	t.Cleanup(func() { isTerminal = orig })
+
+	for _, tc := range []struct {
+		tty   bool
+		found int64
+		want  string
+	}{
+		{true, 3, ""},
+		{false, 3, "found 3 databases\n"},
+		{false, 1, "found 1 database\n"},
+		{false, 0, "found 0 databases\n"},
+	} {
+		isTerminal = func(fd int) bool {
+			if fd != 42 {
+				t.Fatalf("expected the stdout fd to be checked, got %d", fd)
+			}
+			return tc.tty
+		}
+		var buf bytes.Buffer
+		printSummary(&buf, 42, tc.found)
+		if buf.String() != tc.want {
+			t.Fatalf("tty=%v found=%d: expected %q, got %q", tc.tty, tc.found, tc.want, buf.String())
+		}
+	}
+}
+
+func TestMatchCounter(t *testing.T) {
+	c := &matchCounter{}
+	in := make(chan matchResult, 3)
+	for range 3 {
+		in <- matchResult{Path: "/x.db"}
+	}
+	close(in)
+	for range teeMatches(in, []matchSink{c}, nil) {
+	}
+	if got := c.n.Load(); got != 3 {
+		t.Fatalf("expected 3 matches counted, got %d", got)
+	}
+}
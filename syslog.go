@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// syslogWriter is the part of *syslog.Writer the sink uses.
+type syslogWriter interface {
+	Info(msg string) error
+	Close() error
+}
+
+// syslogSink is a matchSink logging every match to the local syslog
+// daemon (--syslog) for audit trails.
+type syslogSink struct {
+	w syslogWriter
+}
+
+func (s *syslogSink) Add(m matchResult) error {
+	return s.w.Info(syslogMessage(m))
+}
+
+func (s *syslogSink) Close() error { return s.w.Close() }
+
+// syslogMessage is the text logged for a match. The daemon adds the
+// "sqlite-scanner[pid]:" tag in front of it.
+func syslogMessage(m matchResult) string {
+	if m.Size < 0 {
+		return fmt.Sprintf("found %s", formatPath(m.Path))
+	}
+	return fmt.Sprintf("found %s (%d bytes)", formatPath(m.Path), m.Size)
+}
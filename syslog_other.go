@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package main
+
+import "errors"
+
+const syslogSupported = false
+
+func dialSyslog() (syslogWriter, error) {
+	return nil, errors.New("--syslog is not supported on this platform")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// recordingSyslog collects the messages a syslogSink logs.
+type recordingSyslog struct {
+	msgs   []string
+	closed bool
+}
+
+func (r *recordingSyslog) Info(msg string) error {
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func (r *recordingSyslog) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestSyslogSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	rec := &recordingSyslog{}
+	sink := &syslogSink{w: rec}
+	sink.Add(matchResult{Path: path, Size: 8192})
+	sink.Add(matchResult{Path: path, Size: -1})
+	if err := sink.Close(); err != nil || !rec.closed {
+		t.Fatalf("expected the writer to be closed, got %v", err)
+	}
+	want := []string{"found " + path + " (8192 bytes)", "found " + path}
+	if len(rec.msgs) != len(want) {
+		t.Fatalf("expected %q, got %q", want, rec.msgs)
+	}
+	for i := range want {
+		if rec.msgs[i] != want[i] {
+			t.Fatalf("expected %q, got %q", want[i], rec.msgs[i])
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+
+package main
+
+import "log/syslog"
+
+const syslogSupported = true
+
+// dialSyslog connects to the local syslog daemon with facility LOG_DAEMON
+// and priority LOG_INFO.
+func dialSyslog() (syslogWriter, error) {
+	return syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "sqlite-scanner")
+}
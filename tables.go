@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errBadBTree reports a sqlite_master b-tree that cannot be parsed.
+var errBadBTree = errors.New("malformed sqlite_master b-tree")
+
+// maxBTreeDepth bounds the walk of sqlite_master so a corrupt file whose
+// child pointers form a cycle cannot keep it going forever.
+const maxBTreeDepth = 20
+
+// countTables counts the user tables in the database r by walking the
+// sqlite_master b-tree rooted at page 1, without the SQLite driver. hdr is
+// the complete database header. Internal sqlite_* tables are not counted.
+// It returns -1 when the schema cannot be parsed, as for a corrupt or
+// truncated file.
+func countTables(r io.ReaderAt, hdr []byte) int {
+	if len(hdr) < sqliteHeaderSize {
+		return -1
+	}
+	pageSize := headerPageSize(hdr)
+	if pageSize == 0 {
+		return -1
+	}
+	usable := pageSize - int(hdr[20])
+	if usable < 480 {
+		return -1
+	}
+	w := btreeWalker{r: r, pageSize: pageSize, usable: usable, seen: map[uint32]bool{}}
+	if err := w.walk(1, 0); err != nil {
+		return -1
+	}
+	return w.tables
+}
+
+type btreeWalker struct {
+	r        io.ReaderAt
+	pageSize int
+	usable   int
+	seen     map[uint32]bool
+	tables   int
+}
+
+func (w *btreeWalker) walk(pgno uint32, depth int) error {
+	if pgno == 0 || w.seen[pgno] || depth > maxBTreeDepth {
+		return errBadBTree
+	}
+	w.seen[pgno] = true
+	page := make([]byte, w.pageSize)
+	if _, err := w.r.ReadAt(page, int64(pgno-1)*int64(w.pageSize)); err != nil {
+		return err
+	}
+	// Page 1 starts with the database header; offsets within the page
+	// are still relative to its start.
+	hdrOff := 0
+	if pgno == 1 {
+		hdrOff = sqliteHeaderSize
+	}
+	if hdrOff+8 > len(page) {
+		return errBadBTree
+	}
+	kind := page[hdrOff]
+	ncells := int(binary.BigEndian.Uint16(page[hdrOff+3:]))
+	ptrs := hdrOff + 8
+	switch kind {
+	case 0x0d: // table leaf
+	case 0x05: // table interior
+		ptrs = hdrOff + 12
+		if ptrs > len(page) {
+			return errBadBTree
+		}
+	default:
+		return errBadBTree
+	}
+	if ptrs+2*ncells > len(page) {
+		return errBadBTree
+	}
+	for i := 0; i < ncells; i++ {
+		off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+		if off < ptrs+2*ncells || off >= w.usable {
+			return errBadBTree
+		}
+		if kind == 0x05 {
+			if off+4 > len(page) {
+				return errBadBTree
+			}
+			if err := w.walk(binary.BigEndian.Uint32(page[off:]), depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.leafCell(page[off:w.usable]); err != nil {
+			return err
+		}
+	}
+	if kind == 0x05 {
+		return w.walk(binary.BigEndian.Uint32(page[hdrOff+8:]), depth+1)
+	}
+	return nil
+}
+
+// leafCell counts the sqlite_master row in cell if it describes a user
+// table. Only the start of the payload is read, which always lies on the
+// page even when the rest spills onto overflow pages.
+func (w *btreeWalker) leafCell(cell []byte) error {
+	payload, n := readVarint(cell)
+	if n == 0 {
+		return errBadBTree
+	}
+	cell = cell[n:]
+	if _, n = readVarint(cell); n == 0 { // rowid
+		return errBadBTree
+	}
+	cell = cell[n:]
+	// The minimum local payload; see the b-tree cell format in
+	// https://www.sqlite.org/fileformat.html#b_tree_pages
+	local := int64((w.usable-12)*32/255 - 23)
+	if payload < local {
+		local = payload
+	}
+	if local > int64(len(cell)) {
+		return errBadBTree
+	}
+	cols, err := recordPrefix(cell[:local], 2)
+	if err != nil {
+		return err
+	}
+	if cols[0] == "table" && !strings.HasPrefix(cols[1], "sqlite_") {
+		w.tables++
+	}
+	return nil
+}
+
+// recordPrefix decodes the first n columns of a record as text, truncated
+// to what rec holds. Columns that are not text decode as "".
+func recordPrefix(rec []byte, n int) ([]string, error) {
+	hdrLen, k := readVarint(rec)
+	if k == 0 || hdrLen > int64(len(rec)) || hdrLen < int64(k) {
+		return nil, errBadBTree
+	}
+	types := rec[k:hdrLen]
+	body := rec[hdrLen:]
+	cols := make([]string, n)
+	for i := 0; i < n; i++ {
+		t, k := readVarint(types)
+		if k == 0 {
+			return nil, errBadBTree
+		}
+		types = types[k:]
+		size := serialTypeSize(t)
+		if size < 0 {
+			return nil, errBadBTree
+		}
+		end := min(size, int64(len(body)))
+		if t >= 13 && t%2 == 1 {
+			cols[i] = string(body[:end])
+		}
+		body = body[end:]
+	}
+	return cols, nil
+}
+
+// serialTypeSize returns the length in bytes of a record value of serial
+// type t, or -1 for the reserved types 10 and 11.
+func serialTypeSize(t int64) int64 {
+	switch {
+	case t <= 4:
+		return t
+	case t == 5:
+		return 6
+	case t == 6, t == 7:
+		return 8
+	case t == 8, t == 9:
+		return 0
+	case t >= 12:
+		return (t - 12) / 2
+	}
+	return -1
+}
+
+// readVarint decodes a SQLite big-endian varint from b, returning the
+// value and its length, or a length of 0 when b is too short.
+func readVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return 0, 0
+}
+
+// minTablesFilter keeps databases with at least min user tables; files
+// whose schema could not be parsed (-1) never qualify.
+func minTablesFilter(min int) matchFilter {
+	return func(m matchResult) bool {
+		return m.Tables >= min
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simonw/sqlite-scanner/internal/testutil"
+)
+
+func TestCheckSQLiteMagicMinTables(t *testing.T) {
+	dir := t.TempDir()
+	var many []string
+	for i := 0; i < 300; i++ {
+		// Enough rows to split sqlite_master over interior pages.
+		many = append(many, fmt.Sprintf("table_with_a_long_name_%03d", i))
+	}
+	magicOnly := filepath.Join(dir, "magic-only.db")
+	if err := os.WriteFile(magicOnly, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	filter := minTablesFilter(2)
+	for _, tc := range []struct {
+		path string
+		want int
+		keep bool
+	}{
+		{testutil.CreateSQLiteDB(t, dir, "empty.db", nil), 0, false},
+		{testutil.CreateSQLiteDB(t, dir, "one.db", []string{"users"}), 1, false},
+		{testutil.CreateSQLiteDB(t, dir, "multi.db", []string{"users", "orders", "items"}), 3, true},
+		{testutil.CreateSQLiteDB(t, dir, "many.db", many), 300, true},
+		{magicOnly, -1, false},
+	} {
+		res, ok, err := checkSQLiteMagic(tc.path, checkOptions{CountTables: true})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, got ok=%v err=%v", tc.path, ok, err)
+		}
+		if res.Tables != tc.want {
+			t.Fatalf("%s: expected %d tables, got %d", filepath.Base(tc.path), tc.want, res.Tables)
+		}
+		if filter(res) != tc.keep {
+			t.Fatalf("%s: --min-tables 2 kept=%v, want %v", filepath.Base(tc.path), !tc.keep, tc.keep)
+		}
+	}
+}
+
+func TestCountTablesIgnoresInternalTables(t *testing.T) {
+	path := testutil.CreateSQLiteDB(t, t.TempDir(), "seq.db", nil)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT); CREATE INDEX t_id ON t(id)"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	db.Close()
+	res, _, err := checkSQLiteMagic(path, checkOptions{CountTables: true})
+	if err != nil || res.Tables != 1 {
+		t.Fatalf("expected 1 table besides sqlite_sequence and the index, got %d (err %v)", res.Tables, err)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// tarSuffixes are the file names --tar opens as archives.
+var tarSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2"}
+
+// archiveSeparator joins an archive's path to a member's name in the
+// reported path, as in backup.tar.gz::data/app.db.
+const archiveSeparator = "::"
+
+// isTarArchive reports whether path looks like a tar archive by its name.
+func isTarArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, s := range tarSuffixes {
+		if strings.HasSuffix(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// tarReader wraps r, the contents of the archive named path, with the
+// decompressor its name calls for.
+func tarReader(path string, r io.Reader) (*tar.Reader, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".gz"), strings.HasSuffix(lower, ".tgz"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	case strings.HasSuffix(lower, ".bz2"):
+		r = bzip2.NewReader(r)
+	}
+	return tar.NewReader(r), nil
+}
+
+// scanReader checks the header of a stream holding one file, such as an
+// archive member, reading no further than the header unless opts asks for
+// a hash. Checks that need a real file (locks, --open-check) are skipped.
+func scanReader(r io.Reader, opts checkOptions) (matchResult, bool, error) {
+	buf := make([]byte, sqliteHeaderSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return matchResult{}, false, err
+	}
+	if opts.Stats != nil {
+		opts.Stats.BytesRead.Add(int64(n))
+	}
+	res, ok := matchHeader(buf[:n], opts.WALFiles)
+	if !ok {
+		return matchResult{}, false, nil
+	}
+	if opts.Hash {
+		sum, extra, err := hashFile(opts.newHash(), buf[:n], r, opts.HashMaxBytes)
+		if opts.Stats != nil {
+			opts.Stats.BytesRead.Add(extra)
+		}
+		if err != nil {
+			return matchResult{}, false, err
+		}
+		res.setHash(sum, opts)
+		if opts.HashMaxBytes > 0 {
+			hashed := min(int64(n), opts.HashMaxBytes) + extra
+			// Anything left unread means the hash stopped short.
+			if more, _ := r.Read(make([]byte, 1)); more > 0 {
+				res.HashPartial = true
+				res.HashedBytes = hashed
+			}
+		}
+	}
+	return res, true, nil
+}
+
+// scanTar checks every regular file in the tar archive at path and calls
+// emit for each SQLite member, reported as path::member with the size and
+// mtime from the member's tar header.
+//
+// With sem, the members of an uncompressed archive are read in place at
+// their offsets, each in its own goroutine once it holds a slot in sem, so
+// a slow filesystem serves several at a time (--concurrent-archives); emit
+// must then be safe to call concurrently. Compressed archives can only be
+// read front to back and are always checked one member at a time.
+func scanTar(path string, opts checkOptions, sem chan struct{}, emit func(matchResult)) error {
+	f, err := openForCheck(path, opts.NoATime)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr, err := tarReader(path, f)
+	if err != nil {
+		return err
+	}
+	inPlace := sem != nil && isUncompressedTar(path)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var memberErr error
+	// check reports the member hdr whose contents r yields, or looks
+	// inside it when it is itself an archive within --archive-depth.
+	check := func(hdr *tar.Header, r io.Reader) error {
+		if opts.archiveDepth() > 1 && isTarArchive(hdr.Name) {
+			return scanNestedTar(path+archiveSeparator+hdr.Name, r, opts, 2, emit)
+		}
+		return checkMember(path, hdr, r, opts, emit)
+	}
+	// Wait for the members still being read before f is closed.
+	defer wg.Wait()
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !inPlace || isSparseMember(hdr) {
+			if err := check(hdr, tr); err != nil {
+				return err
+			}
+			continue
+		}
+		// tar.Reader reads headers block by block from f itself, so
+		// after Next the file offset is where this member's data starts.
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(hdr *tar.Header, r io.Reader) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := check(hdr, r); err != nil {
+				mu.Lock()
+				memberErr = errors.Join(memberErr, err)
+				mu.Unlock()
+			}
+		}(hdr, io.NewSectionReader(f, off, hdr.Size))
+	}
+	wg.Wait()
+	return memberErr
+}
+
+// scanNestedTar checks the members of the archive named path, read from
+// r, which sits level archives deep (the outermost being level 1). Members
+// that are archives themselves are opened in turn until the level reaches
+// --archive-depth, so a deeply nested archive cannot run away.
+func scanNestedTar(path string, r io.Reader, opts checkOptions, level int, emit func(matchResult)) error {
+	tr, err := tarReader(path, r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if level < opts.archiveDepth() && isTarArchive(hdr.Name) {
+			if err := scanNestedTar(path+archiveSeparator+hdr.Name, tr, opts, level+1, emit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := checkMember(path, hdr, tr, opts, emit); err != nil {
+			return err
+		}
+	}
+}
+
+// checkMember checks the archive member hdr, whose contents r yields, and
+// emits it as path::member when it is a SQLite file, with the size and
+// mtime from its tar header.
+func checkMember(path string, hdr *tar.Header, r io.Reader, opts checkOptions, emit func(matchResult)) error {
+	if opts.Stats != nil {
+		opts.Stats.FilesChecked.Add(1)
+	}
+	res, ok, err := scanReader(r, opts)
+	if err != nil || !ok {
+		return err
+	}
+	res.Path = path + archiveSeparator + hdr.Name
+	res.Size = -1
+	if !opts.SkipStat {
+		res.Size = hdr.Size
+		res.ModTime = hdr.ModTime
+		res.Mode = hdr.FileInfo().Mode().Perm()
+		res.estimatePageCount()
+	}
+	emit(res)
+	return nil
+}
+
+// archiveDepth is how many levels of nested archives --tar opens; the
+// default of 1 only opens archives found on disk.
+func (o checkOptions) archiveDepth() int {
+	return max(o.ArchiveDepth, 1)
+}
+
+// isUncompressedTar reports whether the archive at path is a plain tar,
+// whose members can be read at their offsets in the file.
+func isUncompressedTar(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".tar")
+}
+
+// isSparseMember reports whether hdr is a GNU sparse file stored in PAX
+// format, whose data starts with a sparse map rather than the contents.
+func isSparseMember(hdr *tar.Header) bool {
+	_, ok := hdr.PAXRecords["GNU.sparse.major"]
+	return ok
+}
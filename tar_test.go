@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeTarGz writes a gzip-compressed tar archive holding files to path.
+func writeTarGz(t *testing.T, path string, files map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	writeTarMembers(t, gz, files)
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+}
+
+// writeTarMembers writes a tar stream holding files, in name order, to w.
+func writeTarMembers(t *testing.T, w io.Writer, files map[string][]byte) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(files[name])), ModTime: time.Unix(1700000000, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		tw.Write(files[name])
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+}
+
+func TestScanPathsTarArchive(t *testing.T) {
+	root := t.TempDir()
+	db := append(append([]byte{}, sqliteMagic...), make([]byte, 200)...)
+	archive := filepath.Join(root, "backup.tar.gz")
+	writeTarGz(t, archive, map[string][]byte{
+		"data/app.db":    db,
+		"data/notes.txt": []byte("not a database at all"),
+		"cache":          sqliteMagic,
+	})
+	if err := os.WriteFile(filepath.Join(root, "plain.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	matches := make(chan matchResult, 8)
+	errs := make(chan error, 8)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Tar: true}
+	if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+		t.Fatalf("scanPaths: %v", err)
+	}
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]matchResult{}
+	for m := range matches {
+		got[m.Path] = m
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 matches, got %v", got)
+	}
+	member, ok := got[archive+"::data/app.db"]
+	if !ok || member.Size != int64(len(db)) || !member.ModTime.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("expected data/app.db member with size %d, got %+v", len(db), got)
+	}
+	if _, ok := got[archive+"::cache"]; !ok {
+		t.Fatalf("expected the cache member to match, got %v", got)
+	}
+	if _, ok := got[filepath.Join(root, "plain.db")]; !ok {
+		t.Fatalf("expected plain.db alongside the archive, got %v", got)
+	}
+}
+
+func TestScanTarConcurrentMembers(t *testing.T) {
+	files := map[string][]byte{"notes.txt": []byte("not a database at all")}
+	want := map[string]string{}
+	for i := range 8 {
+		name := fmt.Sprintf("db%d.sqlite", i)
+		// Sizes that are not block multiples check the member offsets.
+		db := append(append([]byte{}, sqliteMagic...), bytes.Repeat([]byte{byte(i)}, 700*i+13)...)
+		files[name] = db
+		sum := sha256.Sum256(db)
+		want[name] = hex.EncodeToString(sum[:])
+	}
+	var buf bytes.Buffer
+	writeTarMembers(t, &buf, files)
+	archive := filepath.Join(t.TempDir(), "backup.tar")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	var mu sync.Mutex
+	got := map[string]string{}
+	sem := make(chan struct{}, 3)
+	err := scanTar(archive, checkOptions{Hash: true}, sem, func(m matchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[strings.TrimPrefix(m.Path, archive+archiveSeparator)] = m.SHA256
+	})
+	if err != nil {
+		t.Fatalf("scanTar: %v", err)
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("expected members %v, got %v", want, got)
+	}
+	if len(sem) != 0 {
+		t.Fatalf("expected every slot released, %d still held", len(sem))
+	}
+}
+
+func TestScanTarArchiveDepth(t *testing.T) {
+	db := append(append([]byte{}, sqliteMagic...), make([]byte, 200)...)
+	var innermost, inner bytes.Buffer
+	writeTarMembers(t, &innermost, map[string][]byte{"deep.db": db})
+	writeTarMembers(t, &inner, map[string][]byte{"nested.db": db, "more.tar": innermost.Bytes()})
+	archive := filepath.Join(t.TempDir(), "outer.tar.gz")
+	writeTarGz(t, archive, map[string][]byte{"top.db": db, "inner.tar": inner.Bytes()})
+
+	for depth, want := range map[int][]string{
+		1: {"top.db"},
+		2: {"inner.tar::nested.db", "top.db"},
+		3: {"inner.tar::more.tar::deep.db", "inner.tar::nested.db", "top.db"},
+	} {
+		var got []string
+		err := scanTar(archive, checkOptions{ArchiveDepth: depth}, nil, func(m matchResult) {
+			got = append(got, strings.TrimPrefix(m.Path, archive+archiveSeparator))
+		})
+		if err != nil {
+			t.Fatalf("depth %d: scanTar: %v", depth, err)
+		}
+		sort.Strings(got)
+		if !slices.Equal(got, want) {
+			t.Fatalf("depth %d: expected %v, got %v", depth, want, got)
+		}
+	}
+}
+
+func TestIsTarArchive(t *testing.T) {
+	for path, want := range map[string]bool{
+		"a.tar": true, "a.TAR.GZ": true, "a.tgz": true, "a.tar.bz2": true,
+		"a.gz": false, "a.zip": false, "a.db": false,
+	} {
+		if got := isTarArchive(path); got != want {
+			t.Fatalf("isTarArchive(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log/slog"
+	"unicode/utf8"
+)
+
+// Values of scanOptions.UTF8Paths.
+const (
+	utf8PathsWarn = "warn"
+	utf8PathsSkip = "skip"
+)
+
+// checkUTF8Path applies --validate-utf8-paths to a file found by the walk:
+// it logs a warning when path is not valid UTF-8 and reports whether the
+// file should still be checked, which it is unless mode is utf8PathsSkip.
+func checkUTF8Path(logger *slog.Logger, mode, path string) bool {
+	if mode == "" || utf8.ValidString(path) {
+		return true
+	}
+	if mode == utf8PathsSkip {
+		logger.Warn("skipping path that is not valid UTF-8", "path", path)
+		return false
+	}
+	logger.Warn("path is not valid UTF-8", "path", path)
+	return true
+}
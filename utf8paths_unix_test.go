@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanPathsValidateUTF8Paths(t *testing.T) {
+	root := t.TempDir()
+	bad := filepath.Join(root, "bad\xff\xfe.db")
+	if err := os.WriteFile(bad, sqliteMagic, 0o600); err != nil {
+		// Some filesystems, such as APFS, refuse invalid UTF-8 names.
+		t.Skipf("cannot create a non-UTF-8 file name: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "good.db"), sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	for mode, wantMatches := range map[string]int{"": 2, utf8PathsWarn: 2, utf8PathsSkip: 1} {
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+		matches := make(chan matchResult, 8)
+		errs := make(chan error, 8)
+		opts := scanOptions{Workers: 2, Logger: logger, UTF8Paths: mode}
+		if err := scanPaths(context.Background(), []string{root}, opts, matches, errs); err != nil {
+			t.Fatalf("%q: scanPaths: %v", mode, err)
+		}
+		n := 0
+		for m := range matches {
+			if mode == utf8PathsSkip && m.Path == bad {
+				t.Fatalf("%q: expected %q to be skipped", mode, bad)
+			}
+			n++
+		}
+		if n != wantMatches {
+			t.Fatalf("%q: expected %d matches, got %d", mode, wantMatches, n)
+		}
+		flagged := strings.Contains(logs.String(), "not valid UTF-8")
+		if flagged != (mode != "") {
+			t.Fatalf("%q: expected flagged=%v, logs: %s", mode, mode != "", logs.String())
+		}
+		if strings.Contains(logs.String(), "good.db") {
+			t.Fatalf("%q: valid path was flagged: %s", mode, logs.String())
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latestReleaseURL is the GitHub API endpoint --version-check asks for
+// the newest release.
+const latestReleaseURL = "https://api.github.com/repos/simonw/sqlite-scanner/releases/latest"
+
+// versionCheckTimeout bounds the whole --version-check request.
+const versionCheckTimeout = 3 * time.Second
+
+// latestRelease returns the tag name of the latest release from the
+// GitHub API at url.
+func latestRelease(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "sqlite-scanner/"+version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release); err != nil {
+		return "", err
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("%s: no tag_name in response", url)
+	}
+	return release.TagName, nil
+}
+
+// checkVersion implements --version-check: it compares current with the
+// latest release at url and writes the verdict to w. Network and API
+// errors are returned for the caller to log; nothing is written then.
+func checkVersion(ctx context.Context, w io.Writer, url, current string) error {
+	ctx, cancel := context.WithTimeout(ctx, versionCheckTimeout)
+	defer cancel()
+	latest, err := latestRelease(ctx, http.DefaultClient, url)
+	if err != nil {
+		return err
+	}
+	cur, ok := parseVersion(current)
+	if !ok {
+		fmt.Fprintf(w, "latest release is %s (this is a %s build)\n", latest, current)
+		return nil
+	}
+	if rel, ok := parseVersion(latest); ok && compareVersions(rel, cur) > 0 {
+		fmt.Fprintf(w, "update available: %s\n", latest)
+		return nil
+	}
+	fmt.Fprintln(w, "up to date")
+	return nil
+}
+
+// parseVersion parses "v1.2.3" or "1.2.3" into its numeric parts,
+// ignoring any pre-release or build metadata suffix.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, equal to or
+// newer than b.
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v0.4.0", "name": "0.4.0"}`))
+	}))
+	defer srv.Close()
+
+	for current, want := range map[string]string{
+		"0.3.9":   "update available: v0.4.0\n",
+		"0.4.0":   "up to date\n",
+		"v0.10.0": "up to date\n",
+		"dev":     "latest release is v0.4.0 (this is a dev build)\n",
+	} {
+		var buf bytes.Buffer
+		if err := checkVersion(context.Background(), &buf, srv.URL, current); err != nil {
+			t.Fatalf("%s: checkVersion: %v", current, err)
+		}
+		if buf.String() != want {
+			t.Fatalf("%s: expected %q, got %q", current, want, buf.String())
+		}
+	}
+}
+
+func TestCheckVersionNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	if err := checkVersion(context.Background(), &buf, srv.URL, "0.1.0"); err == nil {
+		t.Fatalf("expected an error for a failed request")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no verdict on error, got %q", buf.String())
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchRoots implements --watch: it watches every directory under roots
+// and passes regular files that are created or written to check, until ctx
+// is cancelled. fsnotify watches are not recursive, so directories created
+// later are added as they appear. A path is reported at most once until it
+// is removed or renamed away, however many writes follow.
+func watchRoots(ctx context.Context, roots []string, opts scanOptions, errs chan<- error, check func(path, label string) bool) error {
+	logger := opts.Logger
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	// labels maps each watched directory to the label of its root.
+	labels := make(map[string]string)
+	reported := make(map[string]bool)
+
+	offer := func(path, label string) {
+		if reported[path] {
+			return
+		}
+		if check(path, label) {
+			reported[path] = true
+		}
+	}
+
+	// addTree watches dir and everything below it. With checkFiles set,
+	// files already present are checked too, since they may have been
+	// created before the watch on their directory existed.
+	addTree := func(dir, label string, checkFiles bool) {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					opts.Denied.add(path)
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				if checkFiles && d.Type().IsRegular() {
+					offer(path, label)
+				}
+				return nil
+			}
+			if err := w.Add(path); err != nil {
+				errs <- fmt.Errorf("watch %s: %w", path, err)
+				return filepath.SkipDir
+			}
+			labels[path] = label
+			return nil
+		})
+	}
+
+	for _, r := range roots {
+		addTree(r, opts.Labels[r], false)
+	}
+	logger.Info("watching for new databases", "directories", len(labels))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			errs <- fmt.Errorf("watch: %w", err)
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			label := labels[filepath.Dir(ev.Name)]
+			if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+				delete(reported, ev.Name)
+				delete(labels, ev.Name)
+				continue
+			}
+			if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) {
+				continue
+			}
+			info, err := os.Lstat(ev.Name)
+			if err != nil {
+				continue
+			}
+			switch {
+			case info.IsDir() && ev.Has(fsnotify.Create):
+				logger.Debug("watching new directory", "path", ev.Name)
+				addTree(ev.Name, label, true)
+			case info.Mode().IsRegular():
+				offer(ev.Name, label)
+			}
+		}
+	}
+}
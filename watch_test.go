@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanPathsWatchReportsNewDatabase(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing.db")
+	if err := os.WriteFile(existing, sqliteMagic, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	matches := make(chan matchResult, 16)
+	errs := make(chan error, 16)
+	opts := scanOptions{Workers: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), Watch: true}
+	done := make(chan error, 1)
+	go func() {
+		done <- scanPaths(ctx, []string{root}, opts, matches, errs)
+	}()
+
+	select {
+	case m := <-matches:
+		if m.Path != existing {
+			t.Fatalf("expected the initial scan to report %s, got %s", existing, m.Path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("initial scan reported nothing")
+	}
+
+	// The watch starts after the initial scan with no signal to wait on,
+	// so keep rewriting the new files until they are noticed.
+	sub := filepath.Join(root, "sub")
+	fresh := filepath.Join(root, "fresh.db")
+	nested := filepath.Join(sub, "nested.db")
+	got := map[string]bool{}
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for !got[fresh] || !got[nested] {
+		select {
+		case m := <-matches:
+			if got[m.Path] {
+				t.Fatalf("%s reported twice", m.Path)
+			}
+			got[m.Path] = true
+		case <-tick.C:
+			os.WriteFile(filepath.Join(root, "notes.txt"), []byte("not a database"), 0o600)
+			if !got[fresh] {
+				os.WriteFile(fresh, sqliteMagic, 0o600)
+			}
+			if _, err := os.Stat(sub); err != nil {
+				os.Mkdir(sub, 0o755)
+				os.WriteFile(nested, sqliteMagic, 0o600)
+			}
+		case <-deadline:
+			t.Fatalf("watch reported %v, expected %s and %s", got, fresh, nested)
+		}
+	}
+	if got[filepath.Join(root, "notes.txt")] {
+		t.Fatalf("non-database reported")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("scanPaths: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watch did not stop after cancel")
+	}
+	for range matches {
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "os"
+
+// isPlaceholderName reports whether path has an extension that marks it
+// as a database for --include-zero-size.
+func isPlaceholderName(path string) bool {
+	switch extensionOf(path) {
+	case ".db", ".sqlite":
+		return true
+	}
+	return false
+}
+
+// placeholderMatch reports the empty file f, already read to EOF by
+// checkSQLiteMagic, as a placeholder. Without a header there is nothing
+// to hash, lock-check or open, so only the stat fields are filled in.
+func placeholderMatch(f *os.File, path string, opts checkOptions) (matchResult, bool, error) {
+	res := matchResult{Path: path, Kind: kindPlaceholder, UID: -1, GID: -1}
+	if opts.SkipStat {
+		return res, true, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return matchResult{}, false, err
+	}
+	// The read hit EOF at once, but the file may have grown since.
+	if info.Size() != 0 {
+		return matchResult{}, false, nil
+	}
+	res.ModTime = info.ModTime()
+	res.Mode = info.Mode().Perm()
+	if opts.Owner {
+		if uid, gid, ok := fileOwner(info); ok {
+			res.UID, res.GID = uid, gid
+		}
+	}
+	return res, true, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncludeZeroSize(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"empty.db", "empty.SQLITE", "empty.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"empty.db", "empty.SQLITE", "empty.txt"} {
+		if _, ok, err := checkSQLiteMagic(filepath.Join(dir, name), checkOptions{}); err != nil || ok {
+			t.Fatalf("%s: expected no match without the flag, got ok=%v err=%v", name, ok, err)
+		}
+	}
+
+	opts := checkOptions{ZeroSize: true}
+	for _, name := range []string{"empty.db", "empty.SQLITE"} {
+		res, ok, err := checkSQLiteMagic(filepath.Join(dir, name), opts)
+		if err != nil || !ok {
+			t.Fatalf("%s: expected a placeholder match, got ok=%v err=%v", name, ok, err)
+		}
+		if res.Kind != kindPlaceholder || res.Size != 0 || res.ModTime.IsZero() {
+			t.Fatalf("%s: unexpected match %+v", name, res)
+		}
+	}
+	if _, ok, _ := checkSQLiteMagic(filepath.Join(dir, "empty.txt"), opts); ok {
+		t.Fatal("expected an empty .txt file not to match")
+	}
+
+	if got := formatPlainMatch(matchResult{Path: "/data/empty.db", Kind: kindPlaceholder}, outputOptions{ShowKind: true}); got != "/data/empty.db [placeholder]" {
+		t.Fatalf("unexpected plain output %q", got)
+	}
+}